@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/josephus-git/TCAS-simulation/internal/aviation"
 	"github.com/josephus-git/TCAS-simulation/internal/config"
 )
@@ -13,7 +15,12 @@ type cliCommand struct {
 }
 
 // getCommand returns a map of available CLI commands for the TCAS-simulator.
-func getCommand(cfg *config.Config, simState *aviation.SimulationState, argument2 string) map[string]cliCommand {
+// arguments holds the words typed after the command name.
+func getCommand(cfg *config.Config, simState *aviation.SimulationState, arguments []string) map[string]cliCommand {
+	argument2 := ""
+	if len(arguments) > 0 {
+		argument2 = strings.ToLower(arguments[0])
+	}
 	commands := map[string]cliCommand{
 		"exit": {
 			name:        "exit",
@@ -26,21 +33,42 @@ func getCommand(cfg *config.Config, simState *aviation.SimulationState, argument
 			name:        "help",
 			description: "Display usage of the application",
 			callback: func() {
-				helpFunc(cfg, simState, argument2)
+				helpFunc(cfg, simState, arguments)
 			},
 		},
 		"start": {
 			name:        "start",
-			description: "Initializes and runs the simulation",
+			description: "Initializes and runs the simulation, usage: start <minutes> [speed] [--seed N] (speed: 1x, 10x, 100x or max)",
 			callback: func() {
-				go startInit(simState, argument2)
+				go startInit(cfg, simState, arguments)
+			},
+		},
+		"load": {
+			name:        "load",
+			description: "Loads a scenario file in place of the current world, usage: load <scenario file>",
+			callback: func() {
+				loadScenario(cfg, simState, arguments)
+			},
+		},
+		"save": {
+			name:        "save",
+			description: "Saves the current world as a loadable scenario file, usage: save scenario <file>",
+			callback: func() {
+				saveScenario(simState, arguments)
+			},
+		},
+		"campaign": {
+			name:        "campaign",
+			description: "Runs many simulations over a parameter grid and reports safety statistics, usage: campaign " + campaignUsage,
+			callback: func() {
+				campaignCommand(simState, arguments)
 			},
 		},
 		"get": {
 			name:        "get",
-			description: "prints details of the simulation such as airports, Planes and flights to the console",
+			description: "prints details of the simulation such as airports, Planes, flights and a flight's track to the console",
 			callback: func() {
-				getDetails(simState, argument2)
+				getDetails(simState, arguments)
 			},
 		},
 		"log": {
@@ -50,6 +78,41 @@ func getCommand(cfg *config.Config, simState *aviation.SimulationState, argument
 				logDetails(simState, argument2)
 			},
 		},
+		"export": {
+			name:        "export",
+			description: "exports airports, Planes, flights and engagements for analysis tools, usage: export csv <dir> | kml <file> | geojson <file>",
+			callback: func() {
+				exportData(simState, arguments)
+			},
+		},
+		"radar": {
+			name:        "radar",
+			description: "shows a live top-down view of the airspace with airports, Planes and their TCAS advisories, press Enter to close it",
+			callback: func() {
+				startRadar(simState)
+			},
+		},
+		"pause": {
+			name:        "pause",
+			description: "freezes the running simulation in simulation time, so that it can be inspected with get",
+			callback: func() {
+				pauseSimulation(simState)
+			},
+		},
+		"resume": {
+			name:        "resume",
+			description: "continues a paused simulation from where it was paused",
+			callback: func() {
+				resumeSimulation(simState)
+			},
+		},
+		"step": {
+			name:        "step",
+			description: "advances a paused simulation by a duration of simulation time, usage: step <duration> (e.g. 30s, 2m)",
+			callback: func() {
+				stepSimulation(simState, arguments)
+			},
+		},
 		"q": {
 			name:        "q",
 			description: "Immediately halts the active simulation.",
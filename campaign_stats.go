@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// z95 is the standard normal quantile used for 95% confidence intervals.
+const z95 = 1.959964
+
+// campaignCellSummary aggregates the runs of one grid cell.
+// Like those of campaignRunResult, its engagements and averted conflicts are counted per plane.
+type campaignCellSummary struct {
+	Cell        campaignCell
+	Runs        int
+	Flights     int
+	FlightHours float64
+	Engagements int // TCAS engagements, per plane
+	Averted     int // RAs that ended without a collision, per plane
+	Crashes     int
+
+	// share of runs that ended in a crash, with its Wilson 95% interval
+	CrashRunRatio, CrashRunLow, CrashRunHigh float64
+	// crashes per flight-hour, with its Poisson 95% interval
+	CrashesPerFlightHour, CrashRateLow, CrashRateHigh float64
+	// mean engagements (per plane) per run, with its normal 95% interval
+	EngagementsPerRun, EngagementsLow, EngagementsHigh float64
+}
+
+// summarizeCampaign aggregates the run results per grid cell.
+func summarizeCampaign(cells []campaignCell, results []campaignRunResult) []campaignCellSummary {
+	summaries := make([]campaignCellSummary, len(cells))
+	engagementsPerRun := make([][]float64, len(cells))
+	crashedRuns := make([]int, len(cells))
+	for i, cell := range cells {
+		summaries[i].Cell = cell
+	}
+
+	for _, result := range results {
+		summary := &summaries[result.Cell]
+		summary.Runs++
+		summary.Flights += result.Flights
+		summary.FlightHours += result.FlightHours
+		summary.Engagements += result.Engagements
+		summary.Averted += result.Averted
+		summary.Crashes += result.Crashes
+		engagementsPerRun[result.Cell] = append(engagementsPerRun[result.Cell], float64(result.Engagements))
+		if result.Crashes > 0 {
+			crashedRuns[result.Cell]++
+		}
+	}
+
+	for i := range summaries {
+		summary := &summaries[i]
+		summary.CrashRunRatio, summary.CrashRunLow, summary.CrashRunHigh = wilsonInterval(crashedRuns[i], summary.Runs)
+		summary.CrashesPerFlightHour, summary.CrashRateLow, summary.CrashRateHigh = poissonRateInterval(summary.Crashes, summary.FlightHours)
+		summary.EngagementsPerRun, summary.EngagementsLow, summary.EngagementsHigh = meanInterval(engagementsPerRun[i])
+	}
+	return summaries
+}
+
+// wilsonInterval returns the proportion successes/trials and its Wilson score 95% interval.
+func wilsonInterval(successes, trials int) (ratio, low, high float64) {
+	if trials == 0 {
+		return 0, 0, 0
+	}
+	n := float64(trials)
+	ratio = float64(successes) / n
+	denominator := 1 + z95*z95/n
+	centre := (ratio + z95*z95/(2*n)) / denominator
+	margin := z95 * math.Sqrt(ratio*(1-ratio)/n+z95*z95/(4*n*n)) / denominator
+	return ratio, math.Max(0, centre-margin), math.Min(1, centre+margin)
+}
+
+// poissonRateInterval returns the rate count/exposure and an approximate 95% interval,
+// using the rule of three when no event was observed.
+func poissonRateInterval(count int, exposure float64) (rate, low, high float64) {
+	if exposure <= 0 {
+		return 0, 0, 0
+	}
+	if count == 0 {
+		return 0, 0, 3 / exposure
+	}
+	k := float64(count)
+	rate = k / exposure
+	margin := z95 * math.Sqrt(k) / exposure
+	return rate, math.Max(0, rate-margin), rate + margin
+}
+
+// meanInterval returns the sample mean and its normal-approximation 95% interval.
+func meanInterval(samples []float64) (mean, low, high float64) {
+	if len(samples) == 0 {
+		return 0, 0, 0
+	}
+	for _, sample := range samples {
+		mean += sample
+	}
+	n := float64(len(samples))
+	mean /= n
+	if len(samples) < 2 {
+		return mean, mean, mean
+	}
+	variance := 0.0
+	for _, sample := range samples {
+		variance += (sample - mean) * (sample - mean)
+	}
+	variance /= n - 1
+	margin := z95 * math.Sqrt(variance/n)
+	return mean, math.Max(0, mean-margin), mean + margin
+}
+
+// printCampaignSummary prints one block of statistics per grid cell.
+func printCampaignSummary(w io.Writer, summaries []campaignCellSummary) {
+	fmt.Fprintln(w, "\n--- Campaign Results ---")
+	fmt.Fprintln(w, aviation.EngagementCountNote)
+	for i, summary := range summaries {
+		fmt.Fprintf(w, "Cell %d: planes=%d altitudes=%s faulty=%.2f threshold=%.2f noncompliance=%.2f opposite=%.2f\n",
+			i+1, summary.Cell.Planes, altitudeMode(summary.Cell.DifferentAltitudes), summary.Cell.FaultyTCASRatio, summary.Cell.CollisionThreshold,
+			summary.Cell.PilotNonCompliance, summary.Cell.PilotOppositeResponse)
+		fmt.Fprintf(w, "  Runs: %d, Flights: %d, Flight Hours: %.2f\n", summary.Runs, summary.Flights, summary.FlightHours)
+		fmt.Fprintf(w, "  Engagements (per plane): %d (%.2f per run, 95%% CI %.2f-%.2f)\n",
+			summary.Engagements, summary.EngagementsPerRun, summary.EngagementsLow, summary.EngagementsHigh)
+		fmt.Fprintf(w, "  Averted Conflicts (per plane): %d\n", summary.Averted)
+		fmt.Fprintf(w, "  Crashes: %d\n", summary.Crashes)
+		fmt.Fprintf(w, "  Crashes per Flight Hour: %.4f (95%% CI %.4f-%.4f)\n",
+			summary.CrashesPerFlightHour, summary.CrashRateLow, summary.CrashRateHigh)
+		fmt.Fprintf(w, "  Runs with a Crash: %.1f%% (95%% CI %.1f%%-%.1f%%)\n",
+			summary.CrashRunRatio*100, summary.CrashRunLow*100, summary.CrashRunHigh*100)
+		fmt.Fprintln(w, "-------------------------------------------")
+	}
+}
+
+// writeCampaignCSV writes summary.csv (one row per grid cell) and runs.csv (one row per simulation) to dir.
+// The columns counted per plane are prefixed with plane_.
+func writeCampaignCSV(dir string, cells []campaignCell, summaries []campaignCellSummary, results []campaignRunResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create campaign directory: %w", err)
+	}
+
+	summaryRows := [][]string{{
+		"cell", "planes", "altitudes", "faulty_tcas_ratio", "collision_threshold", "pilot_noncompliance", "pilot_opposite_response", "runs", "flights", "flight_hours",
+		"plane_engagements", "plane_engagements_per_run", "plane_engagements_per_run_low", "plane_engagements_per_run_high", "plane_averted", "crashes",
+		"crashes_per_flight_hour", "crashes_per_flight_hour_low", "crashes_per_flight_hour_high",
+		"crash_run_ratio", "crash_run_ratio_low", "crash_run_ratio_high",
+	}}
+	for i, s := range summaries {
+		summaryRows = append(summaryRows, []string{
+			strconv.Itoa(i + 1), strconv.Itoa(s.Cell.Planes), altitudeMode(s.Cell.DifferentAltitudes),
+			formatFloat(s.Cell.FaultyTCASRatio), formatFloat(s.Cell.CollisionThreshold),
+			formatFloat(s.Cell.PilotNonCompliance), formatFloat(s.Cell.PilotOppositeResponse),
+			strconv.Itoa(s.Runs), strconv.Itoa(s.Flights), formatFloat(s.FlightHours),
+			strconv.Itoa(s.Engagements), formatFloat(s.EngagementsPerRun), formatFloat(s.EngagementsLow), formatFloat(s.EngagementsHigh),
+			strconv.Itoa(s.Averted), strconv.Itoa(s.Crashes),
+			formatFloat(s.CrashesPerFlightHour), formatFloat(s.CrashRateLow), formatFloat(s.CrashRateHigh),
+			formatFloat(s.CrashRunRatio), formatFloat(s.CrashRunLow), formatFloat(s.CrashRunHigh),
+		})
+	}
+
+	runRows := [][]string{{"cell", "run", "seed", "planes", "altitudes", "faulty_tcas_ratio", "collision_threshold",
+		"pilot_noncompliance", "pilot_opposite_response", "flights", "flight_hours", "plane_engagements", "plane_averted", "crashes"}}
+	for _, r := range results {
+		cell := cells[r.Cell]
+		runRows = append(runRows, []string{
+			strconv.Itoa(r.Cell + 1), strconv.Itoa(r.Run + 1), strconv.FormatInt(r.Seed, 10),
+			strconv.Itoa(cell.Planes), altitudeMode(cell.DifferentAltitudes),
+			formatFloat(cell.FaultyTCASRatio), formatFloat(cell.CollisionThreshold),
+			formatFloat(cell.PilotNonCompliance), formatFloat(cell.PilotOppositeResponse),
+			strconv.Itoa(r.Flights), formatFloat(r.FlightHours), strconv.Itoa(r.Engagements),
+			strconv.Itoa(r.Averted), strconv.Itoa(r.Crashes),
+		})
+	}
+
+	if err := writeCSVFile(filepath.Join(dir, "summary.csv"), summaryRows); err != nil {
+		return err
+	}
+	return writeCSVFile(filepath.Join(dir, "runs.csv"), runRows)
+}
+
+// writeCSVFile creates (or truncates) path and writes the rows to it.
+func writeCSVFile(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// altitudeMode names the cruising altitude setting the way the command-line flags do.
+func altitudeMode(differentAltitudes bool) string {
+	if differentAltitudes {
+		return "varied"
+	}
+	return "same"
+}
+
+// formatFloat formats a number for CSV output without losing precision.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+	"github.com/josephus-git/TCAS-simulation/internal/util"
+)
+
+// campaignUsage describes the flags accepted by the campaign command.
+const campaignUsage = "--runs 20 --planes 20,50 --altitudes same,varied --faulty 0.1,0.25 --threshold 5,10 --noncompliance 0,0.1 --opposite 0 --response-delay 5s --acceleration 0.25 --vertical-rate 1500 --crew-variation 0 --duration 30m --seed N --workers 4 [--out dir] [--logs]"
+
+// campaignCell is one point of the campaign's parameter grid.
+type campaignCell struct {
+	Planes                int
+	DifferentAltitudes    bool
+	FaultyTCASRatio       float64
+	CollisionThreshold    float64
+	PilotNonCompliance    float64
+	PilotOppositeResponse float64
+}
+
+// campaignCrew is how the crews of every simulation of a campaign respond to RAs, whatever the grid cell.
+type campaignCrew struct {
+	ResponseDelay time.Duration
+	Acceleration  float64 // g
+	VerticalRate  float64 // ft/min
+	Variation     float64
+}
+
+// campaignRunResult holds the safety figures of a single simulation of a campaign.
+type campaignRunResult struct {
+	Cell        int
+	Run         int
+	Seed        int64
+	Flights     int
+	FlightHours float64
+	Engagements int // TCAS engagements, per plane
+	Averted     int // RAs that ended without a collision, per plane
+	Crashes     int
+	Err         error
+}
+
+// campaignCommand runs a campaign from the REPL, refusing while a simulation is running.
+func campaignCommand(simState *aviation.SimulationState, arguments []string) {
+	if simState.SimIsRunning.Load() {
+		fmt.Println("A simulation is already running, type 'q' to stop it first")
+		return
+	}
+	if err := runCampaign(arguments); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// runCampaign runs N independent simulations for every cell of a parameter grid in parallel
+// and prints aggregated safety statistics per cell.
+func runCampaign(args []string) error {
+	flags := flag.NewFlagSet("campaign", flag.ContinueOnError)
+	runs := flags.Int("runs", 10, "number of simulations per grid cell")
+	planesList := flags.String("planes", "20", "comma separated plane counts")
+	altitudesList := flags.String("altitudes", "same", "comma separated cruising altitude modes: same, varied")
+	faultyList := flags.String("faulty", strconv.FormatFloat(aviation.DefaultFaultyTCASRatio, 'f', -1, 64), "comma separated faulty-TCAS ratios")
+	thresholdList := flags.String("threshold", strconv.FormatFloat(aviation.CollisionThreshold, 'f', -1, 64), "comma separated collision thresholds")
+	nonComplianceList := flags.String("noncompliance", "0", "comma separated probabilities that a crew ignores an RA")
+	oppositeList := flags.String("opposite", "0", "comma separated probabilities that a crew flies the opposite sense")
+	var crew campaignCrew
+	flags.DurationVar(&crew.ResponseDelay, "response-delay", aviation.StandardResponseDelay, "delay before a crew starts an initial RA maneuver")
+	flags.Float64Var(&crew.Acceleration, "acceleration", aviation.StandardAccelerationG, "vertical acceleration of an initial RA maneuver, in g")
+	flags.Float64Var(&crew.VerticalRate, "vertical-rate", aviation.StandardVerticalRateFpm, "vertical rate commanded by an initial RA, in ft/min")
+	flags.Float64Var(&crew.Variation, "crew-variation", 0, "spread of every crew's delay, acceleration and rate around the given ones (0 to 1), drawn from each run's seed")
+	duration := flags.Duration("duration", 30*time.Minute, "simulated duration of every run")
+	seed := flags.Int64("seed", 0, "master seed of the campaign (0 picks a random seed)")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of simulations run in parallel")
+	outDir := flags.String("out", "", "directory the campaign's CSV results are written to")
+	keepLogs := flags.Bool("logs", false, "keep the log files of every run under the output directory")
+	flags.SetOutput(os.Stdout)
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("usage: campaign %s", campaignUsage)
+	}
+
+	if *runs < 1 || *workers < 1 || *duration <= 0 {
+		return fmt.Errorf("campaign: --runs, --workers and --duration must be positive")
+	}
+	if crew.ResponseDelay <= 0 || crew.Acceleration <= 0 || crew.VerticalRate <= 0 || crew.Variation < 0 || crew.Variation >= 1 {
+		return fmt.Errorf("campaign: --response-delay, --acceleration and --vertical-rate must be positive and --crew-variation at least 0 and less than 1")
+	}
+	if *keepLogs && *outDir == "" {
+		return fmt.Errorf("campaign: --logs needs an --out directory")
+	}
+	cells, err := campaignGrid(*planesList, *altitudesList, *faultyList, *thresholdList, *nonComplianceList, *oppositeList)
+	if err != nil {
+		return err
+	}
+	if *seed == 0 {
+		*seed = util.NewSeed()
+	}
+
+	total := len(cells) * *runs
+	fmt.Printf("Campaign: %d grid cell(s) x %d run(s) = %d simulations of %v each, %d worker(s), seed %d\n",
+		len(cells), *runs, total, *duration, *workers, *seed)
+	fmt.Printf("Crews: %v, varying by up to %.0f%%\n",
+		aviation.DefaultPilotModel.WithResponse(crew.ResponseDelay, crew.Acceleration, crew.VerticalRate), 100*crew.Variation)
+
+	// the runs share the standard logger, so silence it while they run
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	type campaignJob struct {
+		cell, run int
+	}
+	jobs := make(chan campaignJob)
+	results := make(chan campaignRunResult)
+	var wg sync.WaitGroup
+	for range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				runSeed := util.NewRand(*seed, fmt.Sprintf("campaign/%d/%d", job.cell, job.run)).Int63()
+				logDir := os.DevNull
+				if *keepLogs {
+					logDir = filepath.Join(*outDir, "runs", fmt.Sprintf("cell%02d_run%03d", job.cell+1, job.run+1))
+				}
+				results <- runCampaignSimulation(cells[job.cell], crew, job.cell, job.run, runSeed, *duration, logDir)
+			}
+		}()
+	}
+	// a failed run ends the campaign: no job is handed out anymore and the runs still going are waited for
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer func() {
+			close(jobs)
+			wg.Wait()
+			close(results)
+		}()
+		for cell := range cells {
+			for run := range *runs {
+				select {
+				case jobs <- campaignJob{cell: cell, run: run}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	defer func() {
+		cancel()
+		for range results {
+		}
+	}()
+
+	runResults := make([]campaignRunResult, 0, total)
+	for result := range results {
+		if result.Err != nil {
+			return fmt.Errorf("campaign: run %d of cell %d failed: %w", result.Run+1, result.Cell+1, result.Err)
+		}
+		runResults = append(runResults, result)
+		if len(runResults)%max(1, total/10) == 0 || len(runResults) == total {
+			fmt.Printf("  %d/%d simulations done\n", len(runResults), total)
+		}
+	}
+
+	summaries := summarizeCampaign(cells, runResults)
+	printCampaignSummary(os.Stdout, summaries)
+	if *outDir != "" {
+		if err := writeCampaignCSV(*outDir, cells, summaries, runResults); err != nil {
+			return err
+		}
+		fmt.Printf("Campaign results written to %s\n", *outDir)
+	}
+	return nil
+}
+
+// runCampaignSimulation builds a fresh world for the grid cell and runs one quiet simulation as fast as possible.
+func runCampaignSimulation(cell campaignCell, crew campaignCrew, cellIndex, run int, seed int64, duration time.Duration, logDir string) campaignRunResult {
+	cfg := &config.Config{
+		NoOfAirplanes:         cell.Planes,
+		DifferentAltitudes:    cell.DifferentAltitudes,
+		Seed:                  seed,
+		FaultyTCASRatio:       cell.FaultyTCASRatio,
+		CollisionThreshold:    cell.CollisionThreshold,
+		PilotNonCompliance:    cell.PilotNonCompliance,
+		PilotOppositeResponse: cell.PilotOppositeResponse,
+		PilotResponseDelay:    crew.ResponseDelay,
+		PilotAcceleration:     crew.Acceleration,
+		PilotVerticalRate:     crew.VerticalRate,
+		PilotVariation:        crew.Variation,
+	}
+	simState := &aviation.SimulationState{
+		LogDir:   logDir,
+		Headless: true,
+		Quiet:    true,
+	}
+	aviation.InitializeAirports(cfg, simState)
+	if err := launchSimulation(simState, duration, aviation.MaxSpeed); err != nil {
+		return campaignRunResult{Cell: cellIndex, Run: run, Seed: seed, Err: err}
+	}
+
+	result := campaignRunResult{Cell: cellIndex, Run: run, Seed: seed}
+	for _, plane := range simState.AllPlanes() {
+		for _, flight := range plane.FlightLog {
+			result.Flights++
+			end := flight.ActualLandingTime
+			if end.IsZero() {
+				end = simState.SimEndedTime
+			}
+			if end.After(flight.TakeoffTime) {
+				result.FlightHours += end.Sub(flight.TakeoffTime).Hours()
+			}
+		}
+		// every plane records the engagements raised by its own TCAS, open or closed
+		for _, engagement := range append(plane.TCASEngagementRecords, plane.CurrentTCASEngagements...) {
+			result.Engagements++
+			if engagement.Advisory == aviation.AdvisoryRA && !engagement.Collided {
+				result.Averted++
+			}
+		}
+	}
+	// a collision is reported once per pair of planes, but count it once even if reported again before the stop
+	crashes := map[[2]string]bool{}
+	for _, engagement := range simState.Collisions {
+		crashes[[2]string{min(engagement.PlaneSerial, engagement.OtherPlaneSerial), max(engagement.PlaneSerial, engagement.OtherPlaneSerial)}] = true
+	}
+	result.Crashes = len(crashes)
+	return result
+}
+
+// campaignGrid builds the cartesian product of the comma separated parameter lists.
+func campaignGrid(planesList, altitudesList, faultyList, thresholdList, nonComplianceList, oppositeList string) ([]campaignCell, error) {
+	planes := []int{}
+	for _, field := range strings.Split(planesList, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 2 {
+			return nil, fmt.Errorf("campaign: invalid plane count %q, plane counts must be integers greater than 1", field)
+		}
+		planes = append(planes, n)
+	}
+	altitudes := []bool{}
+	for _, field := range strings.Split(altitudesList, ",") {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "same":
+			altitudes = append(altitudes, false)
+		case "varied":
+			altitudes = append(altitudes, true)
+		default:
+			return nil, fmt.Errorf("campaign: invalid altitude mode %q, use same or varied", field)
+		}
+	}
+	faulty, err := parseFloatList(faultyList, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("campaign: invalid faulty-TCAS ratio: %w", err)
+	}
+	thresholds, err := parseFloatList(thresholdList, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("campaign: invalid collision threshold: %w", err)
+	}
+	for _, threshold := range thresholds {
+		// a threshold of 0 would be simulated as the default one while being reported as 0
+		if threshold <= 0 {
+			return nil, fmt.Errorf("campaign: invalid collision threshold: %v must be greater than 0", threshold)
+		}
+	}
+
+	nonCompliance, err := parseFloatList(nonComplianceList, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("campaign: invalid non-compliance probability: %w", err)
+	}
+	opposite, err := parseFloatList(oppositeList, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("campaign: invalid opposite response probability: %w", err)
+	}
+
+	cells := []campaignCell{}
+	for _, n := range planes {
+		for _, differentAltitudes := range altitudes {
+			for _, ratio := range faulty {
+				for _, threshold := range thresholds {
+					for _, ignore := range nonCompliance {
+						for _, reverse := range opposite {
+							if ignore+reverse > 1 {
+								return nil, fmt.Errorf("campaign: non-compliance %v and opposite response %v add up to more than 1", ignore, reverse)
+							}
+							cells = append(cells, campaignCell{
+								Planes:                n,
+								DifferentAltitudes:    differentAltitudes,
+								FaultyTCASRatio:       ratio,
+								CollisionThreshold:    threshold,
+								PilotNonCompliance:    ignore,
+								PilotOppositeResponse: reverse,
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return cells, nil
+}
+
+// parseFloatList parses comma separated non-negative numbers, capped at upper unless upper is 0.
+func parseFloatList(list string, lower, upper float64) ([]float64, error) {
+	values := []float64{}
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || value < lower || (upper > 0 && value > upper) {
+			return nil, fmt.Errorf("%q is out of range", field)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
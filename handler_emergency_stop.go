@@ -7,19 +7,17 @@ import (
 )
 
 // emergencyStop immediately halts the simulation, canceling all active goroutines and resetting the simulation state.
-func emergencyStop(simState *aviation.SimulationState) {
-	if simulationCancelFunc != nil {
-		log.Println("\n--- EMERGENCY STOP ACTIVATED! Signaling all goroutines to stop... ---")
-		simulationCancelFunc() // Trigger cancellation
-		// Reset the cancel func to indicate no active simulation,
-		// and prevent multiple calls to a potentially nil context if Start() finished.
-		simState.SimIsRunning = false
-		<-simState.SimStatusChannel
-		if stopTrigger.Stop() {
-		}
-		simulationCancelFunc = nil
-
-	} else {
+// It returns once the run has ended, and reports whether there was a run to stop.
+func emergencyStop(simState *aviation.SimulationState) bool {
+	cancel, stopTrigger := simState.EndRun()
+	if cancel == nil {
 		log.Println("EmergencyStop: Simulation not running")
+		return false
 	}
+	log.Println("\n--- EMERGENCY STOP ACTIVATED! Signaling all goroutines to stop... ---")
+	cancel() // Trigger cancellation, ending the clock so that goroutines sleeping on it wake up even if it is paused
+	simState.SimIsRunning.Store(false)
+	<-simState.SimStatusChannel
+	stopTrigger.Stop()
+	return true
 }
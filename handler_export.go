@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// exportUsage describes the arguments accepted by the export command.
+const exportUsage = "usage: export csv <dir> (writes airports.csv, planes.csv, flights.csv and engagements.csv to dir)\n" +
+	"       export kml <file> | export geojson <file> (writes airports, flight tracks and TCAS engagements for GIS tools)"
+
+// exportData writes the simulation's data in a machine-readable format to the location given as argument.
+func exportData(simState *aviation.SimulationState, arguments []string) {
+	if len(arguments) != 2 {
+		fmt.Println(exportUsage)
+		return
+	}
+	if len(simState.Airports) == 0 {
+		fmt.Println("There is no simulation to export yet")
+		return
+	}
+	switch strings.ToLower(arguments[0]) {
+	case "csv":
+		if err := simState.ExportCSV(arguments[1]); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Exported airports, planes, flights and engagements as CSV to %s\n", arguments[1])
+	case "kml":
+		exportMap(arguments[1], "KML", simState.ExportKML)
+	case "geojson":
+		exportMap(arguments[1], "GeoJSON", simState.ExportGeoJSON)
+	default:
+		fmt.Println(exportUsage)
+	}
+}
+
+// exportMap writes the airports, flight tracks and TCAS engagements to the given file with the given exporter.
+func exportMap(path, format string, export func(io.Writer) error) {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("failed to create %s: %v\n", path, err)
+		return
+	}
+	defer f.Close()
+	if err := export(f); err != nil {
+		fmt.Printf("failed to write %s: %v\n", path, err)
+		return
+	}
+	fmt.Printf("Exported airports, flight tracks and TCAS engagements as %s to %s\n", format, path)
+}
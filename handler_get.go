@@ -2,15 +2,24 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/josephus-git/TCAS-simulation/internal/aviation"
 )
 
-// getDetails displays specific simulation details (airports, airplanes, or flights) based on the provided argument.
-// It prints usage instructions if an invalid option is given.
-func getDetails(simState *aviation.SimulationState, argument2 string) {
+// getUsage describes the arguments accepted by the get command.
+const getUsage = "usage: get <option>, options: airports, airplanes, flights, all, track <flightID>"
+
+// getDetails displays specific simulation details (airports, airplanes, flights or a flight's track)
+// based on the provided arguments. It prints usage instructions if an invalid option is given.
+func getDetails(simState *aviation.SimulationState, arguments []string) {
+	argument2 := ""
+	if len(arguments) > 0 {
+		argument2 = strings.ToLower(arguments[0])
+	}
 	switch argument2 {
 	case "airports":
 		getAirportDetails(simState)
@@ -22,16 +31,62 @@ func getDetails(simState *aviation.SimulationState, argument2 string) {
 		getAirportDetails(simState)
 		getAirPlanesDetails(simState)
 		getFlightDetails(simState)
+	case "track":
+		if len(arguments) != 2 {
+			fmt.Println(getUsage)
+			return
+		}
+		getTrack(simState, arguments[1])
 	default:
-		fmt.Println("usage: get <option>, options: airports, airplanes, flights, all")
+		fmt.Println(getUsage)
+	}
+}
+
+// getTrack prints the track recorded by a flight: where the plane was, how fast it was moving
+// and which advisory was active on board at every sample.
+func getTrack(simState *aviation.SimulationState, flightID string) {
+	flight, plane, ok := simState.FindFlight(flightID)
+	if !ok {
+		fmt.Printf("No flight %s recorded, use 'get flights' to list them\n", flightID)
+		return
+	}
+
+	fmt.Printf("\n--- Track of flight %s (Plane %s from %s to %s), %d samples ---\n",
+		flight.FlightID, plane, flight.DepatureAirPort, flight.ArrivalAirPort, len(flight.Track))
+	fmt.Printf("  %-19s  %-32s  %-14s  %-12s  %-13s  %s\n", "Time", "Location", "Altitude", "Ground Speed", "Vertical Rate", "Advisory")
+	for _, point := range flight.Track {
+		advisory := "-"
+		switch point.Advisory {
+		case aviation.AdvisoryTA:
+			advisory = "TA"
+		case aviation.AdvisoryRA:
+			advisory = "RA " + point.Sense.String()
+		}
+		fmt.Printf("  %-19s  %-32s  %-14s  %-12s  %-13s  %s\n",
+			point.Time.Format(aviation.LogTimeFormat),
+			describeGroundPosition(simState, point.Position),
+			simState.DescribeAltitude(point.Position.Z),
+			fmt.Sprintf("%.0f m/s", math.Hypot(point.Velocity.X, point.Velocity.Y)),
+			fmt.Sprintf("%+.1f m/s", point.Velocity.Z),
+			advisory)
+	}
+	fmt.Println()
+}
+
+// describeGroundPosition prints where a plane is over the ground, as latitude and longitude in geodetic mode.
+func describeGroundPosition(simState *aviation.SimulationState, position aviation.Coordinate) string {
+	if simState.Frame == nil {
+		return fmt.Sprintf("(%.0f, %.0f)", position.X, position.Y)
 	}
+	ground := simState.Frame.FromMap(position)
+	return fmt.Sprintf("(%.5f°, %.5f°)", ground.Latitude, ground.Longitude)
 }
 
 // getAirPlanesDetails prints selected details of all flights logged in all various planes
 func getFlightDetails(simState *aviation.SimulationState) {
 	var simTime time.Time
-	if simState.SimIsRunning {
-		simTime = time.Now()
+	if simState.SimIsRunning.Load() {
+		simTime = simState.Clock.Now()
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -61,7 +116,7 @@ func getFlightDetails(simState *aviation.SimulationState) {
 	})
 	for i, flight := range flightLogs {
 		fmt.Printf("\nflight %d:\n", i)
-		printFlightDetails(flight, simTime)
+		printFlightDetails(simState, flight, simTime)
 	}
 	fmt.Println()
 }
@@ -69,8 +124,8 @@ func getFlightDetails(simState *aviation.SimulationState) {
 // getAirPlanesDetails prints selected details of all airplanes from the simulation state to the console.
 func getAirPlanesDetails(simState *aviation.SimulationState) {
 	var simTime time.Time
-	if simState.SimIsRunning {
-		simTime = time.Now()
+	if simState.SimIsRunning.Load() {
+		simTime = simState.Clock.Now()
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -89,20 +144,27 @@ func getAirPlanesDetails(simState *aviation.SimulationState) {
 	for i, plane := range Planes {
 		fmt.Printf("Plane %d (Serial: %s):\n", i+1, plane.Serial)
 		fmt.Printf("  In Flight: %t\n", plane.PlaneInFlight)
+		fmt.Printf("  Aircraft Type: %s (%s), wake category %s, service ceiling %s, approach speed %.2f m/s\n", plane.AircraftType.Designator,
+			plane.AircraftType.Name, plane.AircraftType.WakeCategory, simState.DescribeAltitude(plane.AircraftType.ServiceCeiling), plane.AircraftType.ApproachSpeed)
 		fmt.Printf("  Cruise Speed: %.2f m/s\n", plane.CruiseSpeed)
 		fmt.Printf("  TCAS Capability: %s\n", func(capability aviation.TCASCapability) string {
-			if capability == 1 {
+			switch capability {
+			case aviation.TCASPerfect:
 				return "Working Perfectly"
-			} else {
+			case aviation.TCASFaulty:
 				return "Faulty"
+			default:
+				return "Not Fitted"
 			}
 		}(plane.TCASCapability))
+		fmt.Printf("  Pilot: %v, non-compliance %.0f%%, opposite response %.0f%%\n",
+			plane.Pilot, plane.Pilot.NonComplianceProbability*100, plane.Pilot.OppositeResponseProbability*100)
 		fmt.Println("  Flight Log:")
 		if len(plane.FlightLog) == 0 {
 			fmt.Println("    No flights recorded for this plane.")
 		} else {
 			for _, flight := range plane.FlightLog { // Looping to count flights, but not printing content if 'flight' is empty
-				printFlightDetails(flight, simTime)
+				printFlightDetails(simState, flight, simTime)
 			}
 		}
 		if len(plane.TCASEngagementRecords) == 0 {
@@ -117,7 +179,7 @@ func getAirPlanesDetails(simState *aviation.SimulationState) {
 			fmt.Println("    No Expected TCAS engagement recorded for this plane.")
 		} else {
 			for _, engagement := range plane.CurrentTCASEngagements {
-				fmt.Println("    --- Expected Engagement Details ---")
+				fmt.Println("    --- Open Engagement Details ---")
 				printEngagementDetails(engagement)
 			}
 		}
@@ -131,7 +193,7 @@ func getAirportDetails(simState *aviation.SimulationState) {
 	fmt.Println("\n--- Printing selected fields for all airports ---")
 	for i, airport := range simState.Airports {
 		fmt.Printf("Airport %d (Serial: %s):\n", i+1, airport.Serial)
-		fmt.Printf("  Location: %v\n", airport.Location)
+		fmt.Printf("  Location: %s\n", simState.DescribeLocation(airport.Location))
 		fmt.Printf("  Runway: %v\n", airport.Runway)
 		fmt.Println("  Planes:")
 		if len(airport.Planes) == 0 {
@@ -147,12 +209,15 @@ func getAirportDetails(simState *aviation.SimulationState) {
 }
 
 // getFlightDetails prints all details for a given Flight struct,
-func printFlightDetails(flight aviation.Flight, simTime time.Time) {
+func printFlightDetails(simState *aviation.SimulationState, flight aviation.Flight, simTime time.Time) {
 	fmt.Println("    --- Flight Details ---")
 	fmt.Printf("    Flight ID: %s\n", flight.FlightID)
 	fmt.Printf("    Takeoff Time: %s\n", flight.TakeoffTime.Format("15:04:05"))
 	fmt.Printf("    Destination Arrival Time: %s\n", flight.DestinationArrivalTime.Format("15:04:05"))
-	fmt.Printf("    Cruising Altitude: %.2f meters\n", flight.CruisingAltitude)
+	fmt.Printf("    Cruising Altitude: %s\n", simState.DescribeAltitude(flight.CruisingAltitude))
+	fmt.Printf("    Route Distance: %s\n", simState.DescribeDistance(simState.RouteDistance(flight.FlightSchedule)))
+	fmt.Printf("    Top Of Climb: %s at %s\n", flight.TopOfClimb().Time.Format("15:04:05"), simState.DescribeAltitude(flight.TopOfClimb().Position.Z))
+	fmt.Printf("    Top Of Descent: %s at %s\n", flight.TopOfDescent().Time.Format("15:04:05"), simState.DescribeAltitude(flight.TopOfDescent().Position.Z))
 	fmt.Printf("    Depature Airport: %s\n", flight.DepatureAirPort)
 	fmt.Printf("    Destination Airport: %s\n", flight.ArrivalAirPort)
 	var actualLandingTime string
@@ -178,11 +243,21 @@ func printEngagementDetails(engagement aviation.TCASEngagement) {
 	fmt.Printf("    Plane Serial: %s\n", engagement.PlaneSerial)
 	fmt.Printf("    Other Plane Serial: %s\n", engagement.OtherPlaneSerial)
 	fmt.Printf("    Time Of Engagement: %s\n", engagement.TimeOfEngagement.Format("15:04:05"))
-	fmt.Printf("    Will Crash: %s\n", func(willCrash bool) string {
-		if engagement.WillCrash {
+	fmt.Printf("    Advisory: %s (sensitivity level %d)\n", engagement.Advisory, engagement.SensitivityLevel)
+	fmt.Printf("    Traffic Advisory At: %s\n", engagement.TATime.Format("15:04:05"))
+	if engagement.Advisory == aviation.AdvisoryRA {
+		fmt.Printf("    Resolution Advisory At: %s\n", engagement.RATime.Format("15:04:05"))
+		fmt.Printf("    Sense: %s (coordinated: %t)\n", engagement.Sense, engagement.Coordinated)
+		fmt.Printf("    Crew Response: %s\n", engagement.PilotResponse)
+		if !engagement.StrengthenedTime.IsZero() {
+			fmt.Printf("    Strengthened At: %s\n", engagement.StrengthenedTime.Format("15:04:05"))
+		}
+	}
+	fmt.Printf("    Collided: %s\n", func(collided bool) string {
+		if collided {
 			return "yes"
 		} else {
 			return "no"
 		}
-	}(engagement.WillCrash))
+	}(engagement.Collided))
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+)
+
+// loadUsage describes the arguments accepted by the load command.
+const loadUsage = "usage: load <scenario file> (a JSON file declaring airports, planes and a timetable of flights)"
+
+// loadScenario replaces the current world with the one declared by the scenario file given as argument.
+// The scenario's own seed is used, a seed given to start afterwards replays it with other crew responses.
+func loadScenario(cfg *config.Config, simState *aviation.SimulationState, arguments []string) {
+	if len(arguments) != 1 {
+		fmt.Println(loadUsage)
+		return
+	}
+	if simState.SimIsRunning.Load() {
+		fmt.Println("A simulation is already running, type 'q' to stop it first")
+		return
+	}
+	scenario, err := aviation.LoadScenario(arguments[0])
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cfg.Seed = 0
+	aviation.InitializeScenario(cfg, simState, scenario)
+}
@@ -31,7 +31,7 @@ func logDetails(simState *aviation.SimulationState, argument2 string) {
 
 // getAirPlanesDetails prints selected details of all flights logged in all various planes
 func logFlightDetailsToFile(simState *aviation.SimulationState) {
-	logFilePath := "logs/flightDetails.txt"
+	logFilePath := simState.LogPath("flightDetails.txt")
 	// Open the file in append mode. Create it if it doesn't exist.
 	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -40,8 +40,8 @@ func logFlightDetailsToFile(simState *aviation.SimulationState) {
 	defer f.Close()
 
 	var simTime time.Time
-	if simState.SimIsRunning {
-		simTime = time.Now()
+	if simState.SimIsRunning.Load() {
+		simTime = simState.Clock.Now()
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -71,7 +71,7 @@ func logFlightDetailsToFile(simState *aviation.SimulationState) {
 	})
 	for i, flight := range flightLogs {
 		fmt.Fprintf(f, "\nflightLog %d:\n", i)
-		logFlightDetails(flight, simTime, f)
+		logFlightDetails(simState, flight, simTime, f)
 	}
 	fmt.Println("successfully logged all flights")
 }
@@ -79,7 +79,7 @@ func logFlightDetailsToFile(simState *aviation.SimulationState) {
 // logAirplanesDetails appends selected details of all airplanes from the simulation state to a log file.
 // It includes serial, flight status, cruise speed, and a count of flights for each plane.
 func logAirplanesDetails(simState *aviation.SimulationState) {
-	logFilePath := "logs/airplaneDetails.txt"
+	logFilePath := simState.LogPath("airplaneDetails.txt")
 	// Open the file in append mode. Create it if it doesn't exist.
 	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -88,8 +88,8 @@ func logAirplanesDetails(simState *aviation.SimulationState) {
 	defer f.Close()
 
 	var simTime time.Time
-	if simState.SimIsRunning {
-		simTime = time.Now()
+	if simState.SimIsRunning.Load() {
+		simTime = simState.Clock.Now()
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -108,20 +108,27 @@ func logAirplanesDetails(simState *aviation.SimulationState) {
 	for i, plane := range Planes {
 		fmt.Fprintf(f, "Plane %d (Serial: %s):\n", i+1, plane.Serial)
 		fmt.Fprintf(f, "  In Flight: %t\n", plane.PlaneInFlight)
+		fmt.Fprintf(f, "  Aircraft Type: %s (%s), wake category %s, service ceiling %s, approach speed %.2f m/s\n", plane.AircraftType.Designator,
+			plane.AircraftType.Name, plane.AircraftType.WakeCategory, simState.DescribeAltitude(plane.AircraftType.ServiceCeiling), plane.AircraftType.ApproachSpeed)
 		fmt.Fprintf(f, "  Cruise Speed: %.2f m/s\n", plane.CruiseSpeed)
 		fmt.Fprintf(f, "  TCAS Capability: %s\n", func(capability aviation.TCASCapability) string {
-			if capability == 1 {
+			switch capability {
+			case aviation.TCASPerfect:
 				return "Working Perfectly"
-			} else {
+			case aviation.TCASFaulty:
 				return "Faulty"
+			default:
+				return "Not Fitted"
 			}
 		}(plane.TCASCapability))
+		fmt.Fprintf(f, "  Pilot: %v, non-compliance %.0f%%, opposite response %.0f%%\n",
+			plane.Pilot, plane.Pilot.NonComplianceProbability*100, plane.Pilot.OppositeResponseProbability*100)
 		fmt.Fprintln(f, "  Flight Log:")
 		if len(plane.FlightLog) == 0 {
 			fmt.Fprintln(f, "    No flights recorded for this plane.")
 		} else {
 			for _, flight := range plane.FlightLog { // Looping to count flights, but not printing content if 'flight' is empty
-				logFlightDetails(flight, simTime, f)
+				logFlightDetails(simState, flight, simTime, f)
 			}
 		}
 		if len(plane.TCASEngagementRecords) == 0 {
@@ -147,7 +154,7 @@ func logAirplanesDetails(simState *aviation.SimulationState) {
 // logAirportDetails appends selected details of all airports from the simulation state to a log file.
 // It includes serial, location, plane capacity, runway information, and a list of associated plane serials.
 func logAirportDetails(simState *aviation.SimulationState) {
-	logFilePath := "logs/airportDetails.txt"
+	logFilePath := simState.LogPath("airportDetails.txt")
 	// Open the file in append mode. Create it if it doesn't exist.
 	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -157,7 +164,7 @@ func logAirportDetails(simState *aviation.SimulationState) {
 	fmt.Fprintln(f, "\n--- Logging selected fields for each airport ---")
 	for i, ap := range simState.Airports {
 		fmt.Fprintf(f, "Airport %d (Serial: %s):\n", i+1, ap.Serial)
-		fmt.Fprintf(f, "  Location: %v\n", ap.Location)
+		fmt.Fprintf(f, "  Location: %s\n", simState.DescribeLocation(ap.Location))
 		fmt.Fprintf(f, "  Runway: %v\n", ap.Runway)
 		fmt.Fprintln(f, "  Planes:")
 		if len(ap.Planes) == 0 {
@@ -173,19 +180,22 @@ func logAirportDetails(simState *aviation.SimulationState) {
 }
 
 // getFlightDetails logs all details for a given Flight struct,
-func logFlightDetails(flight aviation.Flight, simTime time.Time, f *os.File) {
+func logFlightDetails(simState *aviation.SimulationState, flight aviation.Flight, simTime time.Time, f *os.File) {
 	fmt.Fprintln(f, "    --- Flight Details ---")
 	fmt.Fprintf(f, "    Flight ID: %s\n", flight.FlightID)
-	fmt.Fprintf(f, "    Takeoff Time: %s\n", flight.TakeoffTime.Format("15:04:05"))
-	fmt.Fprintf(f, "    Destination Arrival Time: %s\n", flight.DestinationArrivalTime.Format("15:04:05"))
-	fmt.Fprintf(f, "    Cruising Altitude: %.2f meters\n", flight.CruisingAltitude)
+	fmt.Fprintf(f, "    Takeoff Time: %s\n", flight.TakeoffTime.Format(aviation.LogTimeFormat))
+	fmt.Fprintf(f, "    Destination Arrival Time: %s\n", flight.DestinationArrivalTime.Format(aviation.LogTimeFormat))
+	fmt.Fprintf(f, "    Cruising Altitude: %s\n", simState.DescribeAltitude(flight.CruisingAltitude))
+	fmt.Fprintf(f, "    Route Distance: %s\n", simState.DescribeDistance(simState.RouteDistance(flight.FlightSchedule)))
+	fmt.Fprintf(f, "    Top Of Climb: %s at %s\n", flight.TopOfClimb().Time.Format(aviation.LogTimeFormat), simState.DescribeAltitude(flight.TopOfClimb().Position.Z))
+	fmt.Fprintf(f, "    Top Of Descent: %s at %s\n", flight.TopOfDescent().Time.Format(aviation.LogTimeFormat), simState.DescribeAltitude(flight.TopOfDescent().Position.Z))
 	fmt.Fprintf(f, "    Depature Airport: %s\n", flight.DepatureAirPort)
 	fmt.Fprintf(f, "    Destination Airport: %s\n", flight.ArrivalAirPort)
 	var actualLandingTime string
 	if flight.ActualLandingTime.IsZero() {
 		actualLandingTime = "Plane is yet to land"
 	} else {
-		actualLandingTime = flight.ActualLandingTime.Format("15:04:05")
+		actualLandingTime = flight.ActualLandingTime.Format(aviation.LogTimeFormat)
 	}
 	fmt.Fprintf(f, "    Actual Landing Time: %s\n", actualLandingTime)
 
@@ -203,12 +213,22 @@ func logEngagementDetails(engagement aviation.TCASEngagement, f *os.File) {
 	fmt.Fprintf(f, "    Flight ID: %s\n", engagement.FlightID)
 	fmt.Fprintf(f, "    Plane Serial: %s\n", engagement.PlaneSerial)
 	fmt.Fprintf(f, "    Other Plane Serial: %s\n", engagement.OtherPlaneSerial)
-	fmt.Fprintf(f, "    Time Of Engagement: %s\n", engagement.TimeOfEngagement.Format("15:04:05"))
-	fmt.Fprintf(f, "    Will Crash: %s\n", func(willCrash bool) string {
-		if engagement.WillCrash {
+	fmt.Fprintf(f, "    Time Of Engagement: %s\n", engagement.TimeOfEngagement.Format(aviation.LogTimeFormat))
+	fmt.Fprintf(f, "    Advisory: %s (sensitivity level %d)\n", engagement.Advisory, engagement.SensitivityLevel)
+	fmt.Fprintf(f, "    Traffic Advisory At: %s\n", engagement.TATime.Format(aviation.LogTimeFormat))
+	if engagement.Advisory == aviation.AdvisoryRA {
+		fmt.Fprintf(f, "    Resolution Advisory At: %s\n", engagement.RATime.Format(aviation.LogTimeFormat))
+		fmt.Fprintf(f, "    Sense: %s (coordinated: %t)\n", engagement.Sense, engagement.Coordinated)
+		fmt.Fprintf(f, "    Crew Response: %s\n", engagement.PilotResponse)
+		if !engagement.StrengthenedTime.IsZero() {
+			fmt.Fprintf(f, "    Strengthened At: %s\n", engagement.StrengthenedTime.Format(aviation.LogTimeFormat))
+		}
+	}
+	fmt.Fprintf(f, "    Collided: %s\n", func(collided bool) string {
+		if collided {
 			return "yes"
 		} else {
 			return "no"
 		}
-	}(engagement.WillCrash))
+	}(engagement.Collided))
 }
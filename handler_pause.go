@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// stepUsage describes the arguments accepted by the step command.
+const stepUsage = "usage: step <duration> (simulated time to advance the paused simulation by, e.g. 30s, 2m or 1h)"
+
+// pauseSimulation freezes the running simulation in simulation time: airports stop launching planes,
+// takeoffs, landings and flights stand still until the simulation is resumed or stepped.
+func pauseSimulation(simState *aviation.SimulationState) {
+	clock := runningClock(simState)
+	if clock == nil {
+		return
+	}
+	if clock.Paused() {
+		fmt.Println("The simulation is already paused, type 'resume' to continue it")
+		return
+	}
+	clock.Pause()
+	log.Printf("Simulation paused at %s, %d plane(s) in flight",
+		clock.Now().Format(aviation.LogTimeFormat), planesInFlight(simState))
+}
+
+// resumeSimulation lets a paused simulation run on from where it was paused.
+func resumeSimulation(simState *aviation.SimulationState) {
+	clock := runningClock(simState)
+	if clock == nil {
+		return
+	}
+	if !clock.Paused() {
+		fmt.Println("The simulation is not paused")
+		return
+	}
+	clock.Resume()
+	log.Printf("Simulation resumed at %s, clock running at %s",
+		clock.Now().Format(aviation.LogTimeFormat), aviation.FormatClockSpeed(clock.Speed()))
+}
+
+// stepSimulation advances a paused simulation by the given duration of simulation time and pauses it again.
+func stepSimulation(simState *aviation.SimulationState, arguments []string) {
+	if len(arguments) != 1 {
+		fmt.Println(stepUsage)
+		return
+	}
+	d, err := time.ParseDuration(arguments[0])
+	if err != nil || d <= 0 {
+		fmt.Println(stepUsage)
+		return
+	}
+	clock := runningClock(simState)
+	if clock == nil {
+		return
+	}
+	if !clock.Paused() {
+		fmt.Println("The simulation is running, type 'pause' before stepping it")
+		return
+	}
+	if err := clock.Step(d); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if !simState.SimIsRunning.Load() {
+		fmt.Println("The simulation ended during the step")
+		return
+	}
+	log.Printf("Simulation stepped by %v to %s, %d plane(s) in flight",
+		d, clock.Now().Format(aviation.LogTimeFormat), planesInFlight(simState))
+}
+
+// runningClock returns the clock of the running simulation, or prints why there is none and returns nil.
+func runningClock(simState *aviation.SimulationState) *aviation.SimClock {
+	clock, ok := simState.Clock.(*aviation.SimClock)
+	if !simState.SimIsRunning.Load() || !ok {
+		fmt.Println("No simulation is running, type 'start' to run one")
+		return nil
+	}
+	return clock
+}
+
+// planesInFlight returns the number of planes currently in flight.
+func planesInFlight(simState *aviation.SimulationState) int {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+	return len(simState.PlanesInFlight)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// radarRefreshInterval is how often, in wall-clock time, the radar display is redrawn.
+const radarRefreshInterval = time.Second
+
+// radarVectorLength is how far ahead the velocity vector of a plane on the radar reaches.
+const radarVectorLength = 60 * time.Second
+
+// radarWidth and radarHeight are the size of the radar scope in terminal characters.
+const (
+	radarWidth  = 100
+	radarHeight = 32
+)
+
+// ANSI colors of the radar display.
+const (
+	colorReset   = "\033[0m"
+	colorAirport = "\033[36m"   // cyan
+	colorPlane   = "\033[32m"   // green
+	colorTA      = "\033[33;1m" // bold yellow
+	colorRA      = "\033[31;1m" // bold red
+)
+
+// radarCell is a character of the radar scope.
+type radarCell struct {
+	char  rune
+	color string
+}
+
+// startRadar shows the live radar display, redrawn every radarRefreshInterval until the next input line
+// closes it through the simulation state.
+func startRadar(simState *aviation.SimulationState) {
+	stop, done := simState.StartRadar()
+	color := os.Getenv("NO_COLOR") == ""
+
+	ticker := time.NewTicker(radarRefreshInterval)
+	go func() {
+		defer ticker.Stop()
+		drawRadar(os.Stdout, ticker.C, stop, done, func() string {
+			return renderRadar(simState, radarWidth, radarHeight, color)
+		})
+	}()
+}
+
+// drawRadar writes a frame rendered by render to w on every tick until stop is closed, then closes done.
+// Stop is checked right before each frame, so that none is drawn once it is closed even when a tick is
+// ready at the same time.
+func drawRadar(w io.Writer, ticks <-chan time.Time, stop <-chan struct{}, done chan<- struct{}, render func() string) {
+	defer close(done)
+	for {
+		frame := render()
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		// clear the screen and draw from its top-left corner
+		fmt.Fprint(w, "\033[H\033[2J"+frame+"Press Enter to close the radar > ")
+		select {
+		case <-ticks:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// renderRadar draws a top-down view of the airspace: airports, planes in flight tagged with their serial
+// and flight level, and the distance each plane covers in radarVectorLength. Planes with a Traffic
+// Advisory active, or that are the intruder of one, are highlighted in yellow and those with a
+// Resolution Advisory in red.
+func renderRadar(simState *aviation.SimulationState, width, height int, color bool) string {
+	now := time.Time{}
+	if simState.Clock != nil {
+		now = simState.Clock.Now()
+	}
+	simState.Mu.Lock()
+	planes := make([]aviation.Plane, 0, len(simState.PlanesInFlight))
+	for _, plane := range simState.PlanesInFlight {
+		planes = append(planes, plane.Snapshot())
+	}
+	simState.Mu.Unlock()
+	sort.Slice(planes, func(i, j int) bool { return planes[i].Serial < planes[j].Serial })
+
+	// the highest advisory each plane is involved in, as own plane or as intruder
+	advisories := map[string]aviation.AdvisoryType{}
+	for _, plane := range planes {
+		for _, engagement := range plane.CurrentTCASEngagements {
+			for _, serial := range []string{plane.Serial, engagement.OtherPlaneSerial} {
+				if engagement.Advisory > advisories[serial] {
+					advisories[serial] = engagement.Advisory
+				}
+			}
+		}
+	}
+
+	positions := make([]aviation.Coordinate, len(planes))
+	vectors := make([]aviation.Coordinate, len(planes))
+	points := []aviation.Coordinate{}
+	for _, airport := range simState.Airports {
+		points = append(points, airport.Location)
+	}
+	for i, plane := range planes {
+		positions[i] = plane.Position(now)
+		velocity := plane.VelocityAt(now)
+		vectors[i] = aviation.Coordinate{
+			X: positions[i].X + velocity.X*radarVectorLength.Seconds(),
+			Y: positions[i].Y + velocity.Y*radarVectorLength.Seconds(),
+		}
+		points = append(points, positions[i])
+	}
+
+	// fit everything in the scope, a character being about twice as tall as it is wide
+	minX, maxX, minY, maxY := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		minX, maxX = math.Min(minX, point.X), math.Max(maxX, point.X)
+		minY, maxY = math.Min(minY, point.Y), math.Max(maxY, point.Y)
+	}
+	if len(points) == 0 {
+		minX, maxX, minY, maxY = 0, 0, 0, 0
+	}
+	metersPerColumn := math.Max((maxX-minX)/float64(width-2), (maxY-minY)/float64(2*(height-2)))
+	if metersPerColumn <= 0 {
+		metersPerColumn = 1000
+	}
+	centerX, centerY := (minX+maxX)/2, (minY+maxY)/2
+	cell := func(c aviation.Coordinate) (int, int) {
+		column := width/2 + int(math.Round((c.X-centerX)/metersPerColumn))
+		row := height/2 - int(math.Round((c.Y-centerY)/(2*metersPerColumn)))
+		return column, row
+	}
+
+	grid := make([][]radarCell, height)
+	for row := range grid {
+		grid[row] = make([]radarCell, width)
+		for column := range grid[row] {
+			grid[row][column] = radarCell{char: ' '}
+		}
+	}
+	put := func(column, row int, char rune, cellColor string, overwrite bool) {
+		if row < 0 || row >= height || column < 0 || column >= width {
+			return
+		}
+		if !overwrite && grid[row][column].char != ' ' && grid[row][column].char != '.' {
+			return
+		}
+		grid[row][column] = radarCell{char: char, color: cellColor}
+	}
+
+	// velocity vectors first, so that airports, planes and tags are drawn over them
+	for i := range planes {
+		fromColumn, fromRow := cell(positions[i])
+		toColumn, toRow := cell(vectors[i])
+		steps := max(abs(toColumn-fromColumn), abs(toRow-fromRow))
+		for step := 1; step <= steps; step++ {
+			fraction := float64(step) / float64(steps)
+			put(fromColumn+int(math.Round(fraction*float64(toColumn-fromColumn))),
+				fromRow+int(math.Round(fraction*float64(toRow-fromRow))), '.', planeColor(advisories[planes[i].Serial]), false)
+		}
+	}
+	for _, airport := range simState.Airports {
+		column, row := cell(airport.Location)
+		put(column, row, '#', colorAirport, true)
+	}
+	for i, plane := range planes {
+		column, row := cell(positions[i])
+		planeColor := planeColor(advisories[plane.Serial])
+		put(column, row, '+', planeColor, true)
+		tag := fmt.Sprintf("%s FL%03.0f", plane.Serial, aviation.MetersToFeet(positions[i].Z)/100)
+		// the tag follows the plane, or precedes it near the right edge of the scope
+		tagColumn := column + 2
+		if tagColumn+len(tag) > width {
+			tagColumn = column - 1 - len(tag)
+		}
+		for j, char := range tag {
+			put(tagColumn+j, row, char, planeColor, false)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "TCAS RADAR  %s  |  %d plane(s) in flight  |  1 column = %s\n",
+		now.Format(aviation.LogTimeFormat), len(planes), simState.DescribeDistance(metersPerColumn))
+	border := "+" + strings.Repeat("-", width) + "+\n"
+	b.WriteString(border)
+	for _, cells := range grid {
+		b.WriteByte('|')
+		for _, c := range cells {
+			if color && c.color != "" {
+				b.WriteString(c.color + string(c.char) + colorReset)
+			} else {
+				b.WriteRune(c.char)
+			}
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(border)
+	legend := "# airport  + plane  . distance flown in %v  %s  %s\n"
+	ta, ra := "TA (yellow)", "RA (red)"
+	if color {
+		ta, ra = colorTA+"TA"+colorReset, colorRA+"RA"+colorReset
+	}
+	fmt.Fprintf(&b, legend, radarVectorLength, ta, ra)
+	return b.String()
+}
+
+// planeColor returns the radar color of a plane involved in the given advisory.
+func planeColor(advisory aviation.AdvisoryType) string {
+	switch advisory {
+	case aviation.AdvisoryRA:
+		return colorRA
+	case aviation.AdvisoryTA:
+		return colorTA
+	default:
+		return colorPlane
+	}
+}
+
+// abs returns the absolute value of an integer.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+	"github.com/josephus-git/TCAS-simulation/internal/util"
+)
+
+// Exit codes of the headless run mode
+const (
+	exitOK        = 0 // the simulation ran to the end without a collision
+	exitError     = 1 // the simulation could not be run or its artifacts could not be written
+	exitUsage     = 2 // invalid command-line arguments
+	exitCollision = 3 // the simulation ran but at least one mid-air collision occurred
+)
+
+// scenarioError is returned by runOptions.prepare when the scenario of the run cannot be loaded.
+type scenarioError struct {
+	err error
+}
+
+func (e *scenarioError) Error() string { return e.err.Error() }
+func (e *scenarioError) Unwrap() error { return e.err }
+
+// runOptions are the parameters of a single simulation run, given as flags to the run command
+// or as the JSON body of a POST /simulations request, which writes the durations as text.
+type runOptions struct {
+	Planes        int           `json:"planes"`
+	Scenario      string        `json:"scenario"`
+	Altitudes     string        `json:"altitudes"`
+	Duration      time.Duration `json:"-"`
+	Seed          int64         `json:"seed"`
+	Speed         string        `json:"speed"`
+	Faulty        float64       `json:"faulty"`
+	Threshold     float64       `json:"threshold"`
+	NonCompliance float64       `json:"noncompliance"`
+	Opposite      float64       `json:"opposite"`
+	ResponseDelay time.Duration `json:"-"`
+	Acceleration  float64       `json:"acceleration"`
+	VerticalRate  float64       `json:"vertical_rate"`
+	CrewVariation float64       `json:"crew_variation"`
+	Fleet         string        `json:"fleet"`
+	Origin        string        `json:"origin"`
+	TrackInterval time.Duration `json:"-"`
+}
+
+// defaultRunOptions returns the options of a run whose flags are all left at their defaults.
+func defaultRunOptions() runOptions {
+	return runOptions{
+		Altitudes:     "same",
+		Duration:      10 * time.Minute,
+		Speed:         "max",
+		Faulty:        aviation.DefaultFaultyTCASRatio,
+		Threshold:     aviation.CollisionThreshold,
+		ResponseDelay: aviation.StandardResponseDelay,
+		Acceleration:  aviation.StandardAccelerationG,
+		VerticalRate:  aviation.StandardVerticalRateFpm,
+		TrackInterval: aviation.TrackInterval,
+	}
+}
+
+// prepare validates the options and returns the configuration of the run, the scenario it replays
+// (nil for a random world of Planes planes) and the speed of its clock.
+func (opts runOptions) prepare() (*config.Config, *aviation.Scenario, float64, error) {
+	var scenario *aviation.Scenario
+	if opts.Scenario != "" {
+		var err error
+		if scenario, err = aviation.LoadScenario(opts.Scenario); err != nil {
+			return nil, nil, 0, &scenarioError{err}
+		}
+	} else if opts.Planes < 2 {
+		return nil, nil, 0, fmt.Errorf("planes must be an integer greater than 1")
+	}
+	if opts.Duration <= 0 {
+		return nil, nil, 0, fmt.Errorf("duration must be positive")
+	}
+	var differentAltitudes bool
+	switch strings.ToLower(opts.Altitudes) {
+	case "same":
+	case "varied":
+		differentAltitudes = true
+	default:
+		return nil, nil, 0, fmt.Errorf("altitudes must be same or varied")
+	}
+	if opts.Faulty < 0 || opts.Faulty > 1 {
+		return nil, nil, 0, fmt.Errorf("faulty must be between 0 and 1")
+	}
+	if opts.Threshold <= 0 {
+		return nil, nil, 0, fmt.Errorf("threshold must be positive")
+	}
+	if opts.NonCompliance < 0 || opts.Opposite < 0 || opts.NonCompliance+opts.Opposite > 1 {
+		return nil, nil, 0, fmt.Errorf("noncompliance and opposite must be probabilities adding up to at most 1")
+	}
+	if opts.ResponseDelay <= 0 || opts.Acceleration <= 0 || opts.VerticalRate <= 0 {
+		return nil, nil, 0, fmt.Errorf("response-delay, acceleration and vertical-rate must be positive")
+	}
+	if opts.CrewVariation < 0 || opts.CrewVariation >= 1 {
+		return nil, nil, 0, fmt.Errorf("crew-variation must be at least 0 and less than 1")
+	}
+	speed, err := aviation.ParseClockSpeed(opts.Speed)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var fleetMix map[string]float64
+	if opts.Fleet != "" {
+		if fleetMix, err = aviation.ParseFleetMix(opts.Fleet); err != nil {
+			return nil, nil, 0, fmt.Errorf("fleet: %w", err)
+		}
+	}
+	if opts.TrackInterval <= 0 {
+		return nil, nil, 0, fmt.Errorf("track-interval must be positive")
+	}
+	var originPosition aviation.GeoCoordinate
+	if opts.Origin != "" {
+		if originPosition, err = aviation.ParseGeoCoordinate(opts.Origin); err != nil {
+			return nil, nil, 0, fmt.Errorf("origin: %w", err)
+		}
+	}
+
+	cfg := &config.Config{
+		NoOfAirplanes:         opts.Planes,
+		DifferentAltitudes:    differentAltitudes,
+		Seed:                  opts.Seed,
+		FaultyTCASRatio:       opts.Faulty,
+		CollisionThreshold:    opts.Threshold,
+		FleetMix:              fleetMix,
+		PilotNonCompliance:    opts.NonCompliance,
+		PilotOppositeResponse: opts.Opposite,
+		PilotResponseDelay:    opts.ResponseDelay,
+		PilotAcceleration:     opts.Acceleration,
+		PilotVerticalRate:     opts.VerticalRate,
+		PilotVariation:        opts.CrewVariation,
+		Geodetic:              opts.Origin != "",
+		OriginLatitude:        originPosition.Latitude,
+		OriginLongitude:       originPosition.Longitude,
+		TrackInterval:         opts.TrackInterval,
+	}
+	return cfg, scenario, speed, nil
+}
+
+// initializeWorld builds the airports and planes of a run, from its scenario when it has one.
+func initializeWorld(cfg *config.Config, simState *aviation.SimulationState, scenario *aviation.Scenario) {
+	if scenario != nil {
+		aviation.InitializeScenario(cfg, simState, scenario)
+	} else {
+		aviation.InitializeAirports(cfg, simState)
+	}
+}
+
+// parseRunFlags parses the flags of the run command into the options of the run and the directory its artifacts are written to.
+func parseRunFlags(args []string) (runOptions, string, error) {
+	opts := defaultRunOptions()
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.IntVar(&opts.Planes, "planes", opts.Planes, "number of planes in the simulation (at least 2)")
+	flags.StringVar(&opts.Scenario, "scenario", opts.Scenario, "scenario file declaring airports, planes and a timetable of flights, in place of --planes")
+	flags.StringVar(&opts.Altitudes, "altitudes", opts.Altitudes, "cruising altitudes: same or varied")
+	flags.DurationVar(&opts.Duration, "duration", opts.Duration, "simulated duration of the run, e.g. 30m or 2h")
+	flags.Int64Var(&opts.Seed, "seed", opts.Seed, "master seed that makes the run reproducible at any speed (0 picks a random seed)")
+	flags.StringVar(&opts.Speed, "speed", opts.Speed, "clock speed: 1x, 10x, 100x or max")
+	flags.Float64Var(&opts.Faulty, "faulty", opts.Faulty, "share of the fleet fitted with a faulty TCAS (0 to 1)")
+	flags.Float64Var(&opts.Threshold, "threshold", opts.Threshold, "distance below which a closest approach is a conflict")
+	flags.Float64Var(&opts.NonCompliance, "noncompliance", opts.NonCompliance, "probability that a crew ignores a Resolution Advisory (0 to 1)")
+	flags.Float64Var(&opts.Opposite, "opposite", opts.Opposite, "probability that a crew flies the opposite of the commanded sense (0 to 1)")
+	flags.DurationVar(&opts.ResponseDelay, "response-delay", opts.ResponseDelay, "delay before a crew starts an initial RA maneuver")
+	flags.Float64Var(&opts.Acceleration, "acceleration", opts.Acceleration, "vertical acceleration of an initial RA maneuver, in g")
+	flags.Float64Var(&opts.VerticalRate, "vertical-rate", opts.VerticalRate, "vertical rate commanded by an initial RA, in ft/min")
+	flags.Float64Var(&opts.CrewVariation, "crew-variation", opts.CrewVariation, "spread of every crew's delay, acceleration and rate around the given ones (0 to 1), drawn from the seed")
+	flags.StringVar(&opts.Fleet, "fleet", opts.Fleet, "fleet mix of aircraft types, e.g. A320=0.5,B738=0.3,C172=0.2 (empty uses the default mix)")
+	flags.StringVar(&opts.Origin, "origin", opts.Origin, "latitude,longitude of the map origin: places the map on the Earth and flies great-circle routes (flat map when empty)")
+	flags.DurationVar(&opts.TrackInterval, "track-interval", opts.TrackInterval, "how often planes in flight record their track, e.g. 1s or 30s")
+	outDir := flags.String("out", "results", "directory the run's artifacts are written to")
+	flags.SetOutput(os.Stderr)
+	if err := flags.Parse(args); err != nil {
+		return runOptions{}, "", err
+	}
+	return opts, *outDir, nil
+}
+
+// runHeadless runs a single simulation end-to-end without any interactive prompt,
+// writes all artifacts to the output directory and returns the process exit code.
+//
+// usage: tcas-sim run --planes 200 --altitudes varied --duration 30m --seed 7 --out results/
+func runHeadless(args []string) int {
+	opts, outDir, err := parseRunFlags(args)
+	if err != nil {
+		return exitUsage
+	}
+
+	cfg, scenario, speed, err := opts.prepare()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return exitUsage
+	}
+	simState := &aviation.SimulationState{
+		LogDir:   outDir,
+		Headless: true,
+		Events:   aviation.NewEventBus(),
+	}
+	util.ResetLogDir(outDir)
+
+	initializeWorld(cfg, simState, scenario)
+	if err := launchSimulation(simState, opts.Duration, speed); err != nil {
+		log.Printf("run: %v", err)
+		return exitError
+	}
+
+	logAirportDetails(simState)
+	logAirplanesDetails(simState)
+	logFlightDetailsToFile(simState)
+
+	fmt.Printf("Run finished: seed %d, %d collision(s), artifacts written to %s\n",
+		simState.Seed, len(simState.Collisions), outDir)
+	if len(simState.Collisions) > 0 {
+		return exitCollision
+	}
+	return exitOK
+}
+
+// usage prints how to invoke the simulator from the command line.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintln(w, "  tcas-sim [--seed N] [--origin LAT,LON] [--track-interval 10s]  start the interactive TCAS-simulator")
+	fmt.Fprintln(w, "  tcas-sim run [flags]     run one simulation without prompts and exit")
+	fmt.Fprintln(w, "  tcas-sim campaign [flags] run a Monte Carlo campaign over a parameter grid and exit")
+	fmt.Fprintln(w, "  tcas-sim serve [flags]    serve an HTTP/JSON API to start, stop and query simulations")
+	fmt.Fprintln(w, "\nflags for run:")
+	fmt.Fprintln(w, "  --planes N | --scenario file.json")
+	fmt.Fprintln(w, "  --altitudes same|varied --duration 30m --seed N --speed max --faulty 0.25 --threshold 5 --noncompliance 0 --opposite 0 --response-delay 5s --acceleration 0.25 --vertical-rate 1500 --crew-variation 0 --fleet A320=0.5,C172=0.5 --origin 51.47,-0.45 --track-interval 10s --out results/")
+	fmt.Fprintln(w, "\nflags for campaign:")
+	fmt.Fprintln(w, "  "+campaignUsage)
+	fmt.Fprintln(w, "\nflags for serve:")
+	fmt.Fprintln(w, "  "+serveUsage)
+}
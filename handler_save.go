@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// saveUsage describes the arguments accepted by the save command.
+const saveUsage = "usage: save scenario <file> (writes the current airports, planes and seed as a scenario file for load)"
+
+// saveScenario writes the current world to the scenario file given as argument, so that it can be loaded
+// again with the load command or the --scenario flag of run.
+func saveScenario(simState *aviation.SimulationState, arguments []string) {
+	if len(arguments) != 2 || !strings.EqualFold(arguments[0], "scenario") {
+		fmt.Println(saveUsage)
+		return
+	}
+	if len(simState.Airports) == 0 {
+		fmt.Println("There is no world to save yet")
+		return
+	}
+	path := arguments[1]
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if simState.Scenario != nil && simState.Scenario.Name != "" {
+		name = simState.Scenario.Name
+	}
+	scenario := simState.ExportScenario(name)
+	if err := aviation.SaveScenario(scenario, path); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Saved scenario %q to %s: %d airports, %d planes, %d scheduled flights (seed %d).\n",
+		scenario.Name, path, len(scenario.Airports), len(scenario.Planes), len(scenario.Flights), scenario.Seed)
+}
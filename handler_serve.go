@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation/internal/util"
+)
+
+// serveUsage describes the flags accepted by the serve command.
+const serveUsage = "[--addr localhost:8080] [--out dir] [--scenarios dir]"
+
+// apiDefaultSpeed is the clock speed of simulations started through the API without one,
+// real time so that dashboards can follow them.
+const apiDefaultSpeed = "1x"
+
+// apiServer runs simulations on behalf of HTTP clients and answers queries about them.
+type apiServer struct {
+	outDir      string
+	scenarioDir string // directory of the scenario files clients can name, none can be loaded when empty
+	mu          sync.Mutex
+	simulations map[string]*apiSimulation
+	latest      string // ID of the most recently started simulation
+	nextID      int
+}
+
+// apiSimulation is a simulation started through the API.
+type apiSimulation struct {
+	id       string
+	state    *aviation.SimulationState
+	duration time.Duration
+	speed    float64
+	metrics  *simulationMetrics
+	done     chan struct{} // closed once the run has ended
+	stopMu   sync.Mutex
+	stopped  bool // whether the run was stopped through the API, set before done is closed
+}
+
+// runServe serves the simulator's HTTP/JSON API until the process is killed.
+//
+// usage: tcas-sim serve --addr localhost:8080 --out results/ --scenarios scenarios/
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address the API listens on")
+	outDir := flags.String("out", "results", "directory every simulation writes its logs to, in a subdirectory named after its ID")
+	scenarioDir := flags.String("scenarios", "scenarios", "directory of the scenario files simulations can be started from")
+	flags.SetOutput(os.Stderr)
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("usage: serve %s", serveUsage)
+	}
+
+	server := &apiServer{outDir: *outDir, scenarioDir: *scenarioDir, simulations: map[string]*apiSimulation{}}
+	log.Printf("Serving the TCAS-simulator API on %s, simulation logs in %s", *addr, *outDir)
+	return http.ListenAndServe(*addr, server.routes())
+}
+
+// routes returns the handler of every endpoint of the API.
+func (server *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /simulations", server.createSimulation)
+	mux.HandleFunc("GET /simulations", server.listSimulations)
+	mux.HandleFunc("GET /simulations/{id}", server.getSimulation)
+	mux.HandleFunc("POST /simulations/{id}/stop", server.stopSimulation)
+	mux.HandleFunc("GET /airports", server.query(airportViews))
+	mux.HandleFunc("GET /planes", server.query(planeViews))
+	mux.HandleFunc("GET /flights", server.query(flightViews))
+	mux.HandleFunc("GET /engagements", server.query(engagementViews))
+	mux.HandleFunc("GET /events", server.streamEvents)
+	mux.HandleFunc("GET /metrics", server.serveMetrics)
+	return mux
+}
+
+// createSimulation builds a world from the run options in the request body and starts simulating it.
+// The options are those of the run command, durations written as text such as "30m", and the scenario
+// is the name of a file in the server's scenario directory.
+func (server *apiServer) createSimulation(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		runOptions
+		Duration      string `json:"duration"`
+		ResponseDelay string `json:"response_delay"`
+		TrackInterval string `json:"track_interval"`
+	}{runOptions: defaultRunOptions()}
+	request.Speed = apiDefaultSpeed
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	opts := request.runOptions
+	var err error
+	if request.Duration != "" {
+		if opts.Duration, err = time.ParseDuration(request.Duration); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid duration %q", request.Duration))
+			return
+		}
+	}
+	if request.ResponseDelay != "" {
+		if opts.ResponseDelay, err = time.ParseDuration(request.ResponseDelay); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid response_delay %q", request.ResponseDelay))
+			return
+		}
+	}
+	if request.TrackInterval != "" {
+		if opts.TrackInterval, err = time.ParseDuration(request.TrackInterval); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid track_interval %q", request.TrackInterval))
+			return
+		}
+	}
+	if opts.Scenario != "" {
+		// clients only name files of the scenario directory, and learn nothing of the others
+		if server.scenarioDir == "" || !filepath.IsLocal(opts.Scenario) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid scenario %q", opts.Scenario))
+			return
+		}
+		opts.Scenario = filepath.Join(server.scenarioDir, opts.Scenario)
+	}
+	cfg, scenario, speed, err := opts.prepare()
+	if loadErr := (*scenarioError)(nil); errors.As(err, &loadErr) {
+		log.Printf("serve: %v", err)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("scenario %q cannot be loaded", request.Scenario))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	server.mu.Lock()
+	server.nextID++
+	id := strconv.Itoa(server.nextID)
+	server.mu.Unlock()
+
+	logDir := filepath.Join(server.outDir, "simulation-"+id)
+	util.ResetLogDir(logDir)
+	simState := &aviation.SimulationState{
+		LogDir:   logDir,
+		Headless: true,
+		Quiet:    true,
+		Events:   aviation.NewEventBus(),
+	}
+	initializeWorld(cfg, simState, scenario)
+	sim := &apiSimulation{id: id, state: simState, duration: opts.Duration, speed: speed,
+		metrics: newSimulationMetrics(), done: make(chan struct{})}
+	simState.Events.Subscribe(sim.metrics.record)
+	// the run is set up before the handlers of other requests may read its state
+	run, err := prepareSimulation(simState, speed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("simulation %s could not start: %v", id, err))
+		return
+	}
+
+	server.mu.Lock()
+	server.simulations[id] = sim
+	server.latest = id
+	server.mu.Unlock()
+
+	go func() {
+		defer close(sim.done)
+		run.run(sim.duration)
+		logAirportDetails(simState)
+		logAirplanesDetails(simState)
+		logFlightDetailsToFile(simState)
+	}()
+
+	log.Printf("Simulation %s started through the API: seed %d, %v at %s", id, simState.Seed, sim.duration, aviation.FormatClockSpeed(speed))
+	w.Header().Set("Location", "/simulations/"+id)
+	writeJSON(w, http.StatusCreated, sim.view())
+}
+
+// listSimulations returns every simulation started through the API, oldest first.
+func (server *apiServer) listSimulations(w http.ResponseWriter, r *http.Request) {
+	views := []simulationView{}
+	for _, sim := range server.sortedSimulations() {
+		views = append(views, sim.view())
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// sortedSimulations returns every simulation started through the API, oldest first.
+func (server *apiServer) sortedSimulations() []*apiSimulation {
+	server.mu.Lock()
+	simulations := make([]*apiSimulation, 0, len(server.simulations))
+	for _, sim := range server.simulations {
+		simulations = append(simulations, sim)
+	}
+	server.mu.Unlock()
+
+	sort.Slice(simulations, func(i, j int) bool {
+		a, _ := strconv.Atoi(simulations[i].id)
+		b, _ := strconv.Atoi(simulations[j].id)
+		return a < b
+	})
+	return simulations
+}
+
+// getSimulation returns the status of a simulation.
+func (server *apiServer) getSimulation(w http.ResponseWriter, r *http.Request) {
+	sim := server.simulation(w, r.PathValue("id"))
+	if sim == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, sim.view())
+}
+
+// stopSimulation stops a running simulation, as the q command does, and returns its final status.
+func (server *apiServer) stopSimulation(w http.ResponseWriter, r *http.Request) {
+	sim := server.simulation(w, r.PathValue("id"))
+	if sim == nil {
+		return
+	}
+
+	if err := sim.stop(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	<-sim.done
+	writeJSON(w, http.StatusOK, sim.view())
+}
+
+// stop stops the run of the simulation, returning once its simulation goroutines have stopped.
+func (sim *apiSimulation) stop() error {
+	sim.stopMu.Lock()
+	defer sim.stopMu.Unlock()
+	select {
+	case <-sim.done:
+		return fmt.Errorf("simulation %s has already ended", sim.id)
+	default:
+	}
+	if !emergencyStop(sim.state) {
+		// the run has not begun yet or is ending on its own
+		return fmt.Errorf("simulation %s is starting or ending, try again", sim.id)
+	}
+	sim.stopped = true
+	return nil
+}
+
+// query returns a handler answering with the given view of the simulation the request is about.
+func (server *apiServer) query(view func(simState *aviation.SimulationState) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sim := server.resolve(w, r)
+		if sim == nil {
+			return
+		}
+		writeJSON(w, http.StatusOK, view(sim.state))
+	}
+}
+
+// resolve returns the simulation a request is about: the one named by its "simulation" query parameter,
+// or the most recently started one. It answers 404 and returns nil when there is none.
+func (server *apiServer) resolve(w http.ResponseWriter, r *http.Request) *apiSimulation {
+	id := r.URL.Query().Get("simulation")
+	if id == "" {
+		server.mu.Lock()
+		id = server.latest
+		server.mu.Unlock()
+		if id == "" {
+			writeError(w, http.StatusNotFound, "no simulation has been started, POST /simulations to start one")
+			return nil
+		}
+	}
+	return server.simulation(w, id)
+}
+
+// simulation returns the simulation with the given ID, or answers 404 and returns nil.
+func (server *apiServer) simulation(w http.ResponseWriter, id string) *apiSimulation {
+	server.mu.Lock()
+	sim, ok := server.simulations[id]
+	server.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no simulation %q", id))
+		return nil
+	}
+	return sim
+}
+
+// writeJSON answers with the given status and the value encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+// writeError answers with the given status and a JSON object holding the error message.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
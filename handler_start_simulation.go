@@ -3,59 +3,209 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
-	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+	"github.com/josephus-git/TCAS-simulation/internal/util"
 )
 
-// startInit parses the duration string and initializes the simulation,
+// startUsage describes the arguments accepted by the start command.
+const startUsage = "usage: start <integer> [speed] [--seed N] (integer represents time in minute(s), speed is e.g. 1x, 10x, 100x or max)"
+
+// startInit parses the duration, speed and seed arguments and initializes the simulation,
 // handles input validation, ensuring a positive integer for simulation duration.
-func startInit(simState *aviation.SimulationState, durationMinutesString string) {
+// When a seed is given the world is rebuilt from it, or the loaded scenario replayed with it, so the run is reproducible from scratch.
+func startInit(cfg *config.Config, simState *aviation.SimulationState, arguments []string) {
+	if len(arguments) == 0 {
+		fmt.Println(startUsage)
+		return
+	}
 
-	logFilePath := "logs/console_log.txt"
-	// Open the file in append mode. Create it if it doesn't exist.
-	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	durationMinutes, err := strconv.Atoi(arguments[0])
 	if err != nil {
-		log.Fatalf("failed to open log file: %v", err)
+		fmt.Println(startUsage)
+		return
+	}
+	if durationMinutes < 1 {
+		fmt.Println("Please input a valid integer greater than 0")
+		return
+	}
+
+	speed := 1.0
+	var seed int64
+	for i := 1; i < len(arguments); i++ {
+		if strings.EqualFold(arguments[i], "--seed") {
+			if i+1 >= len(arguments) {
+				fmt.Println(startUsage)
+				return
+			}
+			seed, err = strconv.ParseInt(arguments[i+1], 10, 64)
+			if err != nil {
+				fmt.Println("Please input a valid integer seed")
+				return
+			}
+			i++
+			continue
+		}
+		speed, err = aviation.ParseClockSpeed(arguments[i])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 	}
 
-	logFilePath = "logs/tcasLog.txt"
-	tcasLog, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if simState.SimIsRunning.Load() {
+		fmt.Println("A simulation is already running, type 'q' to stop it first")
+		return
+	}
+	if seed != 0 && simState.Scenario != nil {
+		cfg.Seed = seed
+		aviation.InitializeScenario(cfg, simState, simState.Scenario)
+	} else if seed != 0 {
+		cfg.Seed = seed
+		aviation.InitializeAirports(cfg, simState)
+	}
+
+	if err := launchSimulation(simState, time.Duration(durationMinutes)*time.Minute, speed); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// launchSimulation opens the run's log files, starts a simulation clock at the given speed
+// and runs the simulation for the given duration of simulated time, returning once it has ended.
+func launchSimulation(simState *aviation.SimulationState, simulationDuration time.Duration, speed float64) error {
+	run, err := prepareSimulation(simState, speed)
 	if err != nil {
-		log.Fatalf("failed to open log file: %v", err)
+		return err
 	}
+	run.run(simulationDuration)
+	return nil
+}
+
+// simulationRun is a run whose log files are open and whose clock is set, ready to start.
+type simulationRun struct {
+	simState    *aviation.SimulationState
+	clock       *aviation.SimClock
+	f           *os.File
+	tcasLog     *os.File
+	eventLog    *os.File
+	unsubscribe func()
+}
 
-	durationMinutes, err := strconv.Atoi(durationMinutesString)
+// prepareSimulation opens the run's log files and sets the simulation state up for a run whose clock
+// goes at the given speed. Once it returns, other goroutines may read the state while the run goes on.
+func prepareSimulation(simState *aviation.SimulationState, speed float64) (*simulationRun, error) {
+	f, err := openLogFile(simState, "console_log.txt")
 	if err != nil {
-		fmt.Println("usage: start <integer> (integer represents time in minute(s))")
-		return
+		return nil, err
 	}
-	if durationMinutes < 1 {
-		fmt.Println("Please input a valid integer greater than 0")
-		return
+
+	tcasLog, err := openLogFile(simState, "tcasLog.txt")
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	// every event of the run is also written as a JSON object per line, for analysis tools
+	eventLog, err := openLogFile(simState, "events.jsonl")
+	if err != nil {
+		f.Close()
+		tcasLog.Close()
+		return nil, err
+	}
+	if simState.Events == nil {
+		simState.Events = aviation.NewEventBus()
 	}
-	simState.SimIsRunning = true
+	unsubscribe := simState.Events.Subscribe(aviation.JSONLinesWriter(eventLog))
+
+	// every goroutine of the run reads simulated time from this clock
+	clock := aviation.NewSimClock(aviation.SimulationEpoch, speed)
+	simState.Clock = clock
+	simState.SimIsRunning.Store(true)
 	simState.SimEndedTime = time.Time{}
 	simState.SimStatusChannel = make(chan struct{})
-	startSimulation(simState, time.Duration(durationMinutes), f, tcasLog)
+	simState.Collisions = nil
+	return &simulationRun{simState: simState, clock: clock, f: f, tcasLog: tcasLog, eventLog: eventLog, unsubscribe: unsubscribe}, nil
+}
+
+// run runs the prepared simulation for the given duration of simulated time and closes what it opened once it has ended.
+func (run *simulationRun) run(simulationDuration time.Duration) {
+	defer run.clock.Stop()
+	defer run.eventLog.Close()
+	defer run.unsubscribe()
+	defer run.tcasLog.Close()
+	log.Printf("Simulation clock running at %s, seed %d", aviation.FormatClockSpeed(run.clock.Speed()), run.simState.Seed)
+	startSimulation(run.simState, simulationDuration, run.f, run.tcasLog)
+}
+
+// openLogFile opens the named log file of the run in append mode, creating the log directory if needed.
+// When the run's LogDir is the null device the logs are discarded.
+func openLogFile(simState *aviation.SimulationState, name string) (*os.File, error) {
+	if simState.LogDir == os.DevNull {
+		return os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	}
+	if err := os.MkdirAll(simState.LogPath(""), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	// Open the file in append mode. Create it if it doesn't exist.
+	f, err := os.OpenFile(simState.LogPath(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return f, nil
+}
+
+// reportFormats are the files the end-of-run report is written to, one per format.
+var reportFormats = []struct {
+	name  string
+	write func(report aviation.Report, w io.Writer) error
+}{
+	{"report.txt", aviation.Report.WriteText},
+	{"report.md", aviation.Report.WriteMarkdown},
+	{"report.html", aviation.Report.WriteHTML},
+}
+
+// writeRunReport writes the end-of-run report in every format to the log directory of the run.
+// Nothing is written when the run's LogDir is the null device.
+func writeRunReport(simState *aviation.SimulationState, report aviation.Report) error {
+	if simState.LogDir == os.DevNull {
+		return nil
+	}
+	for _, format := range reportFormats {
+		file, err := os.Create(simState.LogPath(format.name))
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		err = format.write(report, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", format.name, err)
+		}
+	}
+	return nil
 }
 
 // startAirports launches goroutines for each airport to handle takeoffs.
-func startAirports(simState *aviation.SimulationState, ctx context.Context, wg *sync.WaitGroup, f, tcasLog *os.File) {
+// Like every goroutine of a run they are started through the clock, which keeps track of them until they return.
+func startAirports(simState *aviation.SimulationState, ctx context.Context, wg *sync.WaitGroup, f *os.File) {
 	log.Printf("--- Starting Airport Launch Operations ---")
-	fmt.Fprintf(f, "%s--- Starting Airport Launch Operations ---\n",
-		time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(f, "%s --- Starting Airport Launch Operations ---\n",
+		simState.Clock.Now().Format(aviation.LogTimeFormat))
 	for i := range simState.Airports {
-		ap := simState.Airports[i] // Get a pointer to the airport
-		wg.Add(1)                  // Add to WaitGroup for each airport goroutine
-		go func(airport *aviation.Airport) {
+		airport := simState.Airports[i] // Get a pointer to the airport
+		wg.Add(1)                       // Add to WaitGroup for each airport goroutine
+		simState.Clock.AfterFunc(0, func() {
 			defer wg.Done()
-			airportRand := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)*1000)) // Unique seed for each airport
+			airportRand := util.NewRand(simState.Seed, "launch/"+airport.Serial) // Unique seeded stream for each airport
 
 			for {
 				select {
@@ -67,9 +217,8 @@ func startAirports(simState *aviation.SimulationState, ctx context.Context, wg *
 				}
 
 				sleepDuration := time.Duration(airportRand.Intn(int(AirportLaunchIntervalMax.Seconds()-AirportLaunchIntervalMin.Seconds())+1)+int(AirportLaunchIntervalMin.Seconds())) * time.Second //wait 5 to 10 seconds
-				select {
-				case <-time.After(sleepDuration):
-				case <-ctx.Done():
+				simState.Clock.Sleep(sleepDuration)
+				if ctx.Err() != nil {
 					// stoping all airport launch operation during sleep
 					return
 				}
@@ -80,7 +229,7 @@ func startAirports(simState *aviation.SimulationState, ctx context.Context, wg *
 					airport.Mu.Unlock()                 // Unlock airport before calling TakeOff
 
 					// IMPORTANT: Pass the global simState here.
-					_, err := airport.TakeOff(planeToTakeOff, simState, f, tcasLog) // Pass the simState from main
+					_, err := airport.TakeOff(planeToTakeOff, simState, f) // Pass the simState from main
 					if err != nil {
 						// log.Printf("error taking off from %s: %v", airport.Serial, err)
 					}
@@ -89,6 +238,52 @@ func startAirports(simState *aviation.SimulationState, ctx context.Context, wg *
 					// log.Printf("Airport %s has no planes to take off.", airport.Serial)
 				}
 			}
-		}(ap) // Pass airport pointer
+		})
+	}
+}
+
+// startTimetable launches every flight of the loaded scenario's timetable at its departure time,
+// in place of the random departures of startAirports. A plane that is not parked when its flight
+// is due, because its previous flight has not landed yet, misses that flight.
+func startTimetable(simState *aviation.SimulationState, ctx context.Context, wg *sync.WaitGroup, f *os.File) {
+	log.Printf("--- Starting Scenario Timetable ---")
+	fmt.Fprintf(f, "%s --- Starting Scenario Timetable ---\n",
+		simState.Clock.Now().Format(aviation.LogTimeFormat))
+	start := simState.Clock.Now()
+	for _, flight := range simState.Scenario.Timetable() {
+		wg.Add(1)
+		// a run stopped before the departure fires every pending timer at once, so the flight is dropped then
+		simState.Clock.AfterFunc(time.Duration(flight.Departure)-simState.Clock.Now().Sub(start), func() {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+
+			var origin, destination *aviation.Airport
+			var plane aviation.Plane
+			for _, airport := range simState.Airports {
+				airport.Mu.Lock()
+				for _, parked := range airport.Planes {
+					if parked.Serial == flight.Plane {
+						origin, plane = airport, parked
+					}
+				}
+				airport.Mu.Unlock()
+				if airport.Serial == flight.Destination {
+					destination = airport
+				}
+			}
+			if origin == nil || destination == nil || origin == destination {
+				log.Printf("Timetable: plane %s is not parked away from airport %s, it misses its flight scheduled at %v\n\n",
+					flight.Plane, flight.Destination, time.Duration(flight.Departure))
+				fmt.Fprintf(f, "%s Timetable: plane %s is not parked away from airport %s, it misses its flight scheduled at %v\n\n",
+					simState.Clock.Now().Format(aviation.LogTimeFormat), flight.Plane, flight.Destination, time.Duration(flight.Departure))
+				return
+			}
+			if _, err := origin.TakeOffTo(plane, destination, aviation.FeetToMeters(flight.CruisingAltitudeFt), simState, f); err != nil {
+				log.Printf("Timetable: %v\n\n", err)
+				fmt.Fprintf(f, "%s Timetable: %v\n\n", simState.Clock.Now().Format(aviation.LogTimeFormat), err)
+			}
+		})
 	}
 }
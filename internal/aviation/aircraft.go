@@ -0,0 +1,155 @@
+package aviation
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AircraftType describes the performance and equipage of an aircraft type, in SI units.
+type AircraftType struct {
+	Designator     string         // ICAO type designator, e.g. A320
+	Name           string         // manufacturer and model
+	CruiseSpeed    float64        // meters per second
+	ClimbRate      float64        // meters per second
+	DescentRate    float64        // meters per second
+	ServiceCeiling float64        // meters
+	ApproachSpeed  float64        // meters per second
+	WakeCategory   string         // ICAO wake turbulence category: L, M, H or J
+	TCAS           TCASCapability // default TCAS equipage of the type
+}
+
+// metersPerSecondPerKnot converts knots to meters per second.
+const metersPerSecondPerKnot = metersPerNauticalMile / 3600
+
+// aircraftTypesJSON is the embedded aircraft type database, in the units pilots use.
+//
+//go:embed aircraft_types.json
+var aircraftTypesJSON []byte
+
+// aircraftCatalogue holds every known aircraft type by designator.
+var aircraftCatalogue = mustLoadAircraftCatalogue(aircraftTypesJSON)
+
+// DefaultFleetMix is the share of each aircraft type in the fleet when none is configured.
+var DefaultFleetMix = map[string]float64{
+	"A320": 0.30,
+	"B738": 0.30,
+	"E190": 0.15,
+	"B77W": 0.10,
+	"AT76": 0.10,
+	"C172": 0.05,
+}
+
+// mustLoadAircraftCatalogue parses the aircraft type database, panicking on a malformed one
+// since it is embedded in the binary.
+func mustLoadAircraftCatalogue(data []byte) map[string]AircraftType {
+	var entries []struct {
+		Designator       string  `json:"designator"`
+		Name             string  `json:"name"`
+		CruiseSpeedKt    float64 `json:"cruise_speed_kt"`
+		ClimbRateFpm     float64 `json:"climb_rate_fpm"`
+		DescentRateFpm   float64 `json:"descent_rate_fpm"`
+		ServiceCeilingFt float64 `json:"service_ceiling_ft"`
+		ApproachSpeedKt  float64 `json:"approach_speed_kt"`
+		WakeCategory     string  `json:"wake_category"`
+		TCAS             string  `json:"tcas"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		panic(fmt.Sprintf("invalid aircraft type database: %v", err))
+	}
+
+	catalogue := map[string]AircraftType{}
+	for _, entry := range entries {
+		equipage := TCASPerfect
+		if strings.EqualFold(entry.TCAS, "none") {
+			equipage = TCASNone
+		}
+		catalogue[entry.Designator] = AircraftType{
+			Designator:     entry.Designator,
+			Name:           entry.Name,
+			CruiseSpeed:    entry.CruiseSpeedKt * metersPerSecondPerKnot,
+			ClimbRate:      entry.ClimbRateFpm * metersPerFoot / 60,
+			DescentRate:    entry.DescentRateFpm * metersPerFoot / 60,
+			ServiceCeiling: entry.ServiceCeilingFt * metersPerFoot,
+			ApproachSpeed:  entry.ApproachSpeedKt * metersPerSecondPerKnot,
+			WakeCategory:   entry.WakeCategory,
+			TCAS:           equipage,
+		}
+	}
+	return catalogue
+}
+
+// LookupAircraftType returns the aircraft type with the given designator.
+func LookupAircraftType(designator string) (AircraftType, bool) {
+	aircraftType, ok := aircraftCatalogue[strings.ToUpper(designator)]
+	return aircraftType, ok
+}
+
+// AircraftDesignators returns the designators of every known aircraft type, sorted.
+func AircraftDesignators() []string {
+	designators := make([]string, 0, len(aircraftCatalogue))
+	for designator := range aircraftCatalogue {
+		designators = append(designators, designator)
+	}
+	sort.Strings(designators)
+	return designators
+}
+
+// ParseFleetMix parses a fleet mix such as "A320=0.5,B738=0.3,C172=0.2": the relative share of
+// each aircraft type in the fleet. The shares do not need to add up to 1.
+func ParseFleetMix(s string) (map[string]float64, error) {
+	mix := map[string]float64{}
+	for _, part := range strings.Split(s, ",") {
+		designator, share, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid fleet mix entry %q: use TYPE=share", part)
+		}
+		designator = strings.ToUpper(strings.TrimSpace(designator))
+		if _, ok := aircraftCatalogue[designator]; !ok {
+			return nil, fmt.Errorf("unknown aircraft type %q, known types are %s", designator, strings.Join(AircraftDesignators(), ", "))
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(share), 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid share %q for %s: use a non-negative number", share, designator)
+		}
+		mix[designator] += value
+	}
+	total := 0.0
+	for _, share := range mix {
+		total += share
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("fleet mix %q has no aircraft", s)
+	}
+	return mix, nil
+}
+
+// drawAircraftType draws an aircraft type from a fleet mix, in proportion to the share of each type.
+func drawAircraftType(mix map[string]float64, r *rand.Rand) AircraftType {
+	designators := make([]string, 0, len(mix))
+	total := 0.0
+	for designator, share := range mix {
+		designators = append(designators, designator)
+		total += share
+	}
+	// sorted so that the same seed always draws the same fleet
+	sort.Strings(designators)
+
+	draw := r.Float64() * total
+	var drawn string
+	for _, designator := range designators {
+		if mix[designator] <= 0 {
+			continue
+		}
+		drawn = designator
+		if draw < mix[designator] {
+			break
+		}
+		draw -= mix[designator]
+	}
+	return aircraftCatalogue[drawn]
+}
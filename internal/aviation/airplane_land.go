@@ -33,21 +33,34 @@ func (ap *Airport) Land(plane Plane, simState *SimulationState, f *os.File) erro
 	log.Printf("Plane %s is attempting to land at Airport %s (%s).\n\n",
 		plane.Serial, ap.Serial, ap.Location.String())
 	fmt.Fprintf(f, "%s Plane %s is attempting to land at Airport %s (%s).\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, ap.Serial, ap.Location.String())
+		simState.Clock.Now().Format(LogTimeFormat), plane.Serial, ap.Serial, ap.Location.String())
+	requested := LandingRequested{Time: simState.Clock.Now(), Plane: plane.Serial, Airport: ap.Serial, Position: ap.Location}
+	if len(plane.FlightLog) > 0 {
+		requested.FlightID = plane.FlightLog[len(plane.FlightLog)-1].FlightID
+	}
+	simState.Events.Publish(requested)
 
 	// first we run a loop to make sure a plane is not trying to land in an airport where
 	// another airplane is trying to take off
-	for i := 0; ap.Runway.noOfRunwayinUse > 0 && simState.SimIsRunning; i++ {
+	for simState.SimIsRunning.Load() {
+		ap.Mu.Lock()
+		busy := RunwayBusy{Time: simState.Clock.Now(), Plane: plane.Serial, Airport: ap.Serial,
+			Position: ap.Location, Operation: "landing", RunwaysInUse: ap.Runway.noOfRunwayinUse, Runways: ap.Runway.numberOfRunway}
+		ap.Mu.Unlock()
+		if busy.RunwaysInUse == 0 {
+			break
+		}
 		log.Printf("\nairport %s has %d runway(s) currently in use; plane %s cannot land until all runways are free\n\n",
-			ap.Serial, ap.Runway.noOfRunwayinUse, plane.Serial)
-		fmt.Fprintf(f, "%s\nairport %s has %d runway(s) currently in use; plane %s cannot land until all runways are free\n\n",
-			time.Now().Format("2006-01-02 15:04:05"), ap.Serial, ap.Runway.noOfRunwayinUse, plane.Serial)
-		time.Sleep(TakeoffDuration)
+			ap.Serial, busy.RunwaysInUse, plane.Serial)
+		fmt.Fprintf(f, "%s airport %s has %d runway(s) currently in use; plane %s cannot land until all runways are free\n\n",
+			simState.Clock.Now().Format(LogTimeFormat), ap.Serial, busy.RunwaysInUse, plane.Serial)
+		simState.Events.Publish(busy)
+		simState.Clock.Sleep(TakeoffDuration)
 	}
 	log.Printf("Plane %s is now landing at Airport %s (%s).\n\n",
 		plane.Serial, ap.Serial, ap.Location.String())
-	fmt.Fprintf(f, "%sPlane %s is now landing at Airport %s (%s).\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, ap.Serial, ap.Location.String())
+	fmt.Fprintf(f, "%s Plane %s is now landing at Airport %s (%s).\n\n",
+		simState.Clock.Now().Format(LogTimeFormat), plane.Serial, ap.Serial, ap.Location.String())
 
 	// Mark a runway as in use for the landing.
 	// This lock the runway so no plane can take off for the landing duration
@@ -56,8 +69,12 @@ func (ap *Airport) Land(plane Plane, simState *SimulationState, f *os.File) erro
 	ap.Runway.noOfRunwayinUse++
 	ap.ReceivingPlane = true
 	ap.Mu.Unlock()
-	defer func() { ap.ReceivingPlane = false }()
-	time.Sleep(LandingDuration)
+	defer func() {
+		ap.Mu.Lock()
+		ap.ReceivingPlane = false
+		ap.Mu.Unlock()
+	}()
+	simState.Clock.Sleep(LandingDuration)
 
 	// Retrieve the current flight details from the plane's log.
 	if len(plane.FlightLog) == 0 {
@@ -66,7 +83,13 @@ func (ap *Airport) Land(plane Plane, simState *SimulationState, f *os.File) erro
 	// Get the most recent flight from the log.
 	currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
 
-	plane.FlightLog[len(plane.FlightLog)-1].FlightStatus = "about to land"
+	// the flight is still surveilled: its status is updated on the plane in flight
+	simState.Mu.Lock()
+	if index := simState.inFlightIndex(plane.Serial); index != -1 {
+		flightLog := simState.PlanesInFlight[index].FlightLog
+		flightLog[len(flightLog)-1].FlightStatus = "about to land"
+	}
+	simState.Mu.Unlock()
 
 	// Verify that this airport is the plane's intended destination.
 	// We use the 'distance' function with an Epsilon to account for floating-point inaccuracies.
@@ -77,6 +100,9 @@ func (ap *Airport) Land(plane Plane, simState *SimulationState, f *os.File) erro
 
 	// Acquire the airport's mutex lock. This protects the runway state and other
 	// airport-specific shared resources during the critical landing operation.
+	// the landing's event is published once the airport and the simulation state are unlocked
+	var events []Event
+	defer func() { simState.Events.Publish(events...) }()
 	ap.Mu.Lock()
 	defer ap.Mu.Unlock() // Ensure the lock is released when the function exits
 
@@ -88,13 +114,9 @@ func (ap *Airport) Land(plane Plane, simState *SimulationState, f *os.File) erro
 	ap.Runway.noOfRunwayinUse--
 
 	// 7. Remove the plane from the global `simState.PlanesInFlight` list.
-	planeInFlightIndex := -1
-	for i, p := range simState.PlanesInFlight {
-		if p.Serial == plane.Serial {
-			planeInFlightIndex = i
-			break
-		}
-	}
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+	planeInFlightIndex := simState.inFlightIndex(plane.Serial)
 
 	if planeInFlightIndex == -1 {
 		// This scenario should ideally not happen if the simulation logic is robust,
@@ -102,23 +124,45 @@ func (ap *Airport) Land(plane Plane, simState *SimulationState, f *os.File) erro
 		return fmt.Errorf("plane %s not found in the global PlanesInFlight list; cannot complete landing at airport %s", plane.Serial, ap.Serial)
 	}
 
+	// Take the track and the TCAS engagements recorded by the surveillance cycles while the plane was in flight
+	plane.FlightLog = simState.PlanesInFlight[planeInFlightIndex].FlightLog
+	plane.TCASEngagementRecords = simState.PlanesInFlight[planeInFlightIndex].TCASEngagementRecords
+	plane.CurrentTCASEngagements = simState.PlanesInFlight[planeInFlightIndex].CurrentTCASEngagements
+
 	// Remove the plane from the slice without changing its capacity.
 	simState.PlanesInFlight = append(simState.PlanesInFlight[:planeInFlightIndex], simState.PlanesInFlight[planeInFlightIndex+1:]...)
 
 	// 8. Update the plane's status to reflect it's no longer in flight.
 	plane.PlaneInFlight = false // Update the local copy
-	plane.CurrentTCASEngagements = []TCASEngagement{}
+	for len(plane.CurrentTCASEngagements) > 0 {
+		plane.closeEngagement(0, simState.Clock.Now())
+	}
 
 	plane.FlightLog[len(plane.FlightLog)-1].FlightStatus = "landed"
-	plane.FlightLog[len(plane.FlightLog)-1].ActualLandingTime = time.Now()
+	plane.FlightLog[len(plane.FlightLog)-1].ActualLandingTime = simState.Clock.Now()
+	plane.FlightLog[len(plane.FlightLog)-1].Track = append(plane.FlightLog[len(plane.FlightLog)-1].Track,
+		TrackPoint{Time: simState.Clock.Now(), Position: ap.Location})
 
 	// 9. Add the now-landed plane to the destination airport's list of parked planes.
 	ap.Planes = append(ap.Planes, plane) // Append the updated copy of the plane
 
 	log.Printf("Plane %s successfully landed at Airport %s (%s). It is now parked.\n\n",
 		plane.Serial, ap.Serial, ap.Location.String())
-	fmt.Fprintf(f, "%sPlane %s successfully landed at Airport %s (%s). It is now parked.\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, ap.Serial, ap.Location.String())
+	fmt.Fprintf(f, "%s Plane %s successfully landed at Airport %s (%s). It is now parked.\n\n",
+		simState.Clock.Now().Format(LogTimeFormat), plane.Serial, ap.Serial, ap.Location.String())
+	events = append(events, LandingCompleted{Time: simState.Clock.Now(), Plane: plane.Serial, FlightID: currentFlight.FlightID,
+		Airport: ap.Serial, Position: ap.Location})
 
 	return nil
 }
+
+// inFlightIndex returns the index in PlanesInFlight of the plane with the given serial, or -1 if it is not in flight.
+// The caller must hold simState.Mu.
+func (simState *SimulationState) inFlightIndex(serial string) int {
+	for i, plane := range simState.PlanesInFlight {
+		if plane.Serial == serial {
+			return i
+		}
+	}
+	return -1
+}
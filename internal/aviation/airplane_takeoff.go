@@ -3,7 +3,6 @@ package aviation
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"time"
 
@@ -16,7 +15,7 @@ var CruisingAltitudes = [3]float64{10000.0, 10200.0, 10400.0}
 // TakeoffDuration defines how long a takeoff operation physically lasts.
 const TakeoffDuration = 5 * time.Second
 
-// TakeOff prepares a plane for flight, simulates its takeoff, and updates the simulation state.
+// TakeOff prepares a plane for flight to a random destination, simulates its takeoff, and updates the simulation state.
 // It handles runway allocation, flight path generation, and state transitions for the plane and airport.
 //
 // Parameters:
@@ -29,30 +28,53 @@ const TakeoffDuration = 5 * time.Second
 //
 //	*Flight: A pointer to the newly created Flight struct representing this takeoff.
 //	error: An error if the takeoff cannot be initiated (e.g., no available runways, plane not found).
-func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasLog *os.File) (*Flight, error) {
+func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f *os.File) (*Flight, error) {
+	// Select a random destination airport for the plane.
+	destinationAirport, err := airport.getRandomDestinationAirport(simState.Airports)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select destination airport for plane %s: %w", plane.Serial, err)
+	}
+	return airport.TakeOffTo(plane, destinationAirport, 0, simState, f)
+}
+
+// TakeOffTo is TakeOff to the given destination airport, as scheduled by a scenario's timetable.
+// A cruisingAltitude of 0 draws the cruising altitude like a random flight.
+func (airport *Airport) TakeOffTo(plane Plane, destinationAirport *Airport, cruisingAltitude float64, simState *SimulationState, f *os.File) (*Flight, error) {
 	log.Printf("Plane %s (Cruise Speed: %.2fm/s) is attempting to takeoff from Airport %s %s\n\n",
 		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) is attempting to takeoff from Airport %s %s\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
+		simState.Clock.Now().Format(LogTimeFormat), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 
-	for i := 0; airport.ReceivingPlane && simState.SimIsRunning; i++ {
+	for simState.SimIsRunning.Load() {
+		airport.Mu.Lock()
+		receiving := airport.ReceivingPlane
+		busy := RunwayBusy{Time: simState.Clock.Now(), Plane: plane.Serial, Airport: airport.Serial,
+			Position: airport.Location, Operation: "takeoff", RunwaysInUse: airport.Runway.noOfRunwayinUse, Runways: airport.Runway.numberOfRunway}
+		airport.Mu.Unlock()
+		if !receiving {
+			break
+		}
 		log.Printf("\nairport %s is currently receiving a landing plane; plane %s cannot takeoff until all landing operations are over\n\n",
 			airport.Serial, plane.Serial)
-		fmt.Fprintf(f, "%s \nairport %s is currently receiving a landing plane; plane %s cannot takeoff until all landing operations are over\n\n",
-			time.Now().Format("2006-01-02 15:04:05"), airport.Serial, plane.Serial)
-		time.Sleep(LandingDuration)
+		fmt.Fprintf(f, "%s airport %s is currently receiving a landing plane; plane %s cannot takeoff until all landing operations are over\n\n",
+			simState.Clock.Now().Format(LogTimeFormat), airport.Serial, plane.Serial)
+		simState.Events.Publish(busy)
+		simState.Clock.Sleep(LandingDuration)
 	}
 
 	for {
 		// Check if there's an available runway.
 		airport.Mu.Lock()
 		if airport.Runway.noOfRunwayinUse >= airport.Runway.numberOfRunway {
+			busy := RunwayBusy{Time: simState.Clock.Now(), Plane: plane.Serial, Airport: airport.Serial,
+				Position: airport.Location, Operation: "takeoff", RunwaysInUse: airport.Runway.noOfRunwayinUse, Runways: airport.Runway.numberOfRunway}
 			airport.Mu.Unlock() // Release lock immediately if no runway available
 			log.Printf("\nairport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
 				airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
-			fmt.Fprintf(f, "%s \nairport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
-				time.Now().Format("2006-01-02 15:04:05"), airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
-			time.Sleep(TakeoffDuration)
+			fmt.Fprintf(f, "%s airport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
+				simState.Clock.Now().Format(LogTimeFormat), airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
+			simState.Events.Publish(busy)
+			simState.Clock.Sleep(TakeoffDuration)
 		} else {
 			airport.Mu.Unlock()
 			break
@@ -69,11 +91,15 @@ func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasL
 	log.Printf("Plane %s (Cruise Speed: %.2fm/s) is taking off from Airport %s %s\n\n",
 		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) is taking off from Airport %s %s\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
+		simState.Clock.Now().Format(LogTimeFormat), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
+	simState.Events.Publish(TakeoffStarted{Time: simState.Clock.Now(), Plane: plane.Serial, Airport: airport.Serial, Position: airport.Location})
 
-	time.Sleep(TakeoffDuration)
+	simState.Clock.Sleep(TakeoffDuration)
 
 	// After the takeoff duration, re-acquire the lock to safely decrement the counter.
+	// the takeoff's event is published once the airport is unlocked
+	var events []Event
+	defer func() { simState.Events.Publish(events...) }()
 	airport.Mu.Lock()
 	airport.Runway.noOfRunwayinUse--
 	defer airport.Mu.Unlock() // ensures the airport lock is released after the function exits
@@ -94,12 +120,6 @@ func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasL
 	// Remove the plane from the airport's Planes slice.
 	airport.Planes = append(airport.Planes[:planeIndex], airport.Planes[planeIndex+1:]...)
 
-	// Select a random destination airport for the plane.
-	destinationAirport, err := airport.getRandomDestinationAirport(simState.Airports)
-	if err != nil {
-		return nil, fmt.Errorf("failed to select destination airport for plane %s: %w", plane.Serial, err)
-	}
-
 	// Define the flight path from the current airport to the destination.
 	flightPath := FlightPath{
 		Depature:    airport.Location,
@@ -107,18 +127,17 @@ func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasL
 	}
 
 	// Calculate the total distance and estimated flight duration.
-	flightDistance := Distance(flightPath.Depature, flightPath.Destination)
+	flightDistance := simState.RouteDistance(flightPath)
 	if plane.CruiseSpeed <= 0 {
 		return nil, fmt.Errorf("plane %s has an invalid cruise speed (%.2f), cannot calculate flight duration", plane.Serial, plane.CruiseSpeed)
 	}
 	// Assuming CruiseSpeed is in units per second, and distance is in those same units.
 	flightDuration := time.Duration(flightDistance/plane.CruiseSpeed) * time.Second
 
-	takeoffTime := time.Now()
+	takeoffTime := simState.Clock.Now()
 	landingTime := takeoffTime.Add(flightDuration)
-	var cruisingAltitude float64
-	if simState.DifferentAltitudes {
-		chance := rand.Float64()
+	if cruisingAltitude <= 0 && simState.DifferentAltitudes {
+		chance := airport.rand.Float64()
 		if chance < 0.33 {
 			cruisingAltitude = CruisingAltitudes[0]
 		} else if chance < 0.66 {
@@ -126,9 +145,17 @@ func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasL
 		} else {
 			cruisingAltitude = CruisingAltitudes[2]
 		}
-	} else {
+	} else if cruisingAltitude <= 0 {
 		cruisingAltitude = CruisingAltitudes[0]
 	}
+	if ceiling := plane.AircraftType.ServiceCeiling; ceiling > 0 && cruisingAltitude > ceiling {
+		cruisingAltitude = ceiling
+	}
+
+	trajectory := planTrajectory(flightPath, takeoffTime, landingTime, cruisingAltitude, plane.AircraftType.ClimbRate, plane.AircraftType.DescentRate)
+	if simState.Frame != nil {
+		trajectory = simState.Frame.followGreatCircle(trajectory)
+	}
 
 	// Create a new Flight record with all its details.
 	newFlight := Flight{
@@ -137,6 +164,8 @@ func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasL
 		TakeoffTime:            takeoffTime,
 		DestinationArrivalTime: landingTime,
 		CruisingAltitude:       cruisingAltitude,
+		Trajectory:             trajectory,
+		Track:                  []TrackPoint{{Time: takeoffTime, Position: airport.Location}},
 		DepatureAirPort:        airport.Serial,
 		ArrivalAirPort:         destinationAirport.Serial,
 		FlightStatus:           "in transit",
@@ -145,15 +174,29 @@ func (airport *Airport) TakeOff(plane Plane, simState *SimulationState, f, tcasL
 	// Update the plane's internal state to reflect it's now in flight.
 	plane.PlaneInFlight = true
 	plane.FlightLog = append(plane.FlightLog, newFlight)
-	tcasEngagements := plane.tcas(simState, tcasLog)
-	plane.CurrentTCASEngagements = tcasEngagements
-	// Add the updated plane to the global list of planes currently in flight.
+	plane.VerticalOffset = 0
+	plane.VerticalRate = 0
+	plane.verticalStateTime = takeoffTime
+	// Add the updated plane to the global list of planes currently in flight,
+	// from now on its TCAS takes part in every surveillance cycle.
+	simState.Mu.Lock()
 	simState.PlanesInFlight = append(simState.PlanesInFlight, plane)
+	simState.Mu.Unlock()
 
 	log.Printf("Plane %s (Cruise Speed: %.2fm/s) took off from Airport %s %s, heading to Airport %s %s. Estimated landing at %s.\n\n",
-		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format("15:04:05"))
+		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format(LogTimeFormat))
 	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) took off from Airport %s %s, heading to Airport %s %s. Estimated landing at %s.\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format("15:04:05"))
+		simState.Clock.Now().Format(LogTimeFormat), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format(LogTimeFormat))
+	events = append(events, TakeoffCompleted{
+		Time:             simState.Clock.Now(),
+		Plane:            plane.Serial,
+		FlightID:         newFlight.FlightID,
+		Airport:          airport.Serial,
+		Destination:      destinationAirport.Serial,
+		Position:         airport.Location,
+		CruisingAltitude: cruisingAltitude,
+		ArrivalTime:      landingTime,
+	})
 
 	return &newFlight, nil
 }
@@ -171,8 +214,7 @@ func (airport *Airport) getRandomDestinationAirport(allAirports []*Airport) (*Ai
 		return nil, fmt.Errorf("no other airports available to serve as a destination")
 	}
 
-	// Use a new random source to ensure sufficient randomness, especially if called frequently.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	randomIndex := r.Intn(len(eligibleAirports))
+	// Draw from the airport's seeded source so destinations are reproducible for a given seed.
+	randomIndex := airport.rand.Intn(len(eligibleAirports))
 	return eligibleAirports[randomIndex], nil
 }
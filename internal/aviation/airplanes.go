@@ -3,6 +3,8 @@ package aviation
 import (
 	"math"
 	"math/rand"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/josephus-git/TCAS-simulation/internal/util"
@@ -15,53 +17,130 @@ type TCASCapability int
 type Plane struct {
 	Serial                 string
 	PlaneInFlight          bool
+	AircraftType           AircraftType
 	CruiseSpeed            float64
 	FlightLog              []Flight
 	TCASCapability         TCASCapability
+	Pilot                  PilotModel
 	TCASEngagementRecords  []TCASEngagement
 	CurrentTCASEngagements []TCASEngagement
+	VerticalOffset         float64 // meters above (positive) or below the planned altitude, flown to resolve RAs
+	VerticalRate           float64 // meters per second, positive when climbing
+
+	verticalStateTime time.Time // simulation time the vertical state was last advanced to
 }
 
 const (
 	TCASPerfect TCASCapability = iota // 0
 	TCASFaulty
+	TCASNone // not fitted: the plane is only seen as traffic by the others
 )
 
-// createPlane initializes and returns a new Plane struct with a generated serial number.
-func createPlane(planeCount int) Plane {
+// DefaultCruiseSpeed is the cruise speed of a plane of unknown type in meters per second, roughly 450 knots.
+const DefaultCruiseSpeed = 230.0
+
+// DefaultFaultyTCASRatio is the default share of the fleet fitted with a faulty TCAS.
+const DefaultFaultyTCASRatio = 0.25
+
+// createPlane initializes and returns a new Plane struct of the given aircraft type with a generated serial number.
+// faultyTCASRatio is the probability that a plane whose type carries TCAS is fitted with a faulty one,
+// and pilot is the model of how its crew responds to Resolution Advisories.
+func createPlane(planeCount int, r *rand.Rand, faultyTCASRatio float64, pilot PilotModel, aircraftType AircraftType) Plane {
 	// Randomly assign TCAS capability
-	capability := TCASPerfect
-	if rand.Float64() < 0.25 { // 25% chance of faulty TCAS
+	capability := aircraftType.TCAS
+	if r.Float64() < faultyTCASRatio && capability != TCASNone {
 		capability = TCASFaulty
 	}
 
 	return Plane{
 		Serial:         util.GenerateSerialNumber(planeCount, "p"),
 		PlaneInFlight:  false,
-		CruiseSpeed:    5,
+		AircraftType:   aircraftType,
+		CruiseSpeed:    aircraftType.CruiseSpeed,
 		FlightLog:      []Flight{},
 		TCASCapability: capability,
+		Pilot:          pilot,
 	}
 }
 
-// Distance calculates the Euclidean Distance between two 3D coordinates.
-func Distance(p1, p2 Coordinate) float64 {
-	return math.Sqrt(math.Pow(p1.X-p2.X, 2) + math.Pow(p1.Y-p2.Y, 2) + math.Pow(p1.Z-p2.Z, 2))
+// Snapshot returns a copy of the plane that shares no flight, track or engagement with it, so that it can be
+// read after the lock guarding the plane is released while the surveillance keeps updating the original.
+// The caller must hold that lock.
+func (plane Plane) Snapshot() Plane {
+	plane.FlightLog = append([]Flight{}, plane.FlightLog...)
+	for i := range plane.FlightLog {
+		plane.FlightLog[i].Track = append([]TrackPoint{}, plane.FlightLog[i].Track...)
+	}
+	plane.TCASEngagementRecords = append([]TCASEngagement{}, plane.TCASEngagementRecords...)
+	plane.CurrentTCASEngagements = append([]TCASEngagement{}, plane.CurrentTCASEngagements...)
+	return plane
 }
 
-// GetClosestApproachDetails calculates the time and minimum Distance at which two planes will be closest during their respective flights.
-func (f1 Flight) GetClosestApproachDetails(f2 Flight) (closestTime time.Time, distanceBetweenPlanesatCA float64) {
-	flight1ClosestCoord, flight2ClosestCoord := FindClosestApproachDuringTransit(f1.FlightSchedule, f2.FlightSchedule)
+// AllPlanes returns a copy of every plane in the simulation, parked or in flight, sorted by serial.
+func (simState *SimulationState) AllPlanes() []Plane {
+	planes := []Plane{}
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		for _, plane := range airport.Planes {
+			planes = append(planes, plane.Snapshot())
+		}
+		airport.Mu.Unlock()
+	}
+	simState.Mu.Lock()
+	for _, plane := range simState.PlanesInFlight {
+		planes = append(planes, plane.Snapshot())
+	}
+	simState.Mu.Unlock()
 
-	flight1Distance := Distance(f1.FlightSchedule.Depature, f1.FlightSchedule.Destination)
-	distBtwDepatureAndClosestApproachForFlight1 := Distance(f1.FlightSchedule.Depature, flight1ClosestCoord)
+	sort.Slice(planes, func(i, j int) bool {
+		return planes[i].Serial < planes[j].Serial
+	})
+	return planes
+}
 
-	f1fractionofCA := distBtwDepatureAndClosestApproachForFlight1 / flight1Distance
+// FindFlight returns a copy of the flight with the given ID, matched case-insensitively, and the serial of
+// the plane that flew it. The copy's track is safe to read while the plane keeps flying.
+func (simState *SimulationState) FindFlight(flightID string) (Flight, string, bool) {
+	find := func(planes []Plane) (Flight, string, bool) {
+		for _, plane := range planes {
+			for _, flight := range plane.FlightLog {
+				if strings.EqualFold(flight.FlightID, flightID) {
+					flight.Track = append([]TrackPoint{}, flight.Track...)
+					return flight, plane.Serial, true
+				}
+			}
+		}
+		return Flight{}, "", false
+	}
 
-	totalFlightDuration1 := f1.DestinationArrivalTime.Sub(f1.TakeoffTime)
-	closestTime = f1.TakeoffTime.Add(time.Duration(float64(totalFlightDuration1) * f1fractionofCA))
+	simState.Mu.Lock()
+	flight, serial, ok := find(simState.PlanesInFlight)
+	simState.Mu.Unlock()
+	if ok {
+		return flight, serial, true
+	}
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		flight, serial, ok = find(airport.Planes)
+		airport.Mu.Unlock()
+		if ok {
+			return flight, serial, true
+		}
+	}
+	return Flight{}, "", false
+}
 
-	distanceBetweenPlanesatCA = Distance(flight1ClosestCoord, flight2ClosestCoord)
+// Distance calculates the Euclidean Distance between two 3D coordinates.
+func Distance(p1, p2 Coordinate) float64 {
+	return math.Sqrt(math.Pow(p1.X-p2.X, 2) + math.Pow(p1.Y-p2.Y, 2) + math.Pow(p1.Z-p2.Z, 2))
+}
 
-	return closestTime, distanceBetweenPlanesatCA
+// GetClosestApproachDetails calculates the time and minimum Distance at which two planes will be closest during their respective flights.
+// Flights that are never airborne at the same time never meet: it returns a zero time and an infinite Distance.
+func (f1 Flight) GetClosestApproachDetails(f2 Flight) (closestTime time.Time, distanceBetweenPlanesatCA float64) {
+	closestApproach, ok := f1.ClosestApproach(f2, f1.TakeoffTime)
+	if !ok {
+		return time.Time{}, math.Inf(1)
+	}
+	return closestApproach.Time, closestApproach.MissDistance()
 }
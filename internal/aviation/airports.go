@@ -16,6 +16,7 @@ type Airport struct {
 	Planes             []Plane
 	Mu                 sync.Mutex
 	ReceivingPlane     bool
+	rand               *rand.Rand // seeded source for this airport's departures
 }
 
 // runway represents the state of an airport's runways.
@@ -24,19 +25,26 @@ type runway struct {
 	noOfRunwayinUse int
 }
 
+// Runways returns the number of runways of the airport and how many of them are in use; ap.Mu must be held.
+func (ap *Airport) Runways() (total, inUse int) {
+	return ap.Runway.numberOfRunway, ap.Runway.noOfRunwayinUse
+}
+
 // createAirport initializes and returns a new Airport struct.
 // It generates a serial number, plane capacity, and runway details for the airport.
-func createAirport(airportCount, planecount, totalNumPlanes int) Airport {
+func createAirport(airportCount, planecount, totalNumPlanes int, r *rand.Rand, seed int64) Airport {
+	serial := util.GenerateSerialNumber(airportCount, "ap")
 	return Airport{
-		Serial:             util.GenerateSerialNumber(airportCount, "ap"),
-		InitialPlaneAmount: generatePlaneCapacity(totalNumPlanes, planecount),
-		Runway:             generateRunway(),
+		Serial:             serial,
+		InitialPlaneAmount: generatePlaneCapacity(totalNumPlanes, planecount, r),
+		Runway:             generateRunway(r),
+		rand:               util.NewRand(seed, "airport/"+serial),
 	}
 }
 
 // generateRunway creates and returns a new runway configuration.
-func generateRunway() runway {
-	randomNumber := rand.Intn(3) + 1
+func generateRunway(r *rand.Rand) runway {
+	randomNumber := r.Intn(3) + 1
 	return runway{
 		numberOfRunway:  randomNumber,
 		noOfRunwayinUse: 0,
@@ -45,14 +53,14 @@ func generateRunway() runway {
 
 // generatePlaneCapacity calculates a random number of planes to create,
 // adjusting the quantity based on the total target and already generated planes.
-func generatePlaneCapacity(totalPlanes, planeGenerated int) int {
+func generatePlaneCapacity(totalPlanes, planeGenerated int, r *rand.Rand) int {
 	var randomNumber int
 	if totalPlanes < 20 {
 		planeToCreate := totalPlanes - planeGenerated
 		if planeToCreate <= 3 {
 			randomNumber = planeToCreate
 		} else {
-			randomNumber = rand.Intn(2) + 1
+			randomNumber = r.Intn(2) + 1
 		}
 
 	} else if totalPlanes < 100 {
@@ -60,7 +68,7 @@ func generatePlaneCapacity(totalPlanes, planeGenerated int) int {
 		if planeToCreate <= 6 {
 			randomNumber = planeToCreate
 		} else {
-			randomNumber = rand.Intn(5) + 1
+			randomNumber = r.Intn(5) + 1
 		}
 
 	} else {
@@ -68,7 +76,7 @@ func generatePlaneCapacity(totalPlanes, planeGenerated int) int {
 		if planeToCreate <= 30 {
 			randomNumber = planeToCreate
 		} else {
-			randomNumber = rand.Intn(20) + 10
+			randomNumber = r.Intn(20) + 10
 		}
 
 	}
@@ -1,18 +1,33 @@
 package aviation
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"log"
 	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/config"
 )
 
 // Test helpers for float comparison:
 // FloatEqualityThreshold defines the tolerance for comparing floating-point numbers.
 const FloatEqualityThreshold = 1e-5
 
-// FloatEquals compares two float64 numbers for approximate equality.
+// FloatEquals compares two float64 numbers for approximate equality; infinities only equal themselves.
 func FloatEquals(a, b float64) bool {
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
 	return math.Abs(a-b) < FloatEqualityThreshold
 }
 
@@ -21,142 +36,6 @@ func CoordEquals(c1, c2 Coordinate) bool {
 	return FloatEquals(c1.X, c2.X) && FloatEquals(c1.Y, c2.Y) && FloatEquals(c1.Z, c2.Z)
 }
 
-// TestFindClosestApproachDuringTransit verifies the accuracy of FindClosestApprachDuringTransit
-// by testing various flight path configurations, including intersecting, parallel, and skew paths.
-func TestFindClosestApproachDuringTransit(t *testing.T) {
-	tests := []struct {
-		name    string
-		fp1     FlightPath
-		fp2     FlightPath
-		wantFp1 Coordinate
-		wantFp2 Coordinate
-	}{
-		{
-			name: "Intersecting Paths",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 5, Y: -5, Z: 0},
-				Destination: Coordinate{X: 5, Y: 5, Z: 0},
-			},
-			wantFp1: Coordinate{X: 5, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 5, Y: 0, Z: 0},
-		},
-		{
-			name: "Parallel Paths (non-overlapping)",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 1, Z: 0},
-				Destination: Coordinate{X: 10, Y: 1, Z: 0},
-			},
-			wantFp1: Coordinate{X: 0, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 0, Y: 1, Z: 0},
-		},
-		{
-			name: "Skew Paths (non-intersecting, 3D)",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 10, Z: 10},
-				Destination: Coordinate{X: 10, Y: 10, Z: 0},
-			},
-			wantFp1: Coordinate{X: 10, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 10, Y: 10, Z: 0},
-		},
-		{
-			name: "Endpoint to Endpoint (closest is an end point)",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 1, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 10, Y: 0, Z: 0},
-				Destination: Coordinate{X: 11, Y: 0, Z: 0},
-			},
-			wantFp1: Coordinate{X: 1, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 10, Y: 0, Z: 0},
-		},
-		{
-			name: "Identical Paths (should return start points)",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			wantFp1: Coordinate{X: 0, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 0, Y: 0, Z: 0},
-		},
-		{
-			name: "Collinear, Overlapping (Segment 1 contains Segment 2)",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 2, Y: 0, Z: 0},
-				Destination: Coordinate{X: 8, Y: 0, Z: 0},
-			},
-			wantFp1: Coordinate{X: 2, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 2, Y: 0, Z: 0},
-		},
-		{
-			name: "Collinear, Non-overlapping (Segment 1 before Segment 2)",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 5, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 7, Y: 0, Z: 0},
-				Destination: Coordinate{X: 10, Y: 0, Z: 0},
-			},
-			wantFp1: Coordinate{X: 5, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 7, Y: 0, Z: 0},
-		},
-		{
-			name: "Perpendicular, not intersecting, one endpoint is closest",
-			fp1: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 0, Z: 0},
-				Destination: Coordinate{X: 5, Y: 0, Z: 0},
-			},
-			fp2: FlightPath{
-				Depature:    Coordinate{X: 0, Y: 5, Z: 0},
-				Destination: Coordinate{X: 0, Y: 10, Z: 0},
-			},
-			wantFp1: Coordinate{X: 0, Y: 0, Z: 0},
-			wantFp2: Coordinate{X: 0, Y: 5, Z: 0},
-		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			gotFp1, gotFp2 := FindClosestApproachDuringTransit(test.fp1, test.fp2)
-
-			if !CoordEquals(gotFp1, test.wantFp1) {
-				t.Errorf("\nFor %s\n fp1Closest: got %v, want %v\n", test.name, gotFp1, test.wantFp1)
-			} else {
-				fmt.Printf("\n\nSuccess For %s\n fp1Closest: got %v, want %v\n", test.name, gotFp1, test.wantFp1)
-			}
-
-			if !CoordEquals(gotFp2, test.wantFp2) {
-				t.Errorf("\nFor %s\n fp2Closest: got %v, want %v\n", test.name, gotFp2, test.wantFp2)
-			} else {
-				fmt.Printf("\nSuccess For %s\n fp2Closest: got %v, want %v\n", test.name, gotFp2, test.wantFp2)
-			}
-		})
-	}
-
-}
-
 // TestGetClosestApproachDetails is the main test function for your logic.
 func TestGetClosestApproachDetails(t *testing.T) {
 	baseTime := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
@@ -201,7 +80,7 @@ func TestGetClosestApproachDetails(t *testing.T) {
 			expectedDistanceBetweenPlanesCA: 10.0,
 		},
 		{
-			name: "Scenario 3: Closest Approach at Departure (Flight 1 starts near Flight 2)",
+			name: "Scenario 3: Same Track, Flight 1 Catching Up",
 			flight1: Flight{
 				FlightSchedule:         FlightPath{Depature: Coordinate{0, 0, 0}, Destination: Coordinate{100, 0, 0}},
 				TakeoffTime:            baseTime,
@@ -212,8 +91,9 @@ func TestGetClosestApproachDetails(t *testing.T) {
 				TakeoffTime:            baseTime,
 				DestinationArrivalTime: baseTime.Add(10 * time.Minute),
 			},
-			expectedClosestTime:             baseTime.Add(30 * time.Second),
-			expectedDistanceBetweenPlanesCA: 0.0,
+			// Flight 1 is faster and closes in on flight 2 until both arrive.
+			expectedClosestTime:             baseTime.Add(10 * time.Minute),
+			expectedDistanceBetweenPlanesCA: 1.0,
 		},
 		{
 			name: "Scenario 4: Closest Approach at Destination (Flight 1 ends near Flight 2)",
@@ -242,8 +122,9 @@ func TestGetClosestApproachDetails(t *testing.T) {
 				TakeoffTime:            baseTime.Add(2 * time.Minute),
 				DestinationArrivalTime: baseTime.Add(12 * time.Minute),
 			},
-			expectedClosestTime:             baseTime.Add(5 * time.Minute),
-			expectedDistanceBetweenPlanesCA: 0.0,
+			// Flight 2 crosses the intersection 2 minutes after flight 1, so they never meet there.
+			expectedClosestTime:             baseTime.Add(6 * time.Minute),
+			expectedDistanceBetweenPlanesCA: 10 * math.Sqrt2,
 		},
 		{
 			name: "Scenario 7: Closest Approach Asymmetric (Near Start F1, Near End F2)",
@@ -260,6 +141,36 @@ func TestGetClosestApproachDetails(t *testing.T) {
 			expectedClosestTime:             baseTime,
 			expectedDistanceBetweenPlanesCA: 100.0,
 		},
+		{
+			name: "Scenario 8: Short Overlap Late In Flight 1",
+			flight1: Flight{
+				FlightSchedule:         FlightPath{Depature: Coordinate{0, 0, 0}, Destination: Coordinate{100, 0, 0}},
+				TakeoffTime:            baseTime,
+				DestinationArrivalTime: baseTime.Add(10 * time.Minute),
+			},
+			flight2: Flight{
+				FlightSchedule:         FlightPath{Depature: Coordinate{50, -50, 0}, Destination: Coordinate{50, 50, 0}},
+				TakeoffTime:            baseTime.Add(8 * time.Minute),
+				DestinationArrivalTime: baseTime.Add(18 * time.Minute),
+			},
+			expectedClosestTime:             baseTime.Add(9 * time.Minute),
+			expectedDistanceBetweenPlanesCA: 40 * math.Sqrt2,
+		},
+		{
+			name: "Scenario 9: Same Intersection Point, Never Airborne Together",
+			flight1: Flight{
+				FlightSchedule:         FlightPath{Depature: Coordinate{0, 0, 0}, Destination: Coordinate{100, 0, 0}},
+				TakeoffTime:            baseTime,
+				DestinationArrivalTime: baseTime.Add(10 * time.Minute),
+			},
+			flight2: Flight{
+				FlightSchedule:         FlightPath{Depature: Coordinate{50, -50, 0}, Destination: Coordinate{50, 50, 0}},
+				TakeoffTime:            baseTime.Add(2 * time.Hour),
+				DestinationArrivalTime: baseTime.Add(2*time.Hour + 10*time.Minute),
+			},
+			expectedClosestTime:             time.Time{},
+			expectedDistanceBetweenPlanesCA: math.Inf(1),
+		},
 	}
 
 	for _, tt := range tests {
@@ -276,3 +187,912 @@ func TestGetClosestApproachDetails(t *testing.T) {
 		})
 	}
 }
+
+// TestClosestApproach checks that the CPA of two flights is computed at the same instant along their
+// trajectories, split into horizontal and vertical miss distances.
+func TestClosestApproach(t *testing.T) {
+	baseTime := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	level := Flight{
+		TakeoffTime:            baseTime,
+		DestinationArrivalTime: baseTime.Add(1000 * time.Second),
+		Trajectory: []Waypoint{
+			{Position: Coordinate{0, 0, 1000}, Time: baseTime},
+			{Position: Coordinate{10000, 0, 1000}, Time: baseTime.Add(1000 * time.Second)},
+		},
+	}
+	// Climbs until the level flight takes off, then flies head-on 200 m to its side and 300 m above it.
+	opposite := Flight{
+		TakeoffTime:            baseTime.Add(-200 * time.Second),
+		DestinationArrivalTime: baseTime.Add(1000 * time.Second),
+		Trajectory: []Waypoint{
+			{Position: Coordinate{14000, 200, 0}, Time: baseTime.Add(-200 * time.Second)},
+			{Position: Coordinate{10000, 200, 1300}, Time: baseTime},
+			{Position: Coordinate{0, 200, 1300}, Time: baseTime.Add(1000 * time.Second)},
+		},
+	}
+
+	got, ok := level.ClosestApproach(opposite, baseTime.Add(-time.Minute))
+	if !ok {
+		t.Fatalf("flights airborne together: got no closest approach")
+	}
+	if !got.Time.Equal(baseTime.Add(500*time.Second)) || got.TimeToCPA != 560*time.Second {
+		t.Errorf("CPA at %v (in %v), want %v (in %v)", got.Time, got.TimeToCPA, baseTime.Add(500*time.Second), 560*time.Second)
+	}
+	if !FloatEquals(got.HorizontalMiss, 200) || !FloatEquals(got.VerticalMiss, 300) {
+		t.Errorf("miss distances %.2f horizontal, %.2f vertical, want 200 and 300", got.HorizontalMiss, got.VerticalMiss)
+	}
+	if !CoordEquals(got.Position1, Coordinate{5000, 0, 1000}) || !CoordEquals(got.Position2, Coordinate{5000, 200, 1300}) {
+		t.Errorf("positions at CPA %v and %v, want (5000, 0, 1000) and (5000, 200, 1300)", got.Position1, got.Position2)
+	}
+
+	if _, ok := level.ClosestApproach(opposite, baseTime.Add(time.Hour)); ok {
+		t.Errorf("reference time after both arrivals: got a closest approach, want none")
+	}
+}
+
+// TestSimClock verifies that a SimClock advances simulated time independently of wall-clock time
+// and fires its timers in order.
+func TestSimClock(t *testing.T) {
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	clock := NewSimClock(start, MaxSpeed)
+	defer clock.Stop()
+
+	fired := make(chan int, 2)
+	clock.AfterFunc(2*time.Hour, func() { fired <- 2 })
+	clock.AfterFunc(time.Hour, func() { fired <- 1 })
+
+	wallStart := time.Now()
+	clock.Sleep(3 * time.Hour)
+	if elapsed := time.Since(wallStart); elapsed > time.Second {
+		t.Errorf("sleeping 3 simulated hours at max speed took %v of wall time", elapsed)
+	}
+	if got := clock.Now(); got.Before(start.Add(3 * time.Hour)) {
+		t.Errorf("unexpected simulated time after sleep.\nExpected at least: %v\nActual: %v", start.Add(3*time.Hour), got)
+	}
+	if first, second := <-fired, <-fired; first != 1 || second != 2 {
+		t.Errorf("timers fired out of order: got %d then %d", first, second)
+	}
+
+	stopped := clock.AfterFunc(time.Minute, func() { t.Error("stopped timer fired") })
+	if !stopped.Stop() {
+		t.Error("Stop on a pending timer returned false")
+	}
+	clock.Sleep(2 * time.Minute)
+}
+
+// TestSimClockPause checks that a paused clock stands still, fires the timers within a step and nothing beyond it.
+func TestSimClockPause(t *testing.T) {
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	clock := NewSimClock(start, MaxSpeed)
+	defer clock.Stop()
+	clock.Pause()
+
+	fired := make(chan time.Duration, 3)
+	clock.AfterFunc(10*time.Second, func() { fired <- 10 * time.Second })
+	clock.AfterFunc(time.Minute, func() { fired <- time.Minute })
+	time.Sleep(20 * time.Millisecond)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Fatalf("paused clock moved from %v to %v", start, got)
+	}
+
+	if err := clock.Step(30 * time.Second); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(30 * time.Second)) {
+		t.Errorf("unexpected simulated time after step.\nExpected: %v\nActual: %v", start.Add(30*time.Second), got)
+	}
+	if got := <-fired; got != 10*time.Second {
+		t.Errorf("step fired the %v timer, want the 10s one", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(fired) != 0 || !clock.Paused() {
+		t.Error("the clock ran on after the step")
+	}
+
+	clock.Resume()
+	if got := <-fired; got != time.Minute {
+		t.Errorf("resumed clock fired the %v timer, want the 1m one", got)
+	}
+	if err := clock.Step(time.Second); err == nil {
+		t.Error("Step on a running clock returned no error")
+	}
+}
+
+// TestSimClockLockstep checks that a max-speed clock runs the goroutines it wakes one after the other,
+// without time moving on while one of them is busy, and that an ended clock fires its pending timers at once.
+func TestSimClockLockstep(t *testing.T) {
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	clock := NewSimClock(start, MaxSpeed)
+	defer clock.Stop()
+	clock.Pause()
+
+	order := make(chan string, 4)
+	clock.AfterFunc(time.Second, func() {
+		time.Sleep(30 * time.Millisecond) // busy in wall time
+		order <- "first at " + clock.Now().Sub(start).String()
+	})
+	clock.AfterFunc(time.Second, func() { order <- "second at " + clock.Now().Sub(start).String() })
+	clock.AfterFunc(2*time.Second, func() {
+		order <- "third at " + clock.Now().Sub(start).String()
+		clock.End()
+	})
+	clock.AfterFunc(time.Hour, func() { order <- "pending at " + clock.Now().Sub(start).String() })
+	clock.Resume()
+
+	for _, want := range []string{"first at 1s", "second at 1s", "third at 2s", "pending at 2s"} {
+		if got := <-order; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	clock.Pause()
+	if err := clock.Step(time.Second); err == nil {
+		t.Error("Step on an ended clock returned no error")
+	}
+}
+
+// TestParseClockSpeed checks the accepted forms of the start command's speed argument.
+func TestParseClockSpeed(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{input: "1x", want: 1},
+		{input: "10", want: 10},
+		{input: "100X", want: 100},
+		{input: "max", want: MaxSpeed},
+		{input: "0", wantErr: true},
+		{input: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseClockSpeed(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseClockSpeed(%q): unexpected error state: %v", tt.input, err)
+			continue
+		}
+		if !tt.wantErr && !FloatEquals(got, tt.want) {
+			t.Errorf("ParseClockSpeed(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestEvaluateThreat checks the TCAS advisory raised for head-on, diverging and vertically separated intruders.
+func TestEvaluateThreat(t *testing.T) {
+	cruise := 10000.0 // meters, sensitivity level 7
+	tests := []struct {
+		name             string
+		intruderPosition Coordinate
+		intruderVelocity Coordinate
+		want             AdvisoryType
+	}{
+		{
+			name:             "head-on at the same altitude, 20s to go",
+			intruderPosition: Coordinate{X: 9200, Y: 0, Z: cruise},
+			intruderVelocity: Coordinate{X: -230, Y: 0, Z: 0},
+			want:             AdvisoryRA,
+		},
+		{
+			name:             "head-on at the same altitude, 45s to go",
+			intruderPosition: Coordinate{X: 20700, Y: 0, Z: cruise},
+			intruderVelocity: Coordinate{X: -230, Y: 0, Z: 0},
+			want:             AdvisoryTA,
+		},
+		{
+			name:             "head-on 200m above, inside ZTHR but outside ALIM",
+			intruderPosition: Coordinate{X: 9200, Y: 0, Z: cruise + 200},
+			intruderVelocity: Coordinate{X: -230, Y: 0, Z: 0},
+			want:             AdvisoryTA,
+		},
+		{
+			name:             "diverging at the same altitude",
+			intruderPosition: Coordinate{X: 20000, Y: 0, Z: cruise},
+			intruderVelocity: Coordinate{X: 230, Y: 0, Z: 0},
+			want:             AdvisoryNone,
+		},
+		{
+			name:             "head-on 1000m above",
+			intruderPosition: Coordinate{X: 9200, Y: 0, Z: cruise + 1000},
+			intruderVelocity: Coordinate{X: -230, Y: 0, Z: 0},
+			want:             AdvisoryNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sl := evaluateThreat(Coordinate{Z: cruise}, Coordinate{X: 230}, tt.intruderPosition, tt.intruderVelocity)
+			if got != tt.want {
+				t.Errorf("evaluateThreat() = %v, want %v", got, tt.want)
+			}
+			if sl.Level != 7 {
+				t.Errorf("sensitivity level = %d, want 7", sl.Level)
+			}
+		})
+	}
+}
+
+// TestSurveil flies two planes head-on at the same altitude through the surveillance cycle. With working
+// TCAS the planes receive coordinated, opposite Resolution Advisories and the maneuver separates them;
+// with faulty TCAS, crews that ignore their RAs, or below 1000 ft where RAs are inhibited, the planes collide.
+func TestSurveil(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	newFlightPlane := func(serial string, capability TCASCapability, pilot PilotModel, altitude float64, from, to Coordinate) Plane {
+		return Plane{
+			Serial:         serial,
+			PlaneInFlight:  true,
+			CruiseSpeed:    DefaultCruiseSpeed,
+			TCASCapability: capability,
+			Pilot:          pilot,
+			FlightLog: []Flight{{
+				FlightID:               serial + "_F001",
+				FlightSchedule:         FlightPath{Depature: from, Destination: to},
+				TakeoffTime:            start,
+				DestinationArrivalTime: start.Add(time.Duration(Distance(from, to)/DefaultCruiseSpeed) * time.Second),
+				CruisingAltitude:       altitude,
+			}},
+			verticalStateTime: start,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		capability    TCASCapability
+		pilot         PilotModel
+		altitude      float64 // cruising altitude, CruisingAltitudes[0] when zero
+		wantCollision bool
+		wantAdvisory  AdvisoryType // highest advisory of the colliding engagement
+	}{
+		{name: "perfect TCAS", capability: TCASPerfect, pilot: DefaultPilotModel, wantCollision: false},
+		{name: "faulty TCAS", capability: TCASFaulty, pilot: DefaultPilotModel, wantCollision: true, wantAdvisory: AdvisoryTA},
+		{name: "non-compliant crews", capability: TCASPerfect, pilot: NewPilotModel(1, 0), wantCollision: true, wantAdvisory: AdvisoryRA},
+		{name: "no TCAS fitted", capability: TCASNone, pilot: DefaultPilotModel, wantCollision: true, wantAdvisory: AdvisoryNone},
+		{name: "below 1000 ft", capability: TCASPerfect, pilot: DefaultPilotModel, altitude: 500 * metersPerFoot, wantCollision: true, wantAdvisory: AdvisoryTA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewSimClock(start, MaxSpeed)
+			defer clock.Stop()
+			altitude := tt.altitude
+			if altitude == 0 {
+				altitude = CruisingAltitudes[0]
+			}
+			simState := &SimulationState{
+				Clock:              clock,
+				CollisionThreshold: CollisionThreshold,
+				PlanesInFlight: []Plane{
+					newFlightPlane("P_A001", tt.capability, tt.pilot, altitude, Coordinate{X: 0}, Coordinate{X: 60000}),
+					newFlightPlane("P_A002", tt.capability, tt.pilot, altitude, Coordinate{X: 60000}, Coordinate{X: 0}),
+				},
+				Events: NewEventBus(),
+			}
+			published := map[EventKind]int{}
+			lockedWhilePublishing := false
+			simState.Events.Subscribe(func(event Event) {
+				published[event.Kind()]++
+				// subscribers run once the simulation state is unlocked
+				if simState.Mu.TryLock() {
+					simState.Mu.Unlock()
+				} else {
+					lockedWhilePublishing = true
+				}
+			})
+
+			r := rand.New(rand.NewSource(1))
+			var collisions []TCASEngagement
+			for range 240 {
+				if collisions = simState.Surveil(devNull, devNull, r); len(collisions) > 0 {
+					break
+				}
+				clock.Sleep(TCASCycleInterval)
+			}
+			if collided := len(collisions) > 0; collided != tt.wantCollision {
+				t.Fatalf("collision = %t, want %t", collided, tt.wantCollision)
+			}
+			if lockedWhilePublishing {
+				t.Error("events were published while the simulation state was locked")
+			}
+			if tt.wantCollision {
+				if published[KindCollision] != 1 || published[KindCollisionAverted] != 0 {
+					t.Errorf("published %d collision and %d collision averted events, want 1 and 0",
+						published[KindCollision], published[KindCollisionAverted])
+				}
+				if collisions[0].Advisory != tt.wantAdvisory {
+					t.Errorf("colliding engagement reached %v, want %v", collisions[0].Advisory, tt.wantAdvisory)
+				}
+				return
+			}
+			// a TA then an RA per plane, and each RA ends clear of conflict
+			if published[KindTCASAdvisory] < 4 || published[KindCollisionAverted] != 2 || published[KindCollision] != 0 {
+				t.Errorf("published %d advisory, %d collision averted and %d collision events, want at least 4, 2 and 0",
+					published[KindTCASAdvisory], published[KindCollisionAverted], published[KindCollision])
+			}
+			// every recorded track point is also published, at least two per plane
+			if published[KindPositionUpdate] < 4 {
+				t.Errorf("published %d position updates, want at least 4", published[KindPositionUpdate])
+			}
+
+			senses := map[RASense]bool{}
+			for _, plane := range simState.PlanesInFlight {
+				if len(plane.CurrentTCASEngagements) != 0 {
+					t.Errorf("plane %s still has %d open engagement(s)", plane.Serial, len(plane.CurrentTCASEngagements))
+				}
+				if len(plane.TCASEngagementRecords) != 1 {
+					t.Fatalf("plane %s recorded %d engagement(s), want 1", plane.Serial, len(plane.TCASEngagementRecords))
+				}
+				engagement := plane.TCASEngagementRecords[0]
+				if engagement.Advisory != AdvisoryRA {
+					t.Errorf("plane %s engagement reached %v, want RA", plane.Serial, engagement.Advisory)
+				}
+				if !engagement.TATime.Before(engagement.RATime) || !engagement.RATime.Before(engagement.ClosedTime) {
+					t.Errorf("plane %s engagement advisories out of order: TA %v, RA %v, closed %v",
+						plane.Serial, engagement.TATime, engagement.RATime, engagement.ClosedTime)
+				}
+				senses[engagement.Sense] = true
+				track := plane.FlightLog[0].Track
+				if len(track) < 2 || track[1].Time.Sub(track[0].Time) != TrackInterval {
+					t.Fatalf("plane %s recorded a track of %d positions, want one every %v", plane.Serial, len(track), TrackInterval)
+				}
+				// the track is also sampled when the RA is issued, whatever the interval
+				recordedRA := false
+				for _, point := range track {
+					recordedRA = recordedRA || point.Time.Equal(engagement.RATime) && point.Advisory == AdvisoryRA && point.Sense == engagement.Sense
+				}
+				if !recordedRA {
+					t.Errorf("plane %s track has no sample of its RA to %v at %v", plane.Serial, engagement.Sense, engagement.RATime)
+				}
+			}
+			if !senses[SenseClimb] || !senses[SenseDescend] {
+				t.Errorf("resolution advisories were not complementary: %v", senses)
+			}
+		})
+	}
+}
+
+// TestPilotModel checks that a configured crew response keeps the proportions of the standard strengthening
+// response, and that crews drawn around it stay within the variation and are reproducible.
+func TestPilotModel(t *testing.T) {
+	if got := DefaultPilotModel.WithResponse(0, 0, 0); got != DefaultPilotModel {
+		t.Errorf("WithResponse without values: got %+v, want the standard pilot", got)
+	}
+	pilot := DefaultPilotModel.WithResponse(8*time.Second, 0.3, 2000)
+	if pilot.InitialResponseDelay != 8*time.Second || pilot.StrengtheningResponseDelay != 4*time.Second {
+		t.Errorf("response delays: got %v and %v, want 8s and 4s", pilot.InitialResponseDelay, pilot.StrengtheningResponseDelay)
+	}
+	if !FloatEquals(pilot.InitialAcceleration, 0.3*gravity) || !FloatEquals(pilot.StrengtheningAcceleration, 0.42*gravity) {
+		t.Errorf("accelerations: got %.3f and %.3f m/s², want 0.3 g and 0.42 g", pilot.InitialAcceleration, pilot.StrengtheningAcceleration)
+	}
+	if rate := pilot.StrengthenedVerticalRate / metersPerFoot * 60; !FloatEquals(pilot.TargetVerticalRate/metersPerFoot*60, 2000) || !FloatEquals(rate, 2000*5.0/3) {
+		t.Errorf("strengthened vertical rate: got %.1f ft/min, want %.1f ft/min", rate, 2000*5.0/3)
+	}
+
+	r1, r2 := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+	for range 100 {
+		crew := DefaultPilotModel.vary(0.2, r1)
+		if crew != DefaultPilotModel.vary(0.2, r2) {
+			t.Fatalf("crews drawn from the same seed differ")
+		}
+		if delay := crew.InitialResponseDelay; delay < 4*time.Second || delay > 6*time.Second ||
+			math.Abs(crew.InitialAcceleration/DefaultPilotModel.InitialAcceleration-1) > 0.2 ||
+			math.Abs(crew.TargetVerticalRate/DefaultPilotModel.TargetVerticalRate-1) > 0.2 {
+			t.Fatalf("crew %v is not within 20%% of the standard pilot", crew)
+		}
+	}
+	if crew := DefaultPilotModel.vary(0, r1); crew != DefaultPilotModel {
+		t.Errorf("crew drawn without variation: got %+v, want the standard pilot", crew)
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	var first, second []EventKind
+	unsubscribe := bus.Subscribe(func(event Event) { first = append(first, event.Kind()) })
+	bus.Subscribe(func(event Event) { second = append(second, event.Kind()) })
+
+	bus.Publish(TakeoffStarted{Plane: "P_A001", Airport: "AP_A001"})
+	unsubscribe()
+	bus.Publish(LandingCompleted{Plane: "P_A001", Airport: "AP_A002"})
+
+	if len(first) != 1 || first[0] != KindTakeoffStarted {
+		t.Errorf("first subscriber received %v, want only the event published before it unsubscribed", first)
+	}
+	if len(second) != 2 || second[1] != KindLandingCompleted {
+		t.Errorf("second subscriber received %v, want both events", second)
+	}
+	if planes := EventPlanes(TCASAdvisory{Plane: "P_A001", Intruder: "P_A002"}); len(planes) != 2 || planes[1] != "P_A002" {
+		t.Errorf("EventPlanes of an advisory = %v, want own plane then intruder", planes)
+	}
+	// a run without a bus discards its events
+	var none *EventBus
+	none.Publish(Collision{})
+
+	var buffer bytes.Buffer
+	write := JSONLinesWriter(&buffer)
+	write(TCASAdvisory{Plane: "P_A001", Intruder: "P_A002", Position: Coordinate{X: 100, Z: 3000}, Advisory: AdvisoryRA, Sense: SenseClimb})
+	write(SimulationEnded{Reason: "collision", Collisions: 1})
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines, want one per event", len(lines))
+	}
+	var advisory map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &advisory); err != nil {
+		t.Fatalf("invalid event line %s: %v", lines[0], err)
+	}
+	position, _ := advisory["position"].(map[string]any)
+	if advisory["type"] != "tcas_advisory" || advisory["advisory"] != "RA" || advisory["sense"] != "climb" || position["z"] != 3000.0 {
+		t.Errorf("advisory event written as %s", lines[0])
+	}
+}
+
+// TestPositionAtFollowsVerticalProfile checks the climb, cruise and descent phases of a flight's trajectory,
+// and that a flight too short to reach its cruising altitude tops out lower.
+func TestPositionAtFollowsVerticalProfile(t *testing.T) {
+	takeoff := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	newFlight := func(duration time.Duration) Flight {
+		return Flight{
+			FlightSchedule:         FlightPath{Depature: Coordinate{}, Destination: Coordinate{X: DefaultCruiseSpeed * duration.Seconds()}},
+			TakeoffTime:            takeoff,
+			DestinationArrivalTime: takeoff.Add(duration),
+			CruisingAltitude:       CruisingAltitudes[0],
+		}
+	}
+
+	long := newFlight(time.Hour)
+	climbDuration := time.Duration(CruisingAltitudes[0] / ClimbRate * float64(time.Second))
+	descentDuration := time.Duration(CruisingAltitudes[0] / DescentRate * float64(time.Second))
+	tests := []struct {
+		name  string
+		at    time.Time
+		wantZ float64
+	}{
+		{name: "takeoff", at: takeoff, wantZ: 0},
+		{name: "half way up the climb", at: takeoff.Add(climbDuration / 2), wantZ: CruisingAltitudes[0] / 2},
+		{name: "cruise", at: takeoff.Add(30 * time.Minute), wantZ: CruisingAltitudes[0]},
+		{name: "half way down the descent", at: takeoff.Add(time.Hour - descentDuration/2), wantZ: CruisingAltitudes[0] / 2},
+		{name: "arrival", at: takeoff.Add(time.Hour), wantZ: 0},
+	}
+	for _, tt := range tests {
+		if got := long.PositionAt(tt.at); math.Abs(got.Z-tt.wantZ) > 0.01 {
+			t.Errorf("%s: altitude %.2f, want %.2f", tt.name, got.Z, tt.wantZ)
+		}
+	}
+	if got := long.VelocityAt(takeoff.Add(time.Minute)); !FloatEquals(got.Z, ClimbRate) {
+		t.Errorf("climb vertical rate %.2f, want %.2f", got.Z, ClimbRate)
+	}
+
+	short := newFlight(10 * time.Minute)
+	wantPeak := 600 / (1/ClimbRate + 1/DescentRate)
+	if peak := short.TopOfClimb().Position.Z; math.Abs(peak-wantPeak) > 0.01 {
+		t.Errorf("short flight peak altitude %.2f, want %.2f", peak, wantPeak)
+	}
+	if cruise := short.TopOfDescent().Time.Sub(short.TopOfClimb().Time); cruise.Abs() > time.Millisecond {
+		t.Errorf("short flight cruises from %v to %v, want no cruise", short.TopOfClimb().Time, short.TopOfDescent().Time)
+	}
+}
+
+// TestGeodetic checks the WGS-84 conversions round trip, that great-circle distances match known values
+// and that encounters far from the map origin are evaluated on a map local to own plane.
+func TestGeodetic(t *testing.T) {
+	frame := ENUFrame{Origin: GeoCoordinate{Latitude: 51.47, Longitude: -0.4543}}
+	positions := []GeoCoordinate{
+		frame.Origin,
+		{Latitude: 52.0, Longitude: 0.5, Altitude: 10000},
+		{Latitude: 50.9, Longitude: -1.3, Altitude: 300},
+	}
+	for _, g := range positions {
+		if got := frame.FromENU(frame.ToENU(g)); !FloatEquals(got.Latitude, g.Latitude) || !FloatEquals(got.Longitude, g.Longitude) || math.Abs(got.Altitude-g.Altitude) > 1e-3 {
+			t.Errorf("ENU round trip of %v: got %v", g, got)
+		}
+		if got := frame.FromMap(frame.ToMap(g)); !FloatEquals(got.Latitude, g.Latitude) || !FloatEquals(got.Longitude, g.Longitude) || got.Altitude != g.Altitude {
+			t.Errorf("map round trip of %v: got %v", g, got)
+		}
+	}
+	if up := frame.ToENU(GeoCoordinate{Latitude: 51.47, Longitude: -0.4543, Altitude: 1000}); !CoordEquals(up, Coordinate{Z: 1000}) {
+		t.Errorf("1000 m above the origin: got %v, want (0, 0, 1000)", up)
+	}
+
+	oneDegree := GreatCircleDistance(GeoCoordinate{Latitude: 10, Longitude: 20}, GeoCoordinate{Latitude: 11, Longitude: 20})
+	if want := meanEarthRadius * math.Pi / 180; !FloatEquals(oneDegree, want) {
+		t.Errorf("one degree of latitude: got %.3f m, want %.3f m", oneDegree, want)
+	}
+	if nm := MetersToNauticalMiles(GreatCircleDistance(GeoCoordinate{Longitude: 0}, GeoCoordinate{Longitude: 1})); math.Abs(nm-60.04) > 0.01 {
+		t.Errorf("one degree along the equator: got %.2f nm, want about 60.04 nm", nm)
+	}
+	midway := GreatCircleIntermediate(GeoCoordinate{Latitude: 40, Longitude: -70}, GeoCoordinate{Latitude: 40, Longitude: 10}, 0.5)
+	if midway.Latitude <= 40 || !FloatEquals(midway.Longitude, -30) {
+		t.Errorf("great circle between two points of latitude 40°N should bulge north halfway, got %v", midway)
+	}
+
+	// Two planes 300 m apart vertically, 5 nm apart on the ground and about 500 km east of the map origin,
+	// where the flat map no longer holds directions: the encounter is evaluated below own plane.
+	own := GeoCoordinate{Latitude: 51.47, Longitude: 6.8, Altitude: 10000}
+	intruder := GeoCoordinate{Latitude: 51.47 + 5*metersPerNauticalMile/(meanEarthRadius*math.Pi/180), Longitude: 6.8, Altitude: 10300}
+	ownPosition, ownVelocity, intruderPosition, intruderVelocity := frame.localGeometry(
+		frame.ToMap(own), Coordinate{X: 200}, frame.ToMap(intruder), Coordinate{})
+	if !CoordEquals(ownPosition, Coordinate{Z: 10000}) || math.Abs(intruderPosition.Z-10300) > 1e-6 {
+		t.Errorf("local geometry: own plane at %v and intruder at %v, want own plane at (0, 0, 10000) and the intruder 300 m above", ownPosition, intruderPosition)
+	}
+	if got, want := math.Hypot(intruderPosition.X, intruderPosition.Y), GreatCircleDistance(own, intruder); math.Abs(got-want) > 0.01*want {
+		t.Errorf("local horizontal distance: got %.1f m, want about %.1f m", got, want)
+	}
+	// the map's east is not east anymore 7° of longitude away from its origin: the meridians converge
+	if math.Abs(Distance(ownVelocity, Coordinate{})-200) > 1 || ownVelocity.Y > -10 || intruderVelocity != (Coordinate{}) {
+		t.Errorf("local velocities: got %v and %v, want about 200 m/s turned south of east and still", ownVelocity, intruderVelocity)
+	}
+}
+
+// TestAircraftCatalogue checks the embedded aircraft type database, fleet mix parsing
+// and that planes are drawn in proportion to the mix.
+func TestAircraftCatalogue(t *testing.T) {
+	for _, designator := range AircraftDesignators() {
+		aircraftType, _ := LookupAircraftType(designator)
+		if aircraftType.CruiseSpeed <= 0 || aircraftType.ClimbRate <= 0 || aircraftType.DescentRate <= 0 ||
+			aircraftType.ServiceCeiling <= 0 || aircraftType.ApproachSpeed <= 0 || aircraftType.WakeCategory == "" {
+			t.Errorf("aircraft type %s has incomplete performance data: %+v", designator, aircraftType)
+		}
+	}
+	a320, ok := LookupAircraftType("a320")
+	if !ok || !FloatEquals(a320.CruiseSpeed, 450*1852.0/3600) || a320.TCAS != TCASPerfect {
+		t.Errorf("A320: got %+v, want 450 kt with TCAS fitted", a320)
+	}
+	if c172, _ := LookupAircraftType("C172"); c172.TCAS != TCASNone || math.Abs(c172.ServiceCeiling-14000*0.3048) > 1e-6 {
+		t.Errorf("C172: got %+v, want no TCAS and a 14000 ft ceiling", c172)
+	}
+
+	if _, err := ParseFleetMix("A320=1,XXXX=1"); err == nil {
+		t.Errorf("unknown aircraft type in fleet mix: got no error")
+	}
+	if _, err := ParseFleetMix("A320=0"); err == nil {
+		t.Errorf("empty fleet mix: got no error")
+	}
+	mix, err := ParseFleetMix("a320=3, C172=1, B77W=0")
+	if err != nil {
+		t.Fatalf("ParseFleetMix: %v", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for range 4000 {
+		counts[drawAircraftType(mix, r).Designator]++
+	}
+	if counts["B77W"] != 0 || math.Abs(float64(counts["A320"])/4000-0.75) > 0.03 {
+		t.Errorf("fleet drawn from %v: got %v, want about 3000 A320, 1000 C172 and no B77W", mix, counts)
+	}
+}
+
+// TestScenario checks that the bundled scenarios load into the declared world
+// and that inconsistent scenarios are rejected.
+func TestScenario(t *testing.T) {
+	scenario, err := LoadScenario("../../scenarios/overtaking_faulty_tcas.json")
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+	simState := &SimulationState{Quiet: true}
+	InitializeScenario(&config.Config{}, simState, scenario)
+	if simState.Seed != 1 || simState.Scenario != scenario || len(simState.Airports) != 2 {
+		t.Fatalf("scenario world: seed %d, %d airports, want seed 1 and 2 airports", simState.Seed, len(simState.Airports))
+	}
+	if home := simState.Airports[0]; home.Serial != "AP_A001" || len(home.Planes) != 2 || home.Runway.numberOfRunway != 1 {
+		t.Fatalf("home airport %s has %d planes and %d runways, want AP_A001 with 2 planes and 1 runway",
+			home.Serial, len(home.Planes), home.Runway.numberOfRunway)
+	}
+	if plane := simState.Airports[0].Planes[1]; plane.AircraftType.Designator != "B77W" || plane.TCASCapability != TCASFaulty {
+		t.Errorf("plane %s: got type %s with TCAS %v, want a B77W with a faulty TCAS", plane.Serial, plane.AircraftType.Designator, plane.TCASCapability)
+	}
+	if departure := scenario.Timetable()[1].Departure; time.Duration(departure) != 500*time.Second {
+		t.Errorf("second departure at %v, want 8m20s", time.Duration(departure))
+	}
+
+	tests := []struct {
+		name     string
+		scenario string
+	}{
+		{name: "unknown aircraft type", scenario: `{"airports": [{"serial": "A", "location": {"x": 0, "y": 0}, "runways": 1}, {"serial": "B", "location": {"x": 1, "y": 0}, "runways": 1}],
+			"planes": [{"serial": "P", "type": "XXXX", "home": "A"}]}`},
+		{name: "departs from its destination", scenario: `{"airports": [{"serial": "A", "location": {"x": 0, "y": 0}, "runways": 1}, {"serial": "B", "location": {"x": 1, "y": 0}, "runways": 1}],
+			"planes": [{"serial": "P", "type": "A320", "home": "A"}],
+			"flights": [{"plane": "P", "departure": "0s", "destination": "B"}, {"plane": "P", "departure": "1h", "destination": "B"}]}`},
+		{name: "latitude without origin", scenario: `{"airports": [{"serial": "A", "location": {"latitude": 0, "longitude": 0}, "runways": 1}, {"serial": "B", "location": {"x": 1, "y": 0}, "runways": 1}]}`},
+		{name: "invalid departure", scenario: `{"airports": [{"serial": "A", "location": {"x": 0, "y": 0}, "runways": 1}, {"serial": "B", "location": {"x": 1, "y": 0}, "runways": 1}],
+			"planes": [{"serial": "P", "type": "A320", "home": "A"}], "flights": [{"plane": "P", "departure": "soon", "destination": "B"}]}`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "scenario.json")
+		if err := os.WriteFile(path, []byte(tt.scenario), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadScenario(path); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+func TestExportScenario(t *testing.T) {
+	for _, geodetic := range []bool{false, true} {
+		world := &SimulationState{Quiet: true}
+		InitializeAirports(&config.Config{NoOfAirplanes: 12, Seed: 7, FaultyTCASRatio: 0.5, PilotVariation: 0.2,
+			Geodetic: geodetic, OriginLatitude: 51.47, OriginLongitude: -0.45}, world)
+
+		path := filepath.Join(t.TempDir(), "saved.json")
+		if err := SaveScenario(world.ExportScenario("saved"), path); err != nil {
+			t.Fatalf("SaveScenario: %v", err)
+		}
+		scenario, err := LoadScenario(path)
+		if err != nil {
+			t.Fatalf("LoadScenario of a saved world: %v", err)
+		}
+		loaded := &SimulationState{Quiet: true}
+		InitializeScenario(&config.Config{}, loaded, scenario)
+
+		if loaded.Seed != world.Seed || len(loaded.Airports) != len(world.Airports) {
+			t.Fatalf("geodetic %v: loaded seed %d with %d airports, want seed %d with %d airports",
+				geodetic, loaded.Seed, len(loaded.Airports), world.Seed, len(world.Airports))
+		}
+		for i, airport := range world.Airports {
+			got := loaded.Airports[i]
+			if got.Serial != airport.Serial || Distance(got.Location, airport.Location) > 1e-3 ||
+				got.Runway.numberOfRunway != airport.Runway.numberOfRunway || len(got.Planes) != len(airport.Planes) {
+				t.Errorf("geodetic %v: airport %s at %s with %d runways and %d planes, want %s at %s with %d runways and %d planes",
+					geodetic, got.Serial, got.Location.String(), got.Runway.numberOfRunway, len(got.Planes),
+					airport.Serial, airport.Location.String(), airport.Runway.numberOfRunway, len(airport.Planes))
+			}
+		}
+		want, got := world.AllPlanes(), loaded.AllPlanes()
+		for i := range want {
+			if got[i].Serial != want[i].Serial || got[i].AircraftType != want[i].AircraftType || got[i].TCASCapability != want[i].TCASCapability {
+				t.Errorf("geodetic %v: plane %s is a %s with TCAS %v, want %s a %s with TCAS %v", geodetic,
+					got[i].Serial, got[i].AircraftType.Designator, got[i].TCASCapability,
+					want[i].Serial, want[i].AircraftType.Designator, want[i].TCASCapability)
+			}
+			// the crews drawn around the standard one are saved with their planes
+			if got[i].Pilot.InitialResponseDelay != want[i].Pilot.InitialResponseDelay ||
+				!FloatEquals(got[i].Pilot.StrengtheningAcceleration, want[i].Pilot.StrengtheningAcceleration) ||
+				!FloatEquals(got[i].Pilot.StrengthenedVerticalRate, want[i].Pilot.StrengthenedVerticalRate) {
+				t.Errorf("geodetic %v: plane %s crew %#v, want %#v", geodetic, got[i].Serial, got[i].Pilot, want[i].Pilot)
+			}
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	simState := &SimulationState{Quiet: true}
+	InitializeAirports(&config.Config{NoOfAirplanes: 4, Seed: 3}, simState)
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	departure, destination := simState.Airports[0], simState.Airports[1]
+	plane := departure.Planes[0]
+	departure.Planes = departure.Planes[1:]
+	plane.PlaneInFlight = true
+	plane.FlightLog = []Flight{{
+		FlightID:               plane.Serial + "F_A000",
+		FlightSchedule:         FlightPath{Depature: departure.Location, Destination: destination.Location},
+		TakeoffTime:            start,
+		DestinationArrivalTime: start.Add(time.Hour),
+		DepatureAirPort:        departure.Serial,
+		ArrivalAirPort:         destination.Serial,
+		FlightStatus:           "in transit",
+	}}
+	plane.TCASEngagementRecords = []TCASEngagement{{EngagementID: plane.Serial + "F_A000E_A001", FlightID: plane.Serial + "F_A000",
+		PlaneSerial: plane.Serial, OtherPlaneSerial: "P_X", Advisory: AdvisoryRA, Sense: SenseDescend, TATime: start.Add(time.Minute)}}
+	simState.PlanesInFlight = []Plane{plane}
+
+	dir := t.TempDir()
+	if err := simState.ExportCSV(dir); err != nil {
+		t.Fatalf("ExportCSV: %v", err)
+	}
+	tests := []struct {
+		file   string
+		header []string
+		rows   int
+	}{
+		{"airports.csv", airportsCSVHeader, len(simState.Airports)},
+		{"planes.csv", planesCSVHeader, 4},
+		{"flights.csv", flightsCSVHeader, 1},
+		{"engagements.csv", engagementsCSVHeader, 1},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if len(records) != tt.rows+1 || strings.Join(records[0], ",") != strings.Join(tt.header, ",") {
+			t.Errorf("%s has %d rows with header %v, want %d rows with header %v", tt.file, len(records)-1, records[0], tt.rows, tt.header)
+		}
+		if tt.file == "engagements.csv" && (records[1][4] != "RA" || records[1][8] != "descend" || records[1][7] != "") {
+			t.Errorf("engagement row %v, want an RA to descend without an RA time", records[1])
+		}
+	}
+}
+
+func TestExportMaps(t *testing.T) {
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	simState := &SimulationState{
+		Frame: &ENUFrame{Origin: GeoCoordinate{Latitude: 51.47, Longitude: -0.45}},
+		Airports: []*Airport{
+			{Serial: "AP_A001", Location: Coordinate{}, Runway: runway{numberOfRunway: 1}},
+			{Serial: "AP_A002", Location: Coordinate{X: 20000}, Runway: runway{numberOfRunway: 2}},
+		},
+	}
+	simState.Airports[1].Planes = []Plane{{
+		Serial: "P_A001",
+		FlightLog: []Flight{{
+			FlightID:        "P_A001F_A000",
+			DepatureAirPort: "AP_A001",
+			ArrivalAirPort:  "AP_A002",
+			Track: []TrackPoint{
+				{Position: Coordinate{}, Time: start},
+				{Position: Coordinate{X: 10000, Z: 3000}, Time: start.Add(time.Minute)},
+				{Position: Coordinate{X: 20000}, Time: start.Add(2 * time.Minute)},
+			},
+		}},
+		TCASEngagementRecords: []TCASEngagement{{EngagementID: "P_A001F_A000E_A001", FlightID: "P_A001F_A000", PlaneSerial: "P_A001",
+			OtherPlaneSerial: "P_A002", Advisory: AdvisoryRA, TimeOfEngagement: start.Add(30 * time.Second), ClosedTime: start.Add(time.Minute)}},
+	}}
+
+	if flight, plane, ok := simState.FindFlight("p_a001f_a000"); !ok || plane != "P_A001" || len(flight.Track) != 3 {
+		t.Errorf("FindFlight found %t a flight of plane %q with %d track points, want P_A001 with 3", ok, plane, len(flight.Track))
+	}
+
+	var geoJSON bytes.Buffer
+	if err := simState.ExportGeoJSON(&geoJSON); err != nil {
+		t.Fatalf("ExportGeoJSON: %v", err)
+	}
+	var collection struct {
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(geoJSON.Bytes(), &collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+	if len(collection.Features) != 4 {
+		t.Fatalf("GeoJSON has %d features, want 2 airports, 1 flight and 1 engagement", len(collection.Features))
+	}
+	if flight := collection.Features[2]; flight.Geometry.Type != "LineString" || len(flight.Properties["coordTimes"].([]any)) != 3 {
+		t.Errorf("flight feature %s with properties %v, want a line string of 3 timed positions", flight.Geometry.Type, flight.Properties)
+	}
+	var location []float64
+	engagement := collection.Features[3]
+	json.Unmarshal(engagement.Geometry.Coordinates, &location)
+	if engagement.Properties["outcome"] != OutcomeResolved || len(location) != 3 || !FloatEquals(math.Round(location[2]), 1500) {
+		t.Errorf("engagement %v at %v, want a resolved RA halfway up the climb at 1500 m", engagement.Properties, location)
+	}
+
+	var kml bytes.Buffer
+	if err := simState.ExportKML(&kml); err != nil {
+		t.Fatalf("ExportKML: %v", err)
+	}
+	decoder := xml.NewDecoder(&kml)
+	elements := map[string]int{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid KML: %v", err)
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			elements[element.Name.Local]++
+		}
+	}
+	if elements["Placemark"] != 4 || elements["Track"] != 1 || elements["coord"] != 3 || elements["when"] != 4 {
+		t.Errorf("KML elements %v, want 4 placemarks and a track of 3 timed coordinates", elements)
+	}
+}
+
+func TestReport(t *testing.T) {
+	start := time.Date(2025, time.June, 19, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	simState := &SimulationState{
+		Seed: 7,
+		Airports: []*Airport{
+			{Serial: "AP_A001", Runway: runway{numberOfRunway: 1}},
+			{Serial: "AP_A002", Location: Coordinate{X: 20000}, Runway: runway{numberOfRunway: 2}},
+		},
+	}
+	simState.Airports[1].Planes = []Plane{{
+		Serial:         "P_A001",
+		TCASCapability: TCASPerfect,
+		FlightLog: []Flight{{FlightID: "P_A001F_A000", DepatureAirPort: "AP_A001", ArrivalAirPort: "AP_A002",
+			TakeoffTime: start, ActualLandingTime: start.Add(30 * time.Minute)}},
+		TCASEngagementRecords: []TCASEngagement{
+			{FlightID: "P_A001F_A000", PlaneSerial: "P_A001", OtherPlaneSerial: "P_A002", Advisory: AdvisoryTA, ClosedTime: start.Add(5 * time.Minute)},
+			{FlightID: "P_A001F_A000", PlaneSerial: "P_A001", OtherPlaneSerial: "P_A002", Advisory: AdvisoryRA, Collided: true},
+		},
+	}}
+	simState.PlanesInFlight = []Plane{{
+		Serial:         "P_A002",
+		TCASCapability: TCASFaulty,
+		FlightLog: []Flight{{FlightID: "P_A002F_A000", DepatureAirPort: "AP_A002", ArrivalAirPort: "AP_A001",
+			TakeoffTime: start.Add(40 * time.Minute)}},
+		CurrentTCASEngagements: []TCASEngagement{
+			{FlightID: "P_A002F_A000", PlaneSerial: "P_A002", OtherPlaneSerial: "P_A001", Advisory: AdvisoryRA},
+		},
+	}}
+	collision := TCASEngagement{FlightID: "P_A001F_A000", PlaneSerial: "P_A001", OtherPlaneSerial: "P_A002",
+		TimeOfEngagement: start.Add(20 * time.Minute), Advisory: AdvisoryRA, MissDistance: 12, Collided: true}
+	mirrored := collision
+	mirrored.PlaneSerial, mirrored.OtherPlaneSerial = collision.OtherPlaneSerial, collision.PlaneSerial
+	simState.Collisions = []TCASEngagement{collision, mirrored}
+
+	recorder := NewReportRecorder()
+	for _, event := range []Event{
+		RunwayBusy{Time: start, Plane: "P_A001", Airport: "AP_A001", Operation: "takeoff"},
+		RunwayBusy{Time: start.Add(time.Minute), Plane: "P_A001", Airport: "AP_A001", Operation: "takeoff"},
+		TakeoffStarted{Time: start.Add(2 * time.Minute), Plane: "P_A001", Airport: "AP_A001"},
+		TakeoffCompleted{Time: start.Add(2*time.Minute + TakeoffDuration), Plane: "P_A001", Airport: "AP_A001"},
+		LandingCompleted{Time: start.Add(30 * time.Minute), Plane: "P_A001", Airport: "AP_A002"},
+		TakeoffStarted{Time: start.Add(40 * time.Minute), Plane: "P_A002", Airport: "AP_A002"},
+		TakeoffCompleted{Time: start.Add(40*time.Minute + TakeoffDuration), Plane: "P_A002", Airport: "AP_A002"},
+	} {
+		recorder.Record(event)
+	}
+	report := simState.BuildReport(recorder, start, end, "collision")
+
+	if report.Planes != 2 || report.Flights != 2 || report.FlightsCompleted != 1 || report.FlightTime != 50*time.Minute {
+		t.Errorf("report has %d planes, %d flights, %d completed in %v, want 2 planes, 2 flights, 1 completed in 50m",
+			report.Planes, report.Flights, report.FlightsCompleted, report.FlightTime)
+	}
+	wantEngagements := []ReportEngagements{
+		{Advisory: AdvisoryTA, Total: 1, Cleared: 1},
+		{Advisory: AdvisoryRA, Total: 2, Collided: 1, Open: 1},
+	}
+	if fmt.Sprint(report.Engagements) != fmt.Sprint(wantEngagements) {
+		t.Errorf("engagements %v, want %v", report.Engagements, wantEngagements)
+	}
+	if len(report.Crashes) != 1 || report.Crashes[0].PlaneTCAS != TCASPerfect || report.Crashes[0].IntruderTCAS != TCASFaulty {
+		t.Errorf("crashes %+v, want the collision of P_A001 with P_A002 once", report.Crashes)
+	}
+	wantPairs := []ReportCapabilityPair{
+		{Own: TCASPerfect, Intruder: TCASFaulty, Engagements: 2, ResolutionAdvisories: 1, Collisions: 1},
+		{Own: TCASFaulty, Intruder: TCASPerfect, Engagements: 1, ResolutionAdvisories: 1},
+	}
+	if fmt.Sprint(report.CapabilityPairs) != fmt.Sprint(wantPairs) {
+		t.Errorf("capability pairs %v, want %v", report.CapabilityPairs, wantPairs)
+	}
+	departure, arrival := report.Airports[0], report.Airports[1]
+	if departure.Takeoffs != 1 || departure.DelayedTakeoffs != 1 || departure.MeanGroundDelay != 2*time.Minute ||
+		!FloatEquals(departure.RunwayUtilization, TakeoffDuration.Hours()) {
+		t.Errorf("departure airport %+v, want 1 takeoff delayed 2m using its runway for %v", departure, TakeoffDuration)
+	}
+	if arrival.Landings != 1 || arrival.DelayedTakeoffs != 0 || arrival.MovementsPerHour != 2 ||
+		!FloatEquals(arrival.RunwayUtilization, (TakeoffDuration+LandingDuration).Hours()/2) {
+		t.Errorf("arrival airport %+v, want 2 movements on 2 runways and no delay", arrival)
+	}
+
+	for _, tt := range []struct {
+		format string
+		write  func(io.Writer) error
+		want   []string
+	}{
+		{"text", report.WriteText, []string{"TCAS simulation report", "Ended by:", "AP_A001", "perfect / faulty"}},
+		{"Markdown", report.WriteMarkdown, []string{"# TCAS simulation report", "| Airport | Runways |", `P\_A001`}},
+		{"HTML", report.WriteHTML, []string{"<h1>TCAS simulation report</h1>", "<td>AP_A002</td>", "</html>"}},
+	} {
+		var out bytes.Buffer
+		if err := tt.write(&out); err != nil {
+			t.Fatalf("%s report: %v", tt.format, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s report does not contain %q:\n%s", tt.format, want, out.String())
+			}
+		}
+	}
+}
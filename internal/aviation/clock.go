@@ -0,0 +1,420 @@
+package aviation
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a simulation run. Every goroutine of the simulation
+// reads the time, sleeps and schedules callbacks through it, so the whole simulation
+// can be run faster (or slower) than wall-clock time.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a scheduled callback returned by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the Timer from firing. It returns false if the timer already fired or was stopped.
+	Stop() bool
+}
+
+// MaxSpeed is the clock speed meaning "as fast as possible": simulated time jumps straight
+// to the next scheduled wake-up as soon as the simulation goroutines go idle.
+const MaxSpeed = 0.0
+
+// WallClock is a Clock backed directly by the time package.
+type WallClock struct{}
+
+// Now returns the current wall-clock time.
+func (WallClock) Now() time.Time { return time.Now() }
+
+// Sleep pauses the current goroutine for d of wall-clock time.
+func (WallClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// After waits for d of wall-clock time and then sends the current time on the returned channel.
+func (WallClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// AfterFunc calls f in its own goroutine after d of wall-clock time.
+func (WallClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// SimClock is a virtual Clock whose time advances at a multiple of wall-clock time,
+// or as fast as possible when its speed is MaxSpeed. A paused SimClock stands still,
+// so every goroutine sleeping on it waits until it is resumed or stepped.
+//
+// Whatever its speed, a SimClock runs the simulation in lockstep: simulated time moves on in jumps to the
+// next scheduled wake-up, once the goroutine it woke last waits on it again, so that a run goes the same way
+// however fast it is shown and however loaded the machine is. The speed only sets when, in wall time, each jump is made.
+type SimClock struct {
+	mu       sync.Mutex
+	speed    float64
+	simBase  time.Time // current simulated time, moved on in jumps to the timers' due times
+	wallBase time.Time // wall time simBase was due at for the clock to keep its speed
+	pausedAt time.Time // wall time the clock was paused at
+	paused   bool
+	ended    bool          // simulated time stopped for good, see End
+	stepTo   time.Time     // simulated time a Step of the paused clock runs to
+	stepDone chan struct{} // closed once the running Step reached stepTo, nil when not stepping
+	timers   clockTimerHeap
+	seq      uint64
+	held     uint64 // seq of the timer whose goroutine runs until it waits on the clock again or returns, 0 when none
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// clockTimer is a pending wake-up on a SimClock.
+type clockTimer struct {
+	clock   *SimClock
+	when    time.Time
+	seq     uint64 // keeps timers due at the same instant in scheduling order
+	ch      chan time.Time
+	f       func()
+	index   int
+	stopped bool
+}
+
+// SimulationEpoch is the simulated time every simulation starts at, so that runs of the same seed
+// log the same times whenever they are run.
+var SimulationEpoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// NewSimClock returns a running SimClock starting at start and advancing speed times faster than wall-clock time.
+// Call Stop once the simulation is over to release its scheduler goroutine.
+func NewSimClock(start time.Time, speed float64) *SimClock {
+	if speed < 0 {
+		speed = 1
+	}
+	c := &SimClock{
+		speed:    speed,
+		simBase:  start,
+		wallBase: time.Now(),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Speed returns the clock's speed multiplier, MaxSpeed meaning as fast as possible.
+func (c *SimClock) Speed() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.speed
+}
+
+// Now returns the current simulated time.
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.simBase
+}
+
+// Sleep pauses the current goroutine for d of simulated time.
+func (c *SimClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// After waits for d of simulated time and then sends the simulated time on the returned channel.
+// The caller is taken to wait on the channel right away, as Sleep does: time may go on once it is called.
+func (c *SimClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(d, ch, nil)
+	return ch
+}
+
+// AfterFunc calls f in its own goroutine after d of simulated time.
+func (c *SimClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, nil, f)
+}
+
+// Stop halts the clock's scheduler; pending timers never fire afterwards.
+func (c *SimClock) Stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+// End stops simulated time for good at the current time: the timers still pending fire right away,
+// in order, at that time, so that the goroutines of a run that is over wind down without time going on.
+func (c *SimClock) End() {
+	c.mu.Lock()
+	c.ended = true
+	if c.stepDone != nil {
+		close(c.stepDone)
+		c.stepDone = nil
+	}
+	c.mu.Unlock()
+	c.poke()
+}
+
+// Pause freezes simulated time; no timer fires until the clock is resumed or stepped.
+func (c *SimClock) Pause() {
+	c.mu.Lock()
+	if !c.paused {
+		c.pausedAt = time.Now()
+		c.paused = true
+	}
+	c.mu.Unlock()
+	c.poke()
+}
+
+// Resume lets a paused clock run again at its speed, from the simulated time it was paused at.
+func (c *SimClock) Resume() {
+	c.mu.Lock()
+	if c.paused {
+		// the time spent paused does not count towards the next jump
+		c.wallBase = c.wallBase.Add(time.Since(c.pausedAt))
+		c.paused = false
+	}
+	if c.stepDone != nil {
+		// the clock runs on from wherever the step got to
+		close(c.stepDone)
+		c.stepDone = nil
+	}
+	c.mu.Unlock()
+	c.poke()
+}
+
+// Paused reports whether the clock is paused.
+func (c *SimClock) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Step advances a paused clock by d of simulated time as fast as possible, firing the timers due
+// on the way in order, and returns once it got there with the clock still paused.
+func (c *SimClock) Step(d time.Duration) error {
+	c.mu.Lock()
+	if !c.paused {
+		c.mu.Unlock()
+		return errors.New("the clock must be paused to step it")
+	}
+	if c.ended {
+		c.mu.Unlock()
+		return errors.New("the clock has ended")
+	}
+	if c.stepDone != nil {
+		c.mu.Unlock()
+		return errors.New("the clock is already stepping")
+	}
+	done := make(chan struct{})
+	c.stepTo = c.simBase.Add(d)
+	c.stepDone = done
+	c.mu.Unlock()
+	c.poke()
+
+	select {
+	case <-done:
+	case <-c.done:
+	}
+	return nil
+}
+
+// Stop prevents the timer from firing.
+func (t *clockTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.stopped || t.index < 0 {
+		return false
+	}
+	t.stopped = true
+	heap.Remove(&t.clock.timers, t.index)
+	return true
+}
+
+// advanceLocked moves simulated time on to t, keeping the clock's pace; c.mu must be held.
+func (c *SimClock) advanceLocked(t time.Time) {
+	switch {
+	case c.paused:
+		// a step: once resumed, the clock goes on at its speed from t
+		c.wallBase = c.pausedAt
+	case c.speed != MaxSpeed:
+		c.wallBase = c.wallBase.Add(time.Duration(float64(t.Sub(c.simBase)) / c.speed))
+	}
+	c.simBase = t
+}
+
+// schedule registers a new timer due d from now and wakes the scheduler.
+func (c *SimClock) schedule(d time.Duration, ch chan time.Time, f func()) *clockTimer {
+	c.mu.Lock()
+	c.seq++
+	t := &clockTimer{clock: c, when: c.simBase.Add(d), seq: c.seq, ch: ch, f: f}
+	heap.Push(&c.timers, t)
+	if ch != nil {
+		// the caller now waits on the clock. During a run every goroutine calling the clock was woken by it,
+		// and they run one at a time, so the caller is the goroutine woken last: time may go on.
+		// An AfterFunc leaves its caller running, so it holds the clock still.
+		c.held = 0
+	}
+	c.mu.Unlock()
+
+	c.poke()
+	return t
+}
+
+// poke wakes the scheduler so that it recomputes its next wake-up.
+func (c *SimClock) poke() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fireDueLocked fires the first timer due at or before the current time, or the first timer once the clock
+// has ended; c.mu must be held. The goroutine it wakes holds the clock until it waits on it again or its
+// AfterFunc returns, so that the goroutines woken at the same instant run one after the other in scheduling order.
+func (c *SimClock) fireDueLocked() {
+	if len(c.timers) == 0 || (!c.ended && c.timers[0].when.After(c.simBase)) {
+		return
+	}
+	t := heap.Pop(&c.timers).(*clockTimer)
+	c.held = t.seq
+	if t.ch != nil {
+		t.ch <- c.simBase
+	}
+	if t.f != nil {
+		go func() {
+			t.f()
+			c.release()
+		}()
+	}
+}
+
+// release lets the clock go on once an AfterFunc has returned; it held the clock
+// whenever it ran, waking up from the clock's timers.
+func (c *SimClock) release() {
+	c.mu.Lock()
+	c.held = 0
+	c.mu.Unlock()
+	c.poke()
+}
+
+// run is the scheduler goroutine; it fires timers as simulated time reaches them.
+// It waits for the goroutine it woke last however long it runs: simulation goroutines only block on the
+// clock, or briefly on locks, and one blocked on something else would rather stall the clock than let
+// the run go a different way.
+func (c *SimClock) run() {
+	for {
+		c.mu.Lock()
+		if c.held == 0 {
+			c.fireDueLocked()
+		}
+		wait := time.Duration(-1)
+		switch {
+		case c.held != 0:
+			// time moves on once the goroutine woken last waits on the clock again or returns
+		case c.ended:
+			// every timer fires as soon as it is scheduled
+		case c.stepDone != nil:
+			// stepping runs at max speed up to the step's end, even without timers
+			wait = 0
+		case c.paused:
+			// nothing fires until the clock is resumed or stepped
+		case len(c.timers) > 0:
+			wait = max(time.Until(c.dueLocked()), 0)
+		}
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-c.wake:
+			// a new timer was scheduled; recompute the next wake-up
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-timeout:
+			c.mu.Lock()
+			switch {
+			case c.held != 0:
+				// the goroutine woken last is still running; time moves on once it is done
+			case c.ended:
+				// time never moves on
+			case c.stepDone != nil && len(c.timers) > 0 && !c.timers[0].when.After(c.stepTo):
+				// the simulation went idle during a step: jump to the next wake-up within it
+				c.advanceLocked(c.timers[0].when)
+			case c.stepDone != nil:
+				// nothing is left to fire before the end of the step
+				c.advanceLocked(c.stepTo)
+				close(c.stepDone)
+				c.stepDone = nil
+			case !c.paused && len(c.timers) > 0 && c.timers[0].when.After(c.simBase) && !time.Now().Before(c.dueLocked()):
+				// the simulation went idle and the next wake-up is due: jump straight to it
+				c.advanceLocked(c.timers[0].when)
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// dueLocked returns the wall time simulated time is to jump to the next wake-up at,
+// right away at max speed; c.mu must be held and a timer pending.
+func (c *SimClock) dueLocked() time.Time {
+	if c.speed == MaxSpeed {
+		return time.Time{}
+	}
+	return c.wallBase.Add(time.Duration(float64(c.timers[0].when.Sub(c.simBase)) / c.speed))
+}
+
+// clockTimerHeap orders pending timers by due time.
+type clockTimerHeap []*clockTimer
+
+func (h clockTimerHeap) Len() int { return len(h) }
+func (h clockTimerHeap) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].when.Before(h[j].when)
+}
+func (h clockTimerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *clockTimerHeap) Push(x any) {
+	t := x.(*clockTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *clockTimerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+// ParseClockSpeed parses a speed multiplier such as "1", "10x", "100X" or "max".
+func ParseClockSpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return MaxSpeed, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed %q: use a positive multiplier such as 10x, or max", s)
+	}
+	return speed, nil
+}
+
+// FormatClockSpeed returns a human readable form of a clock speed.
+func FormatClockSpeed(speed float64) string {
+	if speed == MaxSpeed {
+		return "max speed"
+	}
+	return strconv.FormatFloat(speed, 'f', -1, 64) + "x"
+}
@@ -0,0 +1,87 @@
+package aviation
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ClosestApproach describes the closest point of approach (CPA) of two flights: where both planes
+// are, at the same instant, when they are nearest to each other.
+type ClosestApproach struct {
+	Time           time.Time     // when the CPA happens
+	TimeToCPA      time.Duration // time from the reference time of the computation to the CPA
+	HorizontalMiss float64       // horizontal distance between the planes at the CPA, in meters
+	VerticalMiss   float64       // altitude difference between the planes at the CPA, in meters
+	Position1      Coordinate    // position of the first plane at the CPA
+	Position2      Coordinate    // position of the second plane at the CPA
+}
+
+// MissDistance returns the 3D distance between the planes at the CPA, in meters.
+func (ca ClosestApproach) MissDistance() float64 {
+	return Distance(ca.Position1, ca.Position2)
+}
+
+// ClosestApproach computes the closest point of approach between two flights from the reference time on.
+//
+// Both planes are followed along their 4D trajectories over the window in which both are airborne, so
+// the CPA is computed on their relative position and velocity at the same instant: two planes crossing
+// the same point at different times are not in conflict there. Between waypoints both planes fly straight
+// at constant velocity, so the window is split at every waypoint and the CPA of each piece is found
+// analytically. It returns false if the flights are never airborne together after the reference time.
+func (f1 Flight) ClosestApproach(f2 Flight, reference time.Time) (ClosestApproach, bool) {
+	start := latestTime(reference, f1.TakeoffTime, f2.TakeoffTime)
+	end := f1.DestinationArrivalTime
+	if f2.DestinationArrivalTime.Before(end) {
+		end = f2.DestinationArrivalTime
+	}
+	if end.Before(start) {
+		return ClosestApproach{}, false
+	}
+
+	breakpoints := []time.Time{start, end}
+	for _, trajectory := range [][]Waypoint{f1.trajectory(), f2.trajectory()} {
+		for _, waypoint := range trajectory {
+			if waypoint.Time.After(start) && waypoint.Time.Before(end) {
+				breakpoints = append(breakpoints, waypoint.Time)
+			}
+		}
+	}
+	sort.Slice(breakpoints, func(i, j int) bool { return breakpoints[i].Before(breakpoints[j]) })
+
+	bestTime, bestDistance := start, math.Inf(1)
+	for i := 0; i < len(breakpoints)-1; i++ {
+		from, to := breakpoints[i], breakpoints[i+1]
+		position1, position2 := f1.PositionAt(from), f2.PositionAt(from)
+		var velocity1, velocity2 Coordinate
+		if seconds := to.Sub(from).Seconds(); seconds > 0 {
+			velocity1 = f1.PositionAt(to).subtract(position1).mulScalar(1 / seconds)
+			velocity2 = f2.PositionAt(to).subtract(position2).mulScalar(1 / seconds)
+		}
+		offset, distance := closestApproachWithin(to.Sub(from), position1, velocity1, position2, velocity2)
+		if distance < bestDistance-Epsilon {
+			bestTime, bestDistance = from.Add(offset), distance
+		}
+	}
+
+	position1, position2 := f1.PositionAt(bestTime), f2.PositionAt(bestTime)
+	return ClosestApproach{
+		Time:           bestTime,
+		TimeToCPA:      bestTime.Sub(reference),
+		HorizontalMiss: math.Hypot(position2.X-position1.X, position2.Y-position1.Y),
+		VerticalMiss:   math.Abs(position2.Z - position1.Z),
+		Position1:      position1,
+		Position2:      position2,
+	}, true
+}
+
+// latestTime returns the latest of the given times.
+func latestTime(first time.Time, others ...time.Time) time.Time {
+	latest := first
+	for _, t := range others {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
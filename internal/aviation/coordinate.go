@@ -7,10 +7,12 @@ import (
 
 // Most functions here are helpers to implement the finding of closest approach between flight paths
 
-// Coordinate represents a 3D Coordinate
+// Coordinate represents a 3D Coordinate in meters, Z being the altitude
 // may be changed to latitude logitude altitude
 type Coordinate struct {
-	X, Y, Z float64
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
 }
 
 // Coordinate.String() helper for better print output
@@ -0,0 +1,327 @@
+package aviation
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventKind names a kind of simulation event.
+type EventKind string
+
+// The kinds of events published during a simulation.
+const (
+	KindTakeoffStarted   EventKind = "takeoff_started"
+	KindTakeoffCompleted EventKind = "takeoff_completed"
+	KindLandingRequested EventKind = "landing_requested"
+	KindLandingCompleted EventKind = "landing_completed"
+	KindRunwayBusy       EventKind = "runway_busy"
+	KindPositionUpdate   EventKind = "position_update"
+	KindTCASAdvisory     EventKind = "tcas_advisory"
+	KindCollisionAverted EventKind = "collision_averted"
+	KindCollision        EventKind = "collision"
+	KindSimulationEnded  EventKind = "simulation_ended"
+)
+
+// EventKinds lists every kind of event, in the order of a flight's life.
+var EventKinds = []EventKind{KindTakeoffStarted, KindTakeoffCompleted, KindLandingRequested, KindLandingCompleted,
+	KindRunwayBusy, KindPositionUpdate, KindTCASAdvisory, KindCollisionAverted, KindCollision, KindSimulationEnded}
+
+// Event is something that happened during a simulation, as published on the simulation's EventBus.
+// Every kind of event is its own type: subscribers switch on the type to read its details.
+type Event interface {
+	// Kind names the kind of the event.
+	Kind() EventKind
+	// When returns the simulation time at which the event happened.
+	When() time.Time
+}
+
+// TakeoffStarted is published when a plane is given a runway and starts its takeoff roll.
+type TakeoffStarted struct {
+	Time     time.Time  `json:"time"`
+	Plane    string     `json:"plane"`
+	Airport  string     `json:"airport"`
+	Position Coordinate `json:"position"`
+}
+
+// TakeoffCompleted is published when a plane is airborne on its way to its destination.
+type TakeoffCompleted struct {
+	Time             time.Time  `json:"time"`
+	Plane            string     `json:"plane"`
+	FlightID         string     `json:"flight_id"`
+	Airport          string     `json:"airport"`
+	Destination      string     `json:"destination"`
+	Position         Coordinate `json:"position"`
+	CruisingAltitude float64    `json:"cruising_altitude"` // meters
+	ArrivalTime      time.Time  `json:"arrival_time"`      // scheduled landing time
+}
+
+// LandingRequested is published when a plane reaching its destination asks for a runway.
+type LandingRequested struct {
+	Time     time.Time  `json:"time"`
+	Plane    string     `json:"plane"`
+	FlightID string     `json:"flight_id"`
+	Airport  string     `json:"airport"`
+	Position Coordinate `json:"position"`
+}
+
+// LandingCompleted is published when a plane has landed and is parked at its destination.
+type LandingCompleted struct {
+	Time     time.Time  `json:"time"`
+	Plane    string     `json:"plane"`
+	FlightID string     `json:"flight_id"`
+	Airport  string     `json:"airport"`
+	Position Coordinate `json:"position"`
+}
+
+// RunwayBusy is published every time a plane has to wait for a runway, to take off or to land.
+type RunwayBusy struct {
+	Time         time.Time  `json:"time"`
+	Plane        string     `json:"plane"`
+	Airport      string     `json:"airport"`
+	Position     Coordinate `json:"position"`
+	Operation    string     `json:"operation"` // "takeoff" or "landing"
+	RunwaysInUse int        `json:"runways_in_use"`
+	Runways      int        `json:"runways"`
+}
+
+// PositionUpdate is published every time a plane in flight records a point of its track: once every
+// track interval and whenever the advisory active on board changes.
+type PositionUpdate struct {
+	Time     time.Time    `json:"time"`
+	Plane    string       `json:"plane"`
+	FlightID string       `json:"flight_id"`
+	Position Coordinate   `json:"position"`
+	Velocity Coordinate   `json:"velocity"` // meters per second
+	Advisory AdvisoryType `json:"advisory"` // highest advisory active on board
+	Sense    RASense      `json:"sense"`    // sense of the active Resolution Advisory, SenseNone without one
+}
+
+// TCASAdvisory is published when a plane's TCAS issues a Traffic or Resolution Advisory against an intruder,
+// and again when it strengthens a Resolution Advisory.
+type TCASAdvisory struct {
+	Time                time.Time    `json:"time"`
+	Plane               string       `json:"plane"`
+	Intruder            string       `json:"intruder"`
+	FlightID            string       `json:"flight_id"`
+	EngagementID        string       `json:"engagement_id"`
+	Position            Coordinate   `json:"position"`
+	IntruderPosition    Coordinate   `json:"intruder_position"`
+	Advisory            AdvisoryType `json:"advisory"`
+	SensitivityLevel    int          `json:"sensitivity_level"`
+	Sense               RASense      `json:"sense"` // SenseNone for a Traffic Advisory
+	Coordinated         bool         `json:"coordinated"`
+	PilotResponse       RASense      `json:"pilot_response"`
+	Strengthened        bool         `json:"strengthened"`
+	MissDistance        float64      `json:"miss_distance"` // predicted distance at the closest approach in meters
+	ClosestApproachTime time.Time    `json:"closest_approach_time"`
+}
+
+// CollisionAverted is published when a plane's Resolution Advisory ends with the intruder clear of conflict.
+type CollisionAverted struct {
+	Time         time.Time  `json:"time"`
+	Plane        string     `json:"plane"`
+	Intruder     string     `json:"intruder"`
+	FlightID     string     `json:"flight_id"`
+	EngagementID string     `json:"engagement_id"`
+	Position     Coordinate `json:"position"`
+	MissDistance float64    `json:"miss_distance"` // meters
+}
+
+// Collision is published once per pair of planes that collide in mid-air, with their positions at the impact.
+type Collision struct {
+	Time             time.Time  `json:"time"`
+	Plane            string     `json:"plane"`
+	Intruder         string     `json:"intruder"`
+	FlightID         string     `json:"flight_id"`
+	EngagementID     string     `json:"engagement_id"`
+	Position         Coordinate `json:"position"`
+	IntruderPosition Coordinate `json:"intruder_position"`
+	MissDistance     float64    `json:"miss_distance"` // meters
+}
+
+// SimulationEnded is published when every goroutine of a simulation run has stopped.
+type SimulationEnded struct {
+	Time           time.Time `json:"time"`
+	Reason         string    `json:"reason"` // "duration reached", "collision" or "emergency stop"
+	Collisions     int       `json:"collisions"`
+	PlanesInFlight int       `json:"planes_in_flight"`
+}
+
+// Kind returns KindTakeoffStarted.
+func (TakeoffStarted) Kind() EventKind { return KindTakeoffStarted }
+
+// Kind returns KindTakeoffCompleted.
+func (TakeoffCompleted) Kind() EventKind { return KindTakeoffCompleted }
+
+// Kind returns KindLandingRequested.
+func (LandingRequested) Kind() EventKind { return KindLandingRequested }
+
+// Kind returns KindLandingCompleted.
+func (LandingCompleted) Kind() EventKind { return KindLandingCompleted }
+
+// Kind returns KindRunwayBusy.
+func (RunwayBusy) Kind() EventKind { return KindRunwayBusy }
+
+// Kind returns KindPositionUpdate.
+func (PositionUpdate) Kind() EventKind { return KindPositionUpdate }
+
+// Kind returns KindTCASAdvisory.
+func (TCASAdvisory) Kind() EventKind { return KindTCASAdvisory }
+
+// Kind returns KindCollisionAverted.
+func (CollisionAverted) Kind() EventKind { return KindCollisionAverted }
+
+// Kind returns KindCollision.
+func (Collision) Kind() EventKind { return KindCollision }
+
+// Kind returns KindSimulationEnded.
+func (SimulationEnded) Kind() EventKind { return KindSimulationEnded }
+
+// When returns the time of the event.
+func (e TakeoffStarted) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e TakeoffCompleted) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e LandingRequested) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e LandingCompleted) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e RunwayBusy) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e PositionUpdate) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e TCASAdvisory) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e CollisionAverted) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e Collision) When() time.Time { return e.Time }
+
+// When returns the time of the event.
+func (e SimulationEnded) When() time.Time { return e.Time }
+
+// EventPlanes returns the serials of the planes an event is about, own plane first and then the intruder.
+func EventPlanes(event Event) []string {
+	switch e := event.(type) {
+	case TakeoffStarted:
+		return []string{e.Plane}
+	case TakeoffCompleted:
+		return []string{e.Plane}
+	case LandingRequested:
+		return []string{e.Plane}
+	case LandingCompleted:
+		return []string{e.Plane}
+	case RunwayBusy:
+		return []string{e.Plane}
+	case PositionUpdate:
+		return []string{e.Plane}
+	case TCASAdvisory:
+		return []string{e.Plane, e.Intruder}
+	case CollisionAverted:
+		return []string{e.Plane, e.Intruder}
+	case Collision:
+		return []string{e.Plane, e.Intruder}
+	default:
+		return nil
+	}
+}
+
+// EventBus delivers the events of a simulation to every subscriber.
+//
+// Handlers run on the goroutine publishing the event, in the order they subscribed, once the simulation
+// state and airports are unlocked: they hold the simulation up until they return, so they must return
+// quickly. A nil *EventBus discards every event, so runs nobody listens to need no bus.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers []eventSubscriber
+	nextID      int
+}
+
+// eventSubscriber is a handler attached to an EventBus.
+type eventSubscriber struct {
+	id      int
+	handler func(Event)
+}
+
+// NewEventBus returns an EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe attaches a handler receiving every event published from now on.
+// Calling the returned function detaches it again.
+func (bus *EventBus) Subscribe(handler func(Event)) (unsubscribe func()) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.nextID++
+	id := bus.nextID
+	bus.subscribers = append(bus.subscribers, eventSubscriber{id: id, handler: handler})
+
+	return func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		for i, subscriber := range bus.subscribers {
+			if subscriber.id == id {
+				bus.subscribers = append(bus.subscribers[:i:i], bus.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers the events, in order, to every subscriber.
+func (bus *EventBus) Publish(events ...Event) {
+	if bus == nil {
+		return
+	}
+	// handlers are called without holding the bus, so that they may subscribe or unsubscribe
+	bus.mu.Lock()
+	subscribers := bus.subscribers
+	bus.mu.Unlock()
+
+	for _, event := range events {
+		for _, subscriber := range subscribers {
+			subscriber.handler(event)
+		}
+	}
+}
+
+// MarshalEvent encodes an event as a single JSON object whose "type" member names its kind.
+func MarshalEvent(event Event) ([]byte, error) {
+	kind, err := json.Marshal(event.Kind())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	// every event is a struct with at least its time, so the fields follow the type in the same object
+	data := append([]byte(`{"type":`), kind...)
+	data = append(data, ',')
+	return append(data, fields[1:]...), nil
+}
+
+// JSONLinesWriter returns an EventBus handler writing every event to w as one JSON object per line.
+// Events published concurrently are written whole, one after the other.
+func JSONLinesWriter(w io.Writer) func(Event) {
+	var mu sync.Mutex
+	return func(event Event) {
+		data, err := MarshalEvent(event)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(data, '\n'))
+	}
+}
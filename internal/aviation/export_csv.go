@@ -0,0 +1,154 @@
+package aviation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The column schemas of the CSV export. Columns are only ever added at the end, so that
+// spreadsheets and scripts reading the files by position keep working.
+var (
+	airportsCSVHeader = []string{"serial", "x_m", "y_m", "latitude", "longitude", "runways", "runways_in_use",
+		"initial_planes", "parked_planes", "receiving_plane"}
+	planesCSVHeader = []string{"serial", "aircraft_type", "aircraft_name", "wake_category", "cruise_speed_mps", "climb_rate_mps",
+		"descent_rate_mps", "service_ceiling_m", "approach_speed_mps", "tcas_capability", "pilot_non_compliance",
+		"pilot_opposite_response", "in_flight", "airport", "flights", "engagements", "vertical_offset_m", "vertical_rate_mps"}
+	flightsCSVHeader = []string{"flight_id", "plane", "departure_airport", "arrival_airport", "departure_x_m", "departure_y_m",
+		"destination_x_m", "destination_y_m", "departure_latitude", "departure_longitude", "destination_latitude",
+		"destination_longitude", "route_distance_m", "cruising_altitude_m", "takeoff_time", "scheduled_arrival_time",
+		"actual_landing_time", "status"}
+	engagementsCSVHeader = []string{"engagement_id", "flight_id", "plane", "intruder", "advisory", "sensitivity_level",
+		"ta_time", "ra_time", "sense", "coordinated", "strengthened_time", "pilot_response", "closest_approach_time",
+		"miss_distance_m", "closed_time", "collided"}
+)
+
+// ExportCSV writes the airports, planes, flights and TCAS engagements of the simulation to airports.csv,
+// planes.csv, flights.csv and engagements.csv in dir, creating it if needed. Every file starts with a header
+// row, distances are in meters, times are RFC 3339 and empty when they never happened, and latitudes and
+// longitudes are only filled in geodetic mode.
+func (simState *SimulationState) ExportCSV(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	airports := [][]string{}
+	planes := [][]string{}
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		x, y := airport.Location.X, airport.Location.Y
+		latitude, longitude := simState.csvGeo(airport.Location)
+		airports = append(airports, []string{airport.Serial, csvFloat(x), csvFloat(y), latitude, longitude,
+			strconv.Itoa(airport.Runway.numberOfRunway), strconv.Itoa(airport.Runway.noOfRunwayinUse),
+			strconv.Itoa(airport.InitialPlaneAmount), strconv.Itoa(len(airport.Planes)), strconv.FormatBool(airport.ReceivingPlane)})
+		for _, plane := range airport.Planes {
+			planes = append(planes, planeCSVRecord(plane, airport.Serial))
+		}
+		airport.Mu.Unlock()
+	}
+	simState.Mu.Lock()
+	for _, plane := range simState.PlanesInFlight {
+		planes = append(planes, planeCSVRecord(plane, ""))
+	}
+	simState.Mu.Unlock()
+	sort.Slice(planes, func(i, j int) bool { return planes[i][0] < planes[j][0] })
+
+	flights := [][]string{}
+	engagements := [][]string{}
+	for _, plane := range simState.AllPlanes() {
+		for _, flight := range plane.FlightLog {
+			departureLatitude, departureLongitude := simState.csvGeo(flight.FlightSchedule.Depature)
+			destinationLatitude, destinationLongitude := simState.csvGeo(flight.FlightSchedule.Destination)
+			flights = append(flights, []string{flight.FlightID, plane.Serial, flight.DepatureAirPort, flight.ArrivalAirPort,
+				csvFloat(flight.FlightSchedule.Depature.X), csvFloat(flight.FlightSchedule.Depature.Y),
+				csvFloat(flight.FlightSchedule.Destination.X), csvFloat(flight.FlightSchedule.Destination.Y),
+				departureLatitude, departureLongitude, destinationLatitude, destinationLongitude,
+				csvFloat(simState.RouteDistance(flight.FlightSchedule)), csvFloat(flight.CruisingAltitude),
+				csvTime(flight.TakeoffTime), csvTime(flight.DestinationArrivalTime), csvTime(flight.ActualLandingTime), flight.FlightStatus})
+		}
+		for _, engagement := range append(append([]TCASEngagement{}, plane.TCASEngagementRecords...), plane.CurrentTCASEngagements...) {
+			engagements = append(engagements, []string{engagement.EngagementID, engagement.FlightID, engagement.PlaneSerial,
+				engagement.OtherPlaneSerial, engagement.Advisory.String(), strconv.Itoa(engagement.SensitivityLevel),
+				csvTime(engagement.TATime), csvTime(engagement.RATime), engagement.Sense.String(), strconv.FormatBool(engagement.Coordinated),
+				csvTime(engagement.StrengthenedTime), engagement.PilotResponse.String(), csvTime(engagement.TimeOfEngagement),
+				csvFloat(engagement.MissDistance), csvTime(engagement.ClosedTime), strconv.FormatBool(engagement.Collided)})
+		}
+	}
+	sort.Slice(flights, func(i, j int) bool { return flights[i][0] < flights[j][0] })
+	sort.Slice(engagements, func(i, j int) bool { return engagements[i][0] < engagements[j][0] })
+
+	files := []struct {
+		name    string
+		header  []string
+		records [][]string
+	}{
+		{"airports.csv", airportsCSVHeader, airports},
+		{"planes.csv", planesCSVHeader, planes},
+		{"flights.csv", flightsCSVHeader, flights},
+		{"engagements.csv", engagementsCSVHeader, engagements},
+	}
+	for _, file := range files {
+		if err := writeCSV(filepath.Join(dir, file.name), file.header, file.records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planeCSVRecord returns the planes.csv row of a plane, parked at the given airport or in flight when it is empty.
+func planeCSVRecord(plane Plane, airport string) []string {
+	return []string{plane.Serial, plane.AircraftType.Designator, plane.AircraftType.Name, plane.AircraftType.WakeCategory,
+		csvFloat(plane.CruiseSpeed), csvFloat(plane.AircraftType.ClimbRate), csvFloat(plane.AircraftType.DescentRate),
+		csvFloat(plane.AircraftType.ServiceCeiling), csvFloat(plane.AircraftType.ApproachSpeed), TCASCapabilityName(plane.TCASCapability),
+		csvFloat(plane.Pilot.NonComplianceProbability), csvFloat(plane.Pilot.OppositeResponseProbability),
+		strconv.FormatBool(plane.PlaneInFlight), airport, strconv.Itoa(len(plane.FlightLog)),
+		strconv.Itoa(len(plane.TCASEngagementRecords) + len(plane.CurrentTCASEngagements)),
+		csvFloat(plane.VerticalOffset), csvFloat(plane.VerticalRate)}
+}
+
+// writeCSV writes a header row and the records to a new CSV file, replacing any previous export.
+func writeCSV(path string, header []string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(header)
+	w.WriteAll(records)
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// csvGeo returns the latitude and longitude of a map coordinate in geodetic mode, or two empty fields.
+func (simState *SimulationState) csvGeo(c Coordinate) (string, string) {
+	if simState.Frame == nil {
+		return "", ""
+	}
+	position := simState.Frame.FromMap(c)
+	return strconv.FormatFloat(position.Latitude, 'f', 6, 64), strconv.FormatFloat(position.Longitude, 'f', 6, 64)
+}
+
+// csvFloat formats a number in meters or SI units for the CSV export.
+func csvFloat(value float64) string {
+	formatted := strconv.FormatFloat(value, 'f', 3, 64)
+	if formatted == "-0.000" {
+		return "0.000"
+	}
+	return formatted
+}
+
+// csvTime formats a time for the CSV export, leaving times that never happened empty.
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,274 @@
+package aviation
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outcomes of a TCAS engagement, as shown on the exported maps.
+const (
+	OutcomeCollision = "collision" // the planes collided
+	OutcomeResolved  = "resolved"  // a Resolution Advisory kept the planes apart
+	OutcomeTraffic   = "traffic"   // the encounter never went beyond a Traffic Advisory
+	OutcomeOpen      = "open"      // the encounter was still going on
+)
+
+// outcomeColors holds the color of each engagement outcome on the exported maps, as RGB hex.
+var outcomeColors = map[string]string{
+	OutcomeCollision: "#d7191c",
+	OutcomeResolved:  "#fdae61",
+	OutcomeTraffic:   "#ffff33",
+	OutcomeOpen:      "#999999",
+}
+
+// EngagementOutcome classifies how a TCAS engagement ended.
+func EngagementOutcome(engagement TCASEngagement) string {
+	switch {
+	case engagement.Collided:
+		return OutcomeCollision
+	case engagement.ClosedTime.IsZero():
+		return OutcomeOpen
+	case engagement.Advisory == AdvisoryRA:
+		return OutcomeResolved
+	default:
+		return OutcomeTraffic
+	}
+}
+
+// mapFeatures gathers what the exported maps show, with every position converted to latitude and longitude.
+type mapFeatures struct {
+	airports    []mapAirport
+	flights     []mapFlight
+	engagements []mapEngagement
+}
+
+// mapAirport is an airport on an exported map.
+type mapAirport struct {
+	serial   string
+	location GeoCoordinate
+	runways  int
+}
+
+// mapFlight is the track of a flight on an exported map.
+type mapFlight struct {
+	flight Flight
+	plane  string
+	track  []GeoCoordinate
+	times  []time.Time
+}
+
+// mapEngagement is a TCAS engagement on an exported map, placed where own plane was at the closest approach.
+type mapEngagement struct {
+	engagement TCASEngagement
+	location   GeoCoordinate
+	outcome    string
+}
+
+// mapFrame returns the frame placing the map on the Earth: the geodetic frame of the simulation,
+// or for a flat map a frame at latitude 0, longitude 0 so that GIS tools can still show it to scale.
+func (simState *SimulationState) mapFrame() *ENUFrame {
+	if simState.Frame != nil {
+		return simState.Frame
+	}
+	return &ENUFrame{}
+}
+
+// collectMapFeatures returns the airports, the recorded tracks of every flight flown and the TCAS engagements.
+func (simState *SimulationState) collectMapFeatures() mapFeatures {
+	frame := simState.mapFrame()
+	features := mapFeatures{}
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		features.airports = append(features.airports, mapAirport{
+			serial:   airport.Serial,
+			location: frame.FromMap(airport.Location),
+			runways:  airport.Runway.numberOfRunway,
+		})
+		airport.Mu.Unlock()
+	}
+
+	flights := map[string]Flight{}
+	for _, plane := range simState.AllPlanes() {
+		for _, flight := range plane.FlightLog {
+			flights[flight.FlightID] = flight
+			if len(flight.Track) == 0 {
+				continue
+			}
+			mapped := mapFlight{flight: flight, plane: plane.Serial}
+			for _, waypoint := range flight.Track {
+				mapped.track = append(mapped.track, frame.FromMap(waypoint.Position))
+				mapped.times = append(mapped.times, waypoint.Time)
+			}
+			features.flights = append(features.flights, mapped)
+		}
+		for _, engagement := range append(append([]TCASEngagement{}, plane.TCASEngagementRecords...), plane.CurrentTCASEngagements...) {
+			features.engagements = append(features.engagements, mapEngagement{engagement: engagement, outcome: EngagementOutcome(engagement)})
+		}
+	}
+	for i := range features.engagements {
+		engagement := features.engagements[i].engagement
+		features.engagements[i].location = frame.FromMap(flights[engagement.FlightID].TrackPositionAt(engagement.TimeOfEngagement))
+	}
+	sort.Slice(features.flights, func(i, j int) bool { return features.flights[i].flight.FlightID < features.flights[j].flight.FlightID })
+	sort.Slice(features.engagements, func(i, j int) bool {
+		return features.engagements[i].engagement.EngagementID < features.engagements[j].engagement.EngagementID
+	})
+	return features
+}
+
+// ExportKML writes the airports as points, every flight as a time-stamped gx:Track that GIS tools can animate,
+// and the TCAS engagements as points colored by outcome, in KML.
+func (simState *SimulationState) ExportKML(w io.Writer) error {
+	features := simState.collectMapFeatures()
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(out, `<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">`)
+	fmt.Fprintln(out, `<Document>`)
+	fmt.Fprintf(out, "<name>TCAS simulation (seed %d)</name>\n", simState.Seed)
+	for _, outcome := range []string{OutcomeCollision, OutcomeResolved, OutcomeTraffic, OutcomeOpen} {
+		fmt.Fprintf(out, "<Style id=\"%s\"><IconStyle><color>%s</color></IconStyle></Style>\n", outcome, kmlColor(outcomeColors[outcome]))
+	}
+
+	fmt.Fprintln(out, `<Folder><name>Airports</name>`)
+	for _, airport := range features.airports {
+		fmt.Fprintf(out, "<Placemark><name>%s</name><description>%d runway(s)</description><Point><coordinates>%s</coordinates></Point></Placemark>\n",
+			xmlEscape(airport.serial), airport.runways, kmlCoordinate(airport.location, ","))
+	}
+	fmt.Fprintln(out, `</Folder>`)
+
+	fmt.Fprintln(out, `<Folder><name>Flights</name>`)
+	for _, flight := range features.flights {
+		fmt.Fprintf(out, "<Placemark><name>%s</name><description>Plane %s from %s to %s</description>\n",
+			xmlEscape(flight.flight.FlightID), xmlEscape(flight.plane), xmlEscape(flight.flight.DepatureAirPort), xmlEscape(flight.flight.ArrivalAirPort))
+		fmt.Fprintln(out, `<gx:Track><altitudeMode>absolute</altitudeMode>`)
+		for _, t := range flight.times {
+			fmt.Fprintf(out, "<when>%s</when>\n", t.UTC().Format(time.RFC3339Nano))
+		}
+		for _, position := range flight.track {
+			fmt.Fprintf(out, "<gx:coord>%s</gx:coord>\n", kmlCoordinate(position, " "))
+		}
+		fmt.Fprintln(out, `</gx:Track></Placemark>`)
+	}
+	fmt.Fprintln(out, `</Folder>`)
+
+	fmt.Fprintln(out, `<Folder><name>TCAS engagements</name>`)
+	for _, mapped := range features.engagements {
+		engagement := mapped.engagement
+		fmt.Fprintf(out, "<Placemark><name>%s</name><description>Plane %s against Plane %s: %s, %s, miss distance %.0f m</description>",
+			xmlEscape(engagement.EngagementID), xmlEscape(engagement.PlaneSerial), xmlEscape(engagement.OtherPlaneSerial),
+			engagement.Advisory, mapped.outcome, engagement.MissDistance)
+		fmt.Fprintf(out, "<TimeStamp><when>%s</when></TimeStamp><styleUrl>#%s</styleUrl>",
+			engagement.TimeOfEngagement.UTC().Format(time.RFC3339Nano), mapped.outcome)
+		fmt.Fprintf(out, "<Point><altitudeMode>absolute</altitudeMode><coordinates>%s</coordinates></Point></Placemark>\n",
+			kmlCoordinate(mapped.location, ","))
+	}
+	fmt.Fprintln(out, `</Folder>`)
+
+	fmt.Fprintln(out, `</Document>`)
+	fmt.Fprintln(out, `</kml>`)
+	return out.Flush()
+}
+
+// ExportGeoJSON writes the airports and TCAS engagements as points and every flight as a 3D line string,
+// in a GeoJSON feature collection. A flight's "coordTimes" property holds the time of each of its points,
+// and engagements carry a "marker-color" by outcome.
+func (simState *SimulationState) ExportGeoJSON(w io.Writer) error {
+	type feature struct {
+		Type       string         `json:"type"`
+		Geometry   map[string]any `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	}
+	features := simState.collectMapFeatures()
+	collection := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: []feature{}}
+
+	for _, airport := range features.airports {
+		collection.Features = append(collection.Features, feature{
+			Type:       "Feature",
+			Geometry:   map[string]any{"type": "Point", "coordinates": geoJSONPosition(airport.location)},
+			Properties: map[string]any{"kind": "airport", "serial": airport.serial, "runways": airport.runways},
+		})
+	}
+	for _, flight := range features.flights {
+		coordinates := [][]float64{}
+		times := []string{}
+		for i, position := range flight.track {
+			coordinates = append(coordinates, geoJSONPosition(position))
+			times = append(times, flight.times[i].UTC().Format(time.RFC3339Nano))
+		}
+		// a line string needs two positions, a flight stopped right after takeoff is a single point
+		geometryType := "LineString"
+		var geometry any = coordinates
+		if len(coordinates) == 1 {
+			geometryType, geometry = "Point", coordinates[0]
+		}
+		collection.Features = append(collection.Features, feature{
+			Type:     "Feature",
+			Geometry: map[string]any{"type": geometryType, "coordinates": geometry},
+			Properties: map[string]any{
+				"kind":              "flight",
+				"flight_id":         flight.flight.FlightID,
+				"plane":             flight.plane,
+				"departure_airport": flight.flight.DepatureAirPort,
+				"arrival_airport":   flight.flight.ArrivalAirPort,
+				"status":            flight.flight.FlightStatus,
+				"coordTimes":        times,
+			},
+		})
+	}
+	for _, mapped := range features.engagements {
+		engagement := mapped.engagement
+		collection.Features = append(collection.Features, feature{
+			Type:     "Feature",
+			Geometry: map[string]any{"type": "Point", "coordinates": geoJSONPosition(mapped.location)},
+			Properties: map[string]any{
+				"kind":          "engagement",
+				"engagement_id": engagement.EngagementID,
+				"flight_id":     engagement.FlightID,
+				"plane":         engagement.PlaneSerial,
+				"intruder":      engagement.OtherPlaneSerial,
+				"advisory":      engagement.Advisory.String(),
+				"outcome":       mapped.outcome,
+				"miss_distance": engagement.MissDistance,
+				"time":          engagement.TimeOfEngagement.UTC().Format(time.RFC3339Nano),
+				"marker-color":  outcomeColors[mapped.outcome],
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(collection)
+}
+
+// kmlCoordinate formats a position as KML longitude, latitude and altitude, joined by sep.
+func kmlCoordinate(position GeoCoordinate, sep string) string {
+	return fmt.Sprintf("%.6f%s%.6f%s%.1f", position.Longitude, sep, position.Latitude, sep, position.Altitude)
+}
+
+// kmlColor converts an RGB hex color to KML's opaque aabbggrr notation.
+func kmlColor(rgb string) string {
+	rgb = strings.TrimPrefix(rgb, "#")
+	return "ff" + rgb[4:6] + rgb[2:4] + rgb[0:2]
+}
+
+// geoJSONPosition returns a GeoJSON position: longitude, latitude and altitude in meters.
+func geoJSONPosition(position GeoCoordinate) []float64 {
+	return []float64{position.Longitude, position.Latitude, position.Altitude}
+}
+
+// xmlEscape escapes text for use in an XML document.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -11,7 +11,9 @@ type Flight struct {
 	FlightSchedule         FlightPath
 	TakeoffTime            time.Time
 	DestinationArrivalTime time.Time
-	CruisingAltitude       float64 // Meters
+	CruisingAltitude       float64      // Meters
+	Trajectory             []Waypoint   // 4D trajectory: departure, top of climb, top of descent and arrival, and great-circle points in geodetic mode
+	Track                  []TrackPoint // track actually flown, sampled from takeoff to landing
 	DepatureAirPort        string
 	ArrivalAirPort         string
 	FlightStatus           string
@@ -24,6 +26,31 @@ type FlightPath struct {
 	Destination Coordinate
 }
 
+// Waypoint is a point of a 4D trajectory: where the plane is at a given time.
+type Waypoint struct {
+	Position Coordinate
+	Time     time.Time
+}
+
+// TrackPoint is a sample of the track a plane actually flew, maneuvers included.
+type TrackPoint struct {
+	Time     time.Time
+	Position Coordinate   // Z is the altitude
+	Velocity Coordinate   // meters per second
+	Advisory AdvisoryType // highest advisory active on board, AdvisoryNone when clear of conflict
+	Sense    RASense      // sense of the active Resolution Advisory, SenseNone without one
+}
+
+// TrackInterval is how often a plane in flight records its track when the run does not configure it.
+// A plane also records its track whenever its active advisory changes.
+const TrackInterval = 10 * time.Second
+
+// Vertical performance of a plane of unknown type, in meters per second.
+const (
+	ClimbRate   = 15.0 // about 3000 ft/min
+	DescentRate = 12.0 // about 2400 ft/min
+)
+
 // GetFlightProgress calculates Progress made by plane in transit
 func (f Flight) GetFlightProgress(simTime time.Time) string {
 
@@ -46,3 +73,127 @@ func (f Flight) GetFlightProgress(simTime time.Time) string {
 		return "0% (Plane about to take off or still taking off)"
 	}
 }
+
+// planTrajectory builds the 4D trajectory of a flight along its path between takeoff and arrival.
+// The plane flies at constant ground speed, climbs at climbRate to the cruising altitude, cruises
+// and descends at descentRate to arrive at the destination. A flight too short to reach its cruising
+// altitude tops out where its climb meets its descent, so top of climb and top of descent coincide.
+// Without known vertical rates the plane climbs at ClimbRate and descends at DescentRate.
+func planTrajectory(path FlightPath, takeoff, arrival time.Time, cruisingAltitude, climbRate, descentRate float64) []Waypoint {
+	duration := arrival.Sub(takeoff).Seconds()
+	peak := cruisingAltitude
+	if climbRate <= 0 || descentRate <= 0 {
+		climbRate, descentRate = ClimbRate, DescentRate
+	}
+	if duration <= 0 {
+		return []Waypoint{{Position: path.Depature, Time: takeoff}, {Position: path.Destination, Time: arrival}}
+	}
+	if maxPeak := duration / (1/climbRate + 1/descentRate); peak > maxPeak {
+		peak = maxPeak
+	}
+	climbSeconds := peak / climbRate
+	descentSeconds := peak / descentRate
+
+	along := func(seconds float64) Coordinate {
+		position := path.Depature.add(path.Destination.subtract(path.Depature).mulScalar(seconds / duration))
+		position.Z = peak
+		return position
+	}
+	return []Waypoint{
+		{Position: path.Depature, Time: takeoff},
+		{Position: along(climbSeconds), Time: takeoff.Add(time.Duration(climbSeconds * float64(time.Second)))},
+		{Position: along(duration - descentSeconds), Time: arrival.Add(-time.Duration(descentSeconds * float64(time.Second)))},
+		{Position: path.Destination, Time: arrival},
+	}
+}
+
+// trajectory returns the flight's 4D trajectory, planning it from the schedule with the
+// performance of a plane of unknown type if it was not stored.
+func (f Flight) trajectory() []Waypoint {
+	if len(f.Trajectory) > 0 {
+		return f.Trajectory
+	}
+	return planTrajectory(f.FlightSchedule, f.TakeoffTime, f.DestinationArrivalTime, f.CruisingAltitude, ClimbRate, DescentRate)
+}
+
+// TopOfClimb returns where and when the flight levels off at its peak altitude.
+func (f Flight) TopOfClimb() Waypoint {
+	trajectory := f.trajectory()
+	top := trajectory[0]
+	for _, waypoint := range trajectory {
+		if waypoint.Position.Z > top.Position.Z {
+			top = waypoint
+		}
+	}
+	return top
+}
+
+// TopOfDescent returns where and when the flight starts its descent to the destination.
+func (f Flight) TopOfDescent() Waypoint {
+	trajectory := f.trajectory()
+	top := trajectory[0]
+	for _, waypoint := range trajectory {
+		if waypoint.Position.Z >= top.Position.Z {
+			top = waypoint
+		}
+	}
+	return top
+}
+
+// segmentAt returns the trajectory segment flown at time t, clamped to the first and last segments.
+func segmentAt(trajectory []Waypoint, t time.Time) (from, to Waypoint) {
+	for i := 1; i < len(trajectory)-1; i++ {
+		if t.Before(trajectory[i].Time) {
+			return trajectory[i-1], trajectory[i]
+		}
+	}
+	return trajectory[len(trajectory)-2], trajectory[len(trajectory)-1]
+}
+
+// PositionAt returns the planned 3D position of the plane at time t along its trajectory,
+// through climb, cruise and descent. Times outside the flight are clamped to its ends.
+func (f Flight) PositionAt(t time.Time) Coordinate {
+	return interpolate(f.trajectory(), t)
+}
+
+// TrackPositionAt returns where the plane actually was at time t, interpolated along its recorded track,
+// which includes the maneuvers flown to resolve RAs. Flights without a recorded track use their plan.
+func (f Flight) TrackPositionAt(t time.Time) Coordinate {
+	switch len(f.Track) {
+	case 0:
+		return f.PositionAt(t)
+	case 1:
+		return f.Track[0].Position
+	}
+	waypoints := make([]Waypoint, len(f.Track))
+	for i, point := range f.Track {
+		waypoints[i] = Waypoint{Position: point.Position, Time: point.Time}
+	}
+	return interpolate(waypoints, t)
+}
+
+// interpolate returns the position at time t along a sequence of at least two waypoints,
+// clamped to its first and last waypoints.
+func interpolate(waypoints []Waypoint, t time.Time) Coordinate {
+	from, to := segmentAt(waypoints, t)
+	segmentDuration := to.Time.Sub(from.Time)
+	fraction := 1.0
+	if segmentDuration > 0 {
+		fraction = clamp(float64(t.Sub(from.Time))/float64(segmentDuration), 0, 1)
+	}
+	return from.Position.add(to.Position.subtract(from.Position).mulScalar(fraction))
+}
+
+// VelocityAt returns the planned velocity of the plane at time t, in meters per second.
+// The plane is at rest before takeoff and after arrival.
+func (f Flight) VelocityAt(t time.Time) Coordinate {
+	if t.Before(f.TakeoffTime) || !t.Before(f.DestinationArrivalTime) {
+		return Coordinate{}
+	}
+	from, to := segmentAt(f.trajectory(), t)
+	seconds := to.Time.Sub(from.Time).Seconds()
+	if seconds <= 0 {
+		return Coordinate{}
+	}
+	return to.Position.subtract(from.Position).mulScalar(1 / seconds)
+}
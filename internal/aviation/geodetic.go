@@ -0,0 +1,270 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Most functions here implement the geodetic mode: WGS-84 latitude/longitude/altitude positions,
+// great-circle routes and the local frames used to bring them back to the flat CPA math.
+
+// GeoCoordinate is a WGS-84 geodetic position: latitude and longitude in degrees, altitude in meters.
+type GeoCoordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude,omitempty"`
+}
+
+// String prints the position the way it is read on a chart, with the altitude in feet.
+func (g GeoCoordinate) String() string {
+	return fmt.Sprintf("(%.5f°, %.5f°, %.0f ft)", g.Latitude, g.Longitude, MetersToFeet(g.Altitude))
+}
+
+// ParseGeoCoordinate parses a "latitude,longitude" pair in degrees, such as "51.47,-0.4543".
+func ParseGeoCoordinate(s string) (GeoCoordinate, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return GeoCoordinate{}, fmt.Errorf("invalid position %q: use latitude,longitude in degrees", s)
+	}
+	latitude, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	longitude, errLon := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLon != nil || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return GeoCoordinate{}, fmt.Errorf("invalid position %q: use latitude,longitude in degrees", s)
+	}
+	return GeoCoordinate{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// WGS-84 ellipsoid, and the mean Earth radius used for great-circle routes.
+const (
+	wgs84SemiMajorAxis  = 6378137.0
+	wgs84Flattening     = 1 / 298.257223563
+	wgs84EccentricitySq = wgs84Flattening * (2 - wgs84Flattening)
+	meanEarthRadius     = 6371008.8
+)
+
+// greatCircleStep is the longest straight piece a great-circle route is flown in on the map.
+const greatCircleStep = 10000.0 // meters
+
+// MetersToNauticalMiles converts a distance in meters to nautical miles.
+func MetersToNauticalMiles(meters float64) float64 {
+	return meters / metersPerNauticalMile
+}
+
+// MetersToFeet converts a distance or altitude in meters to feet.
+func MetersToFeet(meters float64) float64 {
+	return meters / metersPerFoot
+}
+
+// FeetToMeters converts a distance or altitude in feet to meters.
+func FeetToMeters(feet float64) float64 {
+	return feet * metersPerFoot
+}
+
+// radians converts degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// degrees converts radians to degrees.
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// toECEF returns the Earth-centered, Earth-fixed cartesian position of a geodetic position, in meters.
+func (g GeoCoordinate) toECEF() Coordinate {
+	latitude, longitude := radians(g.Latitude), radians(g.Longitude)
+	primeVerticalRadius := wgs84SemiMajorAxis / math.Sqrt(1-wgs84EccentricitySq*math.Pow(math.Sin(latitude), 2))
+	return Coordinate{
+		X: (primeVerticalRadius + g.Altitude) * math.Cos(latitude) * math.Cos(longitude),
+		Y: (primeVerticalRadius + g.Altitude) * math.Cos(latitude) * math.Sin(longitude),
+		Z: (primeVerticalRadius*(1-wgs84EccentricitySq) + g.Altitude) * math.Sin(latitude),
+	}
+}
+
+// fromECEF returns the geodetic position of an Earth-centered, Earth-fixed position,
+// iterating on the latitude until it settles well below a millimeter.
+func fromECEF(c Coordinate) GeoCoordinate {
+	longitude := math.Atan2(c.Y, c.X)
+	p := math.Hypot(c.X, c.Y)
+	latitude := math.Atan2(c.Z, p*(1-wgs84EccentricitySq))
+	var altitude float64
+	for range 10 {
+		primeVerticalRadius := wgs84SemiMajorAxis / math.Sqrt(1-wgs84EccentricitySq*math.Pow(math.Sin(latitude), 2))
+		altitude = p/math.Cos(latitude) - primeVerticalRadius
+		latitude = math.Atan2(c.Z, p*(1-wgs84EccentricitySq*primeVerticalRadius/(primeVerticalRadius+altitude)))
+	}
+	return GeoCoordinate{Latitude: degrees(latitude), Longitude: degrees(longitude), Altitude: altitude}
+}
+
+// ENUFrame is a local East-North-Up cartesian frame, in meters, tangent to the ellipsoid at its origin.
+type ENUFrame struct {
+	Origin GeoCoordinate
+}
+
+// ToENU returns the position of a geodetic position in the frame: X east, Y north and Z up from the origin.
+func (frame ENUFrame) ToENU(g GeoCoordinate) Coordinate {
+	d := g.toECEF().subtract(frame.Origin.toECEF())
+	latitude, longitude := radians(frame.Origin.Latitude), radians(frame.Origin.Longitude)
+	sinLat, cosLat := math.Sin(latitude), math.Cos(latitude)
+	sinLon, cosLon := math.Sin(longitude), math.Cos(longitude)
+	return Coordinate{
+		X: -sinLon*d.X + cosLon*d.Y,
+		Y: -sinLat*cosLon*d.X - sinLat*sinLon*d.Y + cosLat*d.Z,
+		Z: cosLat*cosLon*d.X + cosLat*sinLon*d.Y + sinLat*d.Z,
+	}
+}
+
+// FromENU returns the geodetic position of a position in the frame.
+func (frame ENUFrame) FromENU(c Coordinate) GeoCoordinate {
+	latitude, longitude := radians(frame.Origin.Latitude), radians(frame.Origin.Longitude)
+	sinLat, cosLat := math.Sin(latitude), math.Cos(latitude)
+	sinLon, cosLon := math.Sin(longitude), math.Cos(longitude)
+	d := Coordinate{
+		X: -sinLon*c.X - sinLat*cosLon*c.Y + cosLat*cosLon*c.Z,
+		Y: cosLon*c.X - sinLat*sinLon*c.Y + cosLat*sinLon*c.Z,
+		Z: cosLat*c.Y + sinLat*c.Z,
+	}
+	return fromECEF(frame.Origin.toECEF().add(d))
+}
+
+// ToMap returns the simulation map coordinate of a geodetic position.
+//
+// The simulation flies planes on a flat map where X and Y are the east and north position of the
+// ground point below the plane in the frame, and Z is its altitude. Unlike true ENU coordinates the
+// altitude does not drop with the curvature of the Earth away from the origin, so the altitude logic
+// of TCAS holds anywhere on a regional map.
+func (frame ENUFrame) ToMap(g GeoCoordinate) Coordinate {
+	ground := frame.ToENU(GeoCoordinate{Latitude: g.Latitude, Longitude: g.Longitude})
+	return Coordinate{X: ground.X, Y: ground.Y, Z: g.Altitude}
+}
+
+// FromMap returns the geodetic position of a simulation map coordinate, the inverse of ToMap.
+func (frame ENUFrame) FromMap(c Coordinate) GeoCoordinate {
+	// Walk down the frame's vertical to the ellipsoid below the map point.
+	var g GeoCoordinate
+	up := 0.0
+	for range 5 {
+		g = frame.FromENU(Coordinate{X: c.X, Y: c.Y, Z: up})
+		up = frame.ToENU(GeoCoordinate{Latitude: g.Latitude, Longitude: g.Longitude}).Z
+	}
+	return GeoCoordinate{Latitude: g.Latitude, Longitude: g.Longitude, Altitude: c.Z}
+}
+
+// GreatCircleDistance returns the length of the great-circle route between the ground points
+// of two positions, in meters.
+func GreatCircleDistance(from, to GeoCoordinate) float64 {
+	return meanEarthRadius * centralAngle(from, to)
+}
+
+// centralAngle returns the angle between two positions seen from the center of the Earth, in radians.
+func centralAngle(from, to GeoCoordinate) float64 {
+	lat1, lat2 := radians(from.Latitude), radians(to.Latitude)
+	dLat, dLon := lat2-lat1, radians(to.Longitude-from.Longitude)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * math.Asin(math.Sqrt(clamp(h, 0, 1)))
+}
+
+// GreatCircleIntermediate returns the position a fraction of the way along the great-circle route
+// between two positions, with the altitude interpolated linearly.
+func GreatCircleIntermediate(from, to GeoCoordinate, fraction float64) GeoCoordinate {
+	altitude := from.Altitude + (to.Altitude-from.Altitude)*fraction
+	angle := centralAngle(from, to)
+	if angle < 1e-12 {
+		return GeoCoordinate{Latitude: from.Latitude, Longitude: from.Longitude, Altitude: altitude}
+	}
+	a := math.Sin((1-fraction)*angle) / math.Sin(angle)
+	b := math.Sin(fraction*angle) / math.Sin(angle)
+	lat1, lon1 := radians(from.Latitude), radians(from.Longitude)
+	lat2, lon2 := radians(to.Latitude), radians(to.Longitude)
+	x := a*math.Cos(lat1)*math.Cos(lon1) + b*math.Cos(lat2)*math.Cos(lon2)
+	y := a*math.Cos(lat1)*math.Sin(lon1) + b*math.Cos(lat2)*math.Sin(lon2)
+	z := a*math.Sin(lat1) + b*math.Sin(lat2)
+	return GeoCoordinate{
+		Latitude:  degrees(math.Atan2(z, math.Hypot(x, y))),
+		Longitude: degrees(math.Atan2(y, x)),
+		Altitude:  altitude,
+	}
+}
+
+// RouteDistance returns the length of a flight path: the great-circle distance between its airports
+// in geodetic mode, the straight-line distance on the flat map otherwise.
+func (simState *SimulationState) RouteDistance(path FlightPath) float64 {
+	if simState.Frame == nil {
+		return Distance(path.Depature, path.Destination)
+	}
+	return GreatCircleDistance(simState.Frame.FromMap(path.Depature), simState.Frame.FromMap(path.Destination))
+}
+
+// DescribeLocation prints a map coordinate, as latitude, longitude and altitude in geodetic mode.
+func (simState *SimulationState) DescribeLocation(c Coordinate) string {
+	if simState.Frame == nil {
+		return c.String()
+	}
+	return simState.Frame.FromMap(c).String()
+}
+
+// DescribeAltitude prints an altitude in meters, or in feet in geodetic mode.
+func (simState *SimulationState) DescribeAltitude(meters float64) string {
+	if simState.Frame == nil {
+		return fmt.Sprintf("%.2f meters", meters)
+	}
+	return fmt.Sprintf("%.0f ft", MetersToFeet(meters))
+}
+
+// DescribeDistance prints a distance in meters, or in nautical miles in geodetic mode.
+func (simState *SimulationState) DescribeDistance(meters float64) string {
+	if simState.Frame == nil {
+		return fmt.Sprintf("%.2f meters", meters)
+	}
+	return fmt.Sprintf("%.2f nm", MetersToNauticalMiles(meters))
+}
+
+// followGreatCircle bends a trajectory planned as a straight line on the map onto the great-circle
+// route between its ends. The plane keeps a constant ground speed along the route and the altitude
+// profile of the trajectory; the route is cut into straight pieces of at most greatCircleStep.
+func (frame ENUFrame) followGreatCircle(trajectory []Waypoint) []Waypoint {
+	first, last := trajectory[0], trajectory[len(trajectory)-1]
+	duration := last.Time.Sub(first.Time)
+	from, to := frame.FromMap(first.Position), frame.FromMap(last.Position)
+	distance := GreatCircleDistance(from, to)
+	if duration <= 0 || distance == 0 {
+		return trajectory
+	}
+
+	onRoute := func(t time.Time, altitude float64) Waypoint {
+		position := frame.ToMap(GreatCircleIntermediate(from, to, float64(t.Sub(first.Time))/float64(duration)))
+		position.Z = altitude
+		return Waypoint{Position: position, Time: t}
+	}
+	route := []Waypoint{onRoute(first.Time, first.Position.Z)}
+	for i := 1; i < len(trajectory); i++ {
+		start, end := trajectory[i-1], trajectory[i]
+		pieces := max(1, int(math.Ceil(distance*float64(end.Time.Sub(start.Time))/float64(duration)/greatCircleStep)))
+		for piece := 1; piece <= pieces; piece++ {
+			fraction := float64(piece) / float64(pieces)
+			t := start.Time.Add(time.Duration(fraction * float64(end.Time.Sub(start.Time))))
+			route = append(route, onRoute(t, start.Position.Z+(end.Position.Z-start.Position.Z)*fraction))
+		}
+	}
+	return route
+}
+
+// localGeometry re-expresses the positions and velocities of own plane and of an intruder, given on the map,
+// on a map tangent to the Earth below own plane. The simulation map is only true at its origin: far from it
+// horizontal distances drift, so TCAS evaluates every encounter where it happens. Altitudes are kept as they
+// are, TCAS compares the altitudes the planes report rather than their height in the local frame.
+func (frame ENUFrame) localGeometry(ownPosition, ownVelocity, intruderPosition, intruderVelocity Coordinate) (Coordinate, Coordinate, Coordinate, Coordinate) {
+	below := frame.FromMap(ownPosition)
+	local := ENUFrame{Origin: GeoCoordinate{Latitude: below.Latitude, Longitude: below.Longitude}}
+	remap := func(position, velocity Coordinate) (Coordinate, Coordinate) {
+		localPosition := local.ToMap(frame.FromMap(position))
+		// the velocity is the local displacement over one second of flight
+		return localPosition, local.ToMap(frame.FromMap(position.add(velocity))).subtract(localPosition)
+	}
+	ownPosition, ownVelocity = remap(ownPosition, ownVelocity)
+	intruderPosition, intruderVelocity = remap(intruderPosition, intruderVelocity)
+	return ownPosition, ownVelocity, intruderPosition, intruderVelocity
+}
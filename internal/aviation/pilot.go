@@ -0,0 +1,125 @@
+package aviation
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+)
+
+// PilotModel describes how the crew of a plane responds to Resolution Advisories.
+type PilotModel struct {
+	InitialResponseDelay        time.Duration // delay between an initial RA and the start of the maneuver
+	StrengtheningResponseDelay  time.Duration // delay between a strengthening RA and the increased maneuver
+	InitialAcceleration         float64       // vertical acceleration flown for an initial RA, meters per second squared
+	StrengtheningAcceleration   float64       // vertical acceleration flown for a strengthening RA, meters per second squared
+	TargetVerticalRate          float64       // vertical rate commanded by an initial RA, meters per second
+	StrengthenedVerticalRate    float64       // vertical rate commanded by a strengthening RA, meters per second
+	NonComplianceProbability    float64       // probability that the crew ignores an RA
+	OppositeResponseProbability float64       // probability that the crew flies the opposite of the commanded sense
+}
+
+// gravity is the standard acceleration of gravity in meters per second squared.
+const gravity = 9.80665
+
+// Response of the standard pilot to an initial RA, in the units crews fly it in.
+const (
+	StandardResponseDelay   = 5 * time.Second
+	StandardAccelerationG   = 0.25
+	StandardVerticalRateFpm = 1500.0
+)
+
+// DefaultPilotModel is the standard pilot TCAS II assumes: the crew starts an initial RA maneuver
+// after 5 s at 0.25 g towards 1500 ft/min, and a strengthening one after 2.5 s at 0.35 g towards 2500 ft/min.
+var DefaultPilotModel = PilotModel{
+	InitialResponseDelay:       StandardResponseDelay,
+	StrengtheningResponseDelay: 2500 * time.Millisecond,
+	InitialAcceleration:        StandardAccelerationG * gravity,
+	StrengtheningAcceleration:  0.35 * gravity,
+	TargetVerticalRate:         StandardVerticalRateFpm * metersPerFoot / 60,
+	StrengthenedVerticalRate:   2500 * metersPerFoot / 60,
+}
+
+// NewPilotModel returns the standard pilot model with the given compliance probabilities.
+func NewPilotModel(nonCompliance, oppositeResponse float64) PilotModel {
+	pilot := DefaultPilotModel
+	pilot.NonComplianceProbability = nonCompliance
+	pilot.OppositeResponseProbability = oppositeResponse
+	return pilot
+}
+
+// WithResponse returns the pilot model starting initial RA maneuvers after delay, at accelerationG (in g)
+// towards verticalRateFpm (in ft/min). Zero values keep the model's own. The strengthening response is
+// scaled alike, so that it keeps its proportions to the initial one.
+func (pilot PilotModel) WithResponse(delay time.Duration, accelerationG, verticalRateFpm float64) PilotModel {
+	delayFactor, accelerationFactor, rateFactor := 1.0, 1.0, 1.0
+	if delay > 0 {
+		delayFactor = float64(delay) / float64(pilot.InitialResponseDelay)
+	}
+	if accelerationG > 0 {
+		accelerationFactor = accelerationG * gravity / pilot.InitialAcceleration
+	}
+	if verticalRateFpm > 0 {
+		rateFactor = verticalRateFpm * metersPerFoot / 60 / pilot.TargetVerticalRate
+	}
+	pilot = pilot.scale(delayFactor, accelerationFactor, rateFactor)
+	// the initial response is the one asked for, without the rounding of its scaling
+	if delay > 0 {
+		pilot.InitialResponseDelay = delay
+	}
+	return pilot
+}
+
+// vary returns a crew drawn around the pilot model: its response delay, acceleration and commanded vertical rates
+// are each scaled by a factor drawn uniformly between 1-variation and 1+variation.
+func (pilot PilotModel) vary(variation float64, r *rand.Rand) PilotModel {
+	factor := func() float64 { return 1 + variation*(2*r.Float64()-1) }
+	return pilot.scale(factor(), factor(), factor())
+}
+
+// scale returns the pilot model with its response delays, accelerations and commanded vertical rates
+// multiplied by the given factors, for initial and strengthening RAs alike.
+func (pilot PilotModel) scale(delay, acceleration, verticalRate float64) PilotModel {
+	pilot.InitialResponseDelay = time.Duration(float64(pilot.InitialResponseDelay) * delay)
+	pilot.StrengtheningResponseDelay = time.Duration(float64(pilot.StrengtheningResponseDelay) * delay)
+	pilot.InitialAcceleration *= acceleration
+	pilot.StrengtheningAcceleration *= acceleration
+	pilot.TargetVerticalRate *= verticalRate
+	pilot.StrengthenedVerticalRate *= verticalRate
+	return pilot
+}
+
+// String describes how the crew flies initial RAs, such as "response delay 5s at 0.25 g to 1500 ft/min".
+func (pilot PilotModel) String() string {
+	return fmt.Sprintf("response delay %v at %.2f g to %.0f ft/min", pilot.InitialResponseDelay.Round(time.Millisecond),
+		pilot.InitialAcceleration/gravity, pilot.TargetVerticalRate/metersPerFoot*60)
+}
+
+// configuredPilotModel returns the crew of the configuration: the standard pilot with its compliance
+// probabilities and initial RA response, zero values keeping the standard ones.
+func configuredPilotModel(conf *config.Config) PilotModel {
+	return NewPilotModel(conf.PilotNonCompliance, conf.PilotOppositeResponse).
+		WithResponse(conf.PilotResponseDelay, conf.PilotAcceleration, conf.PilotVerticalRate)
+}
+
+// respond draws how the crew reacts to an RA commanding the given sense: it flies the sense,
+// flies the opposite one, or ignores the advisory and returns SenseNone.
+func (pilot PilotModel) respond(sense RASense, r *rand.Rand) RASense {
+	draw := r.Float64()
+	switch {
+	case draw < pilot.NonComplianceProbability:
+		return SenseNone
+	case draw < pilot.NonComplianceProbability+pilot.OppositeResponseProbability:
+		return sense.opposite()
+	default:
+		return sense
+	}
+}
+
+// timeToStrengthen is how long TCAS lets a standard pilot fly an initial RA before checking
+// whether it resolves the conflict: the response delay plus the time to reach the target rate.
+func timeToStrengthen() time.Duration {
+	pilot := DefaultPilotModel
+	return pilot.InitialResponseDelay + time.Duration(pilot.TargetVerticalRate/pilot.InitialAcceleration*float64(time.Second))
+}
@@ -0,0 +1,266 @@
+package aviation
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report is the safety and operations report of a simulation run, produced at its end for stakeholders.
+type Report struct {
+	Seed             int64
+	Start            time.Time
+	End              time.Time
+	EndReason        string // "duration reached", "collision" or "emergency stop"
+	Planes           int
+	Flights          int           // flights that took off
+	FlightsCompleted int           // flights that landed
+	FlightTime       time.Duration // time spent in the air by every flight, until it landed or the run ended
+	Engagements      []ReportEngagements
+	Crashes          []ReportCrash
+	Airports         []ReportAirport
+	CapabilityPairs  []ReportCapabilityPair
+}
+
+// EngagementCountNote explains how TCAS engagements are counted, wherever counts of them are shown.
+const EngagementCountNote = "Each plane's TCAS records its own engagement, so an encounter between two equipped planes counts twice."
+
+// ReportEngagements counts the TCAS engagements that reached an advisory, by how they ended, per plane
+// as told by EngagementCountNote.
+type ReportEngagements struct {
+	Advisory AdvisoryType
+	Total    int
+	Cleared  int // ended with the intruder clear of conflict
+	Collided int
+	Open     int // still going on when the run ended
+}
+
+// ReportCrash is a mid-air collision, reported once per pair of planes.
+type ReportCrash struct {
+	Time         time.Time
+	Plane        string
+	Intruder     string
+	FlightID     string
+	PlaneTCAS    TCASCapability
+	IntruderTCAS TCASCapability
+	Advisory     AdvisoryType // highest advisory own plane's TCAS reached before the impact
+	MissDistance float64      // meters
+	Location     string
+}
+
+// ReportAirport holds the operations of an airport during the run.
+type ReportAirport struct {
+	Serial            string
+	Runways           int
+	Takeoffs          int
+	Landings          int
+	MovementsPerHour  float64 // takeoffs and landings per hour of simulation time
+	RunwayUtilization float64 // share of the runways' time spent on takeoffs and landings, 0 to 1
+	DelayedTakeoffs   int     // takeoffs that had to wait for a runway
+	MeanGroundDelay   time.Duration
+	MaxGroundDelay    time.Duration
+}
+
+// ReportCapabilityPair counts the engagements raised by planes with one TCAS capability against intruders with another.
+type ReportCapabilityPair struct {
+	Own                  TCASCapability
+	Intruder             TCASCapability
+	Engagements          int
+	ResolutionAdvisories int
+	Collisions           int
+}
+
+// ReportRecorder gathers from the events of a run what the report needs but the simulation state does not keep:
+// how long runways were in use and how long planes waited on the ground for one.
+type ReportRecorder struct {
+	mu           sync.Mutex
+	waitingSince map[string]time.Time       // when each plane waiting to take off first found the runways busy
+	rollStart    map[string]time.Time       // when each plane taking off started its takeoff roll
+	runwayTime   map[string]time.Duration   // runway time spent on takeoffs and landings, by airport
+	groundDelays map[string][]time.Duration // how long every takeoff waited for a runway, by airport
+}
+
+// NewReportRecorder returns a ReportRecorder that has seen no event yet.
+func NewReportRecorder() *ReportRecorder {
+	return &ReportRecorder{
+		waitingSince: map[string]time.Time{},
+		rollStart:    map[string]time.Time{},
+		runwayTime:   map[string]time.Duration{},
+		groundDelays: map[string][]time.Duration{},
+	}
+}
+
+// Record is the EventBus handler feeding the recorder with the events of the run.
+func (recorder *ReportRecorder) Record(event Event) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	switch e := event.(type) {
+	case RunwayBusy:
+		if _, waiting := recorder.waitingSince[e.Plane]; e.Operation == "takeoff" && !waiting {
+			recorder.waitingSince[e.Plane] = e.Time
+		}
+	case TakeoffStarted:
+		delay := time.Duration(0)
+		if since, waiting := recorder.waitingSince[e.Plane]; waiting {
+			delay = e.Time.Sub(since)
+			delete(recorder.waitingSince, e.Plane)
+		}
+		recorder.groundDelays[e.Airport] = append(recorder.groundDelays[e.Airport], delay)
+		recorder.rollStart[e.Plane] = e.Time
+	case TakeoffCompleted:
+		if start, rolling := recorder.rollStart[e.Plane]; rolling {
+			recorder.runwayTime[e.Airport] += e.Time.Sub(start)
+			delete(recorder.rollStart, e.Plane)
+		}
+	case LandingCompleted:
+		// a landing holds its runway for LandingDuration right before it completes
+		recorder.runwayTime[e.Airport] += LandingDuration
+	}
+}
+
+// BuildReport builds the report of the run that went from start to end, from the final state of the simulation
+// and what the recorder saw of the run. A nil recorder leaves runway utilization and ground delays at zero.
+func (simState *SimulationState) BuildReport(recorder *ReportRecorder, start, end time.Time, endReason string) Report {
+	report := Report{Seed: simState.Seed, Start: start, End: end, EndReason: endReason}
+	planes := simState.AllPlanes()
+	report.Planes = len(planes)
+	capabilities := map[string]TCASCapability{}
+	for _, plane := range planes {
+		capabilities[plane.Serial] = plane.TCASCapability
+	}
+
+	takeoffs, landings := map[string]int{}, map[string]int{}
+	engagements := map[AdvisoryType]*ReportEngagements{}
+	pairs := map[[2]TCASCapability]*ReportCapabilityPair{}
+	for _, plane := range planes {
+		for _, flight := range plane.FlightLog {
+			report.Flights++
+			takeoffs[flight.DepatureAirPort]++
+			landed := flight.ActualLandingTime
+			if !landed.IsZero() {
+				report.FlightsCompleted++
+				landings[flight.ArrivalAirPort]++
+			} else {
+				landed = end
+			}
+			if landed.After(flight.TakeoffTime) {
+				report.FlightTime += landed.Sub(flight.TakeoffTime)
+			}
+		}
+		for _, engagement := range append(append([]TCASEngagement{}, plane.TCASEngagementRecords...), plane.CurrentTCASEngagements...) {
+			counts := engagements[engagement.Advisory]
+			if counts == nil {
+				counts = &ReportEngagements{Advisory: engagement.Advisory}
+				engagements[engagement.Advisory] = counts
+			}
+			counts.Total++
+			switch {
+			case engagement.Collided:
+				counts.Collided++
+			case engagement.ClosedTime.IsZero():
+				counts.Open++
+			default:
+				counts.Cleared++
+			}
+
+			key := [2]TCASCapability{plane.TCASCapability, capabilities[engagement.OtherPlaneSerial]}
+			pair := pairs[key]
+			if pair == nil {
+				pair = &ReportCapabilityPair{Own: key[0], Intruder: key[1]}
+				pairs[key] = pair
+			}
+			pair.Engagements++
+			if engagement.Advisory == AdvisoryRA {
+				pair.ResolutionAdvisories++
+			}
+			if engagement.Collided {
+				pair.Collisions++
+			}
+		}
+	}
+	for _, advisory := range []AdvisoryType{AdvisoryTA, AdvisoryRA} {
+		if counts := engagements[advisory]; counts != nil {
+			report.Engagements = append(report.Engagements, *counts)
+		} else {
+			report.Engagements = append(report.Engagements, ReportEngagements{Advisory: advisory})
+		}
+	}
+	for _, pair := range pairs {
+		report.CapabilityPairs = append(report.CapabilityPairs, *pair)
+	}
+	sort.Slice(report.CapabilityPairs, func(i, j int) bool {
+		a, b := report.CapabilityPairs[i], report.CapabilityPairs[j]
+		if a.Own != b.Own {
+			return a.Own < b.Own
+		}
+		return a.Intruder < b.Intruder
+	})
+
+	// a collision is reported by both planes, and may be reported again before the run stops
+	simState.Mu.Lock()
+	collisions := append([]TCASEngagement{}, simState.Collisions...)
+	simState.Mu.Unlock()
+	crashed := map[[2]string]bool{}
+	for _, engagement := range collisions {
+		pair := [2]string{min(engagement.PlaneSerial, engagement.OtherPlaneSerial), max(engagement.PlaneSerial, engagement.OtherPlaneSerial)}
+		if crashed[pair] {
+			continue
+		}
+		crashed[pair] = true
+		crash := ReportCrash{
+			Time:         engagement.TimeOfEngagement,
+			Plane:        engagement.PlaneSerial,
+			Intruder:     engagement.OtherPlaneSerial,
+			FlightID:     engagement.FlightID,
+			PlaneTCAS:    capabilities[engagement.PlaneSerial],
+			IntruderTCAS: capabilities[engagement.OtherPlaneSerial],
+			Advisory:     engagement.Advisory,
+			MissDistance: engagement.MissDistance,
+		}
+		if flight, _, ok := simState.FindFlight(engagement.FlightID); ok {
+			crash.Location = simState.DescribeLocation(flight.TrackPositionAt(engagement.TimeOfEngagement))
+		}
+		report.Crashes = append(report.Crashes, crash)
+	}
+	sort.Slice(report.Crashes, func(i, j int) bool { return report.Crashes[i].Time.Before(report.Crashes[j].Time) })
+
+	var runwayTime map[string]time.Duration
+	var groundDelays map[string][]time.Duration
+	if recorder != nil {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		runwayTime, groundDelays = recorder.runwayTime, recorder.groundDelays
+	}
+	duration := end.Sub(start)
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		runways, _ := airport.Runways()
+		airport.Mu.Unlock()
+		operations := ReportAirport{
+			Serial:   airport.Serial,
+			Runways:  runways,
+			Takeoffs: takeoffs[airport.Serial],
+			Landings: landings[airport.Serial],
+		}
+		if duration > 0 {
+			operations.MovementsPerHour = float64(operations.Takeoffs+operations.Landings) / duration.Hours()
+			if runways > 0 {
+				operations.RunwayUtilization = min(1, float64(runwayTime[airport.Serial])/float64(time.Duration(runways)*duration))
+			}
+		}
+		delays := groundDelays[airport.Serial]
+		var total time.Duration
+		for _, delay := range delays {
+			total += delay
+			operations.MaxGroundDelay = max(operations.MaxGroundDelay, delay)
+			if delay > 0 {
+				operations.DelayedTakeoffs++
+			}
+		}
+		if len(delays) > 0 {
+			operations.MeanGroundDelay = total / time.Duration(len(delays))
+		}
+		report.Airports = append(report.Airports, operations)
+	}
+	return report
+}
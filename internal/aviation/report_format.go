@@ -0,0 +1,201 @@
+package aviation
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// reportTitle is the title of every rendering of a Report.
+const reportTitle = "TCAS simulation report"
+
+// reportSection is a table of the report, rendered alike in text, Markdown and HTML.
+type reportSection struct {
+	title  string
+	note   string // explains how to read the table, may be empty
+	empty  string // shown in place of a table without rows
+	header []string
+	rows   [][]string
+}
+
+// summary returns the headline figures of the report as label and value pairs.
+func (report Report) summary() [][2]string {
+	engagements, crashes := 0, len(report.Crashes)
+	for _, counts := range report.Engagements {
+		engagements += counts.Total
+	}
+	return [][2]string{
+		{"Seed", strconv.FormatInt(report.Seed, 10)},
+		{"Simulated period", fmt.Sprintf("%s to %s (%s)", report.Start.Format(LogTimeFormat), report.End.Format(LogTimeFormat),
+			reportDuration(report.End.Sub(report.Start)))},
+		{"Ended by", report.EndReason},
+		{"Planes", strconv.Itoa(report.Planes)},
+		{"Flights", fmt.Sprintf("%d took off, %d completed", report.Flights, report.FlightsCompleted)},
+		{"Total flight time", fmt.Sprintf("%.1f hours", report.FlightTime.Hours())},
+		{"TCAS engagements", strconv.Itoa(engagements)},
+		{"Crashes", strconv.Itoa(crashes)},
+	}
+}
+
+// sections returns the tables of the report.
+func (report Report) sections() []reportSection {
+	engagements := reportSection{
+		title:  "TCAS engagements by advisory and outcome",
+		note:   EngagementCountNote,
+		header: []string{"Advisory", "Total", "Cleared", "Collided", "Open"},
+	}
+	for _, counts := range report.Engagements {
+		engagements.rows = append(engagements.rows, []string{counts.Advisory.String(), strconv.Itoa(counts.Total),
+			strconv.Itoa(counts.Cleared), strconv.Itoa(counts.Collided), strconv.Itoa(counts.Open)})
+	}
+
+	crashes := reportSection{
+		title:  "Crashes",
+		empty:  "No mid-air collision.",
+		header: []string{"Time", "Plane", "Intruder", "Flight", "TCAS", "Advisory", "Miss distance", "Location"},
+	}
+	for _, crash := range report.Crashes {
+		crashes.rows = append(crashes.rows, []string{crash.Time.Format(LogTimeFormat), crash.Plane, crash.Intruder, crash.FlightID,
+			TCASCapabilityName(crash.PlaneTCAS) + " / " + TCASCapabilityName(crash.IntruderTCAS), crash.Advisory.String(),
+			fmt.Sprintf("%.1f m", crash.MissDistance), crash.Location})
+	}
+
+	airports := reportSection{
+		title: "Airport operations",
+		note:  "Runway utilization is the share of the runways' time spent on takeoffs and landings; ground delay is how long takeoffs waited for a runway.",
+		empty: "No airport.",
+		header: []string{"Airport", "Runways", "Takeoffs", "Landings", "Movements/hour", "Runway utilization",
+			"Delayed takeoffs", "Mean ground delay", "Max ground delay"},
+	}
+	for _, airport := range report.Airports {
+		airports.rows = append(airports.rows, []string{airport.Serial, strconv.Itoa(airport.Runways), strconv.Itoa(airport.Takeoffs),
+			strconv.Itoa(airport.Landings), fmt.Sprintf("%.1f", airport.MovementsPerHour), fmt.Sprintf("%.1f%%", 100*airport.RunwayUtilization),
+			strconv.Itoa(airport.DelayedTakeoffs), reportDuration(airport.MeanGroundDelay), reportDuration(airport.MaxGroundDelay)})
+	}
+
+	pairs := reportSection{
+		title:  "TCAS engagements by capability pair",
+		note:   "Engagements raised by the TCAS of own plane, by the TCAS capability of own plane and of the intruder.",
+		empty:  "No TCAS engagement.",
+		header: []string{"Own TCAS", "Intruder TCAS", "Engagements", "Resolution Advisories", "Collisions"},
+	}
+	for _, pair := range report.CapabilityPairs {
+		pairs.rows = append(pairs.rows, []string{TCASCapabilityName(pair.Own), TCASCapabilityName(pair.Intruder),
+			strconv.Itoa(pair.Engagements), strconv.Itoa(pair.ResolutionAdvisories), strconv.Itoa(pair.Collisions)})
+	}
+	return []reportSection{engagements, crashes, airports, pairs}
+}
+
+// WriteText writes the report as plain text with aligned columns.
+func (report Report) WriteText(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(out, "%s\n%s\n\n", reportTitle, strings.Repeat("=", len(reportTitle)))
+	for _, line := range report.summary() {
+		fmt.Fprintf(table, "%s:\t%s\n", line[0], line[1])
+	}
+	table.Flush()
+
+	for _, section := range report.sections() {
+		fmt.Fprintf(out, "\n%s\n%s\n", section.title, strings.Repeat("-", len(section.title)))
+		if section.note != "" {
+			fmt.Fprintln(out, section.note)
+		}
+		if len(section.rows) == 0 {
+			fmt.Fprintln(out, section.empty)
+			continue
+		}
+		fmt.Fprintln(table, strings.Join(section.header, "\t"))
+		for _, row := range section.rows {
+			fmt.Fprintln(table, strings.Join(row, "\t"))
+		}
+		table.Flush()
+	}
+	return out.Flush()
+}
+
+// WriteMarkdown writes the report as a Markdown document.
+func (report Report) WriteMarkdown(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "# %s\n\n", reportTitle)
+	for _, line := range report.summary() {
+		fmt.Fprintf(out, "- **%s:** %s\n", line[0], markdownEscape(line[1]))
+	}
+
+	for _, section := range report.sections() {
+		fmt.Fprintf(out, "\n## %s\n\n", section.title)
+		if section.note != "" {
+			fmt.Fprintf(out, "%s\n\n", section.note)
+		}
+		if len(section.rows) == 0 {
+			fmt.Fprintln(out, section.empty)
+			continue
+		}
+		fmt.Fprintf(out, "| %s |\n", strings.Join(section.header, " | "))
+		fmt.Fprintf(out, "|%s\n", strings.Repeat(" --- |", len(section.header)))
+		for _, row := range section.rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = markdownEscape(cell)
+			}
+			fmt.Fprintf(out, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+	return out.Flush()
+}
+
+// WriteHTML writes the report as a standalone HTML page.
+func (report Report) WriteHTML(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "<!DOCTYPE html>")
+	fmt.Fprintln(out, `<html lang="en">`)
+	fmt.Fprintf(out, "<head><meta charset=\"utf-8\"><title>%s (seed %d)</title>\n", reportTitle, report.Seed)
+	fmt.Fprintln(out, "<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:1em}"+
+		"th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}th{background:#eee}.note{color:#555}</style></head>")
+	fmt.Fprintf(out, "<body>\n<h1>%s</h1>\n<table>\n", reportTitle)
+	for _, line := range report.summary() {
+		fmt.Fprintf(out, "<tr><th>%s</th><td>%s</td></tr>\n", html.EscapeString(line[0]), html.EscapeString(line[1]))
+	}
+	fmt.Fprintln(out, "</table>")
+
+	for _, section := range report.sections() {
+		fmt.Fprintf(out, "<h2>%s</h2>\n", html.EscapeString(section.title))
+		if section.note != "" {
+			fmt.Fprintf(out, "<p class=\"note\">%s</p>\n", html.EscapeString(section.note))
+		}
+		if len(section.rows) == 0 {
+			fmt.Fprintf(out, "<p>%s</p>\n", html.EscapeString(section.empty))
+			continue
+		}
+		fmt.Fprint(out, "<table>\n<tr>")
+		for _, cell := range section.header {
+			fmt.Fprintf(out, "<th>%s</th>", html.EscapeString(cell))
+		}
+		fmt.Fprintln(out, "</tr>")
+		for _, row := range section.rows {
+			fmt.Fprint(out, "<tr>")
+			for _, cell := range row {
+				fmt.Fprintf(out, "<td>%s</td>", html.EscapeString(cell))
+			}
+			fmt.Fprintln(out, "</tr>")
+		}
+		fmt.Fprintln(out, "</table>")
+	}
+	fmt.Fprintln(out, "</body>\n</html>")
+	return out.Flush()
+}
+
+// reportDuration formats a duration of the report to the second.
+func reportDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// markdownEscape escapes the characters that would break a Markdown table cell or list item.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`|`, `\|`, `*`, `\*`, `_`, `\_`).Replace(s)
+}
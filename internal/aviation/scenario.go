@@ -0,0 +1,363 @@
+package aviation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+	"github.com/josephus-git/TCAS-simulation/internal/util"
+)
+
+// Scenario is a scripted world: its airports, its planes and a timetable of flights, loaded from a JSON file
+// in place of the random world of InitializeAirports. It is used to build encounters that can be rerun on demand.
+// A scenario without a timetable launches random departures from its airports like a generated world,
+// which is how a saved random world replays with its seed.
+type Scenario struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description,omitempty"`
+	Seed               int64             `json:"seed,omitempty"`                // master seed of the departures and crews' responses, 0 picks a random seed
+	DifferentAltitudes bool              `json:"different_altitudes,omitempty"` // draws the cruising altitude of flights without one
+	Origin             *GeoCoordinate    `json:"origin,omitempty"`              // places the map on the Earth, airports are then given in latitude/longitude
+	Airports           []ScenarioAirport `json:"airports"`
+	Planes             []ScenarioPlane   `json:"planes"`
+	Flights            []ScenarioFlight  `json:"flights"`
+}
+
+// ScenarioAirport declares an airport of a scenario.
+type ScenarioAirport struct {
+	Serial   string           `json:"serial"`
+	Location ScenarioLocation `json:"location"`
+	Runways  int              `json:"runways"`
+}
+
+// ScenarioLocation is where an airport of a scenario is: x and y in meters on a flat map,
+// or latitude and longitude in degrees when the scenario has an origin.
+type ScenarioLocation struct {
+	X         *float64 `json:"x,omitempty"`
+	Y         *float64 `json:"y,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// ScenarioPlane declares a plane of a scenario, parked at its home airport when the simulation starts.
+// A plane declaring how its crew responds to initial RAs flies that response exactly; the crews of the
+// other planes are drawn around the configured one.
+type ScenarioPlane struct {
+	Serial          string           `json:"serial"`
+	Type            string           `json:"type"`                        // aircraft type designator from the catalogue
+	TCAS            string           `json:"tcas,omitempty"`              // perfect, faulty or none, the type's default equipage when empty
+	Home            string           `json:"home"`                        // serial of the airport the plane is parked at
+	ResponseDelay   ScenarioDuration `json:"response_delay,omitempty"`    // delay before the crew starts an RA maneuver, e.g. "5s"
+	AccelerationG   float64          `json:"acceleration_g,omitempty"`    // vertical acceleration of the crew's RA maneuver
+	VerticalRateFpm float64          `json:"vertical_rate_fpm,omitempty"` // vertical rate the crew flies for an RA
+}
+
+// declaresCrew reports whether the plane declares how its crew responds to RAs.
+func (plane ScenarioPlane) declaresCrew() bool {
+	return plane.ResponseDelay != 0 || plane.AccelerationG != 0 || plane.VerticalRateFpm != 0
+}
+
+// ScenarioFlight is a flight of a scenario's timetable: the plane departs from the airport it is parked at.
+type ScenarioFlight struct {
+	Plane              string           `json:"plane"`
+	Departure          ScenarioDuration `json:"departure"`   // time after the start of the simulation, e.g. "1m30s"
+	Destination        string           `json:"destination"` // serial of the destination airport
+	CruisingAltitudeFt float64          `json:"cruising_altitude_ft,omitempty"`
+}
+
+// ScenarioDuration is a duration written in scenario files the way Go prints it, e.g. "1m30s".
+type ScenarioDuration time.Duration
+
+// MarshalJSON writes the duration as a string.
+func (d ScenarioDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads a duration string such as "90s" or "1m30s".
+func (d *ScenarioDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: use a duration such as \"1m30s\"", data)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: use a duration such as \"1m30s\"", s)
+	}
+	*d = ScenarioDuration(duration)
+	return nil
+}
+
+// LoadScenario reads and validates a scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	scenario := &Scenario{}
+	if err := decoder.Decode(scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return scenario, nil
+}
+
+// TCASCapabilityName returns how a TCAS capability is written in scenario files and exports.
+func TCASCapabilityName(capability TCASCapability) string {
+	switch capability {
+	case TCASFaulty:
+		return "faulty"
+	case TCASNone:
+		return "none"
+	default:
+		return "perfect"
+	}
+}
+
+// parseTCASCapability parses the TCAS equipage of a scenario plane.
+func parseTCASCapability(s string) (TCASCapability, error) {
+	switch strings.ToLower(s) {
+	case "perfect":
+		return TCASPerfect, nil
+	case "faulty":
+		return TCASFaulty, nil
+	case "none":
+		return TCASNone, nil
+	default:
+		return 0, fmt.Errorf("invalid TCAS capability %q: use perfect, faulty or none", s)
+	}
+}
+
+// Validate checks that the scenario is consistent: unique serials, known aircraft types, airports and planes,
+// and a timetable in which every plane departs from the airport its previous flight landed at.
+func (scenario *Scenario) Validate() error {
+	if len(scenario.Airports) < 2 {
+		return fmt.Errorf("a scenario needs at least 2 airports")
+	}
+	airports := map[string]bool{}
+	for _, airport := range scenario.Airports {
+		if airport.Serial == "" || airports[airport.Serial] {
+			return fmt.Errorf("airport serial %q is empty or declared twice", airport.Serial)
+		}
+		airports[airport.Serial] = true
+		if airport.Runways < 1 {
+			return fmt.Errorf("airport %s needs at least 1 runway", airport.Serial)
+		}
+		location := airport.Location
+		if scenario.Origin == nil && (location.X == nil || location.Y == nil) {
+			return fmt.Errorf("airport %s needs an x and y location on a flat map", airport.Serial)
+		}
+		if scenario.Origin != nil && (location.Latitude == nil || location.Longitude == nil) {
+			return fmt.Errorf("airport %s needs a latitude and longitude location in a scenario with an origin", airport.Serial)
+		}
+	}
+
+	locations := map[string]string{}
+	for _, plane := range scenario.Planes {
+		if _, declared := locations[plane.Serial]; plane.Serial == "" || declared {
+			return fmt.Errorf("plane serial %q is empty or declared twice", plane.Serial)
+		}
+		if _, ok := LookupAircraftType(plane.Type); !ok {
+			return fmt.Errorf("plane %s has unknown aircraft type %q, known types are %s",
+				plane.Serial, plane.Type, strings.Join(AircraftDesignators(), ", "))
+		}
+		if plane.TCAS != "" {
+			if _, err := parseTCASCapability(plane.TCAS); err != nil {
+				return fmt.Errorf("plane %s: %w", plane.Serial, err)
+			}
+		}
+		if !airports[plane.Home] {
+			return fmt.Errorf("plane %s has unknown home airport %q", plane.Serial, plane.Home)
+		}
+		if plane.ResponseDelay < 0 || plane.AccelerationG < 0 || plane.VerticalRateFpm < 0 {
+			return fmt.Errorf("plane %s has a negative crew response delay, acceleration or vertical rate", plane.Serial)
+		}
+		locations[plane.Serial] = plane.Home
+	}
+
+	for _, flight := range scenario.Timetable() {
+		location, ok := locations[flight.Plane]
+		if !ok {
+			return fmt.Errorf("flight at %s is flown by unknown plane %q", time.Duration(flight.Departure), flight.Plane)
+		}
+		if flight.Departure < 0 {
+			return fmt.Errorf("flight of plane %s departs before the simulation starts", flight.Plane)
+		}
+		if !airports[flight.Destination] {
+			return fmt.Errorf("flight of plane %s at %s has unknown destination %q", flight.Plane, time.Duration(flight.Departure), flight.Destination)
+		}
+		if flight.Destination == location {
+			return fmt.Errorf("flight of plane %s at %s departs from its destination %s", flight.Plane, time.Duration(flight.Departure), location)
+		}
+		if flight.CruisingAltitudeFt < 0 {
+			return fmt.Errorf("flight of plane %s at %s has a negative cruising altitude", flight.Plane, time.Duration(flight.Departure))
+		}
+		locations[flight.Plane] = flight.Destination
+	}
+	return nil
+}
+
+// Timetable returns the scenario's flights sorted by departure time.
+func (scenario *Scenario) Timetable() []ScenarioFlight {
+	flights := append([]ScenarioFlight{}, scenario.Flights...)
+	sort.SliceStable(flights, func(i, j int) bool { return flights[i].Departure < flights[j].Departure })
+	return flights
+}
+
+// InitializeScenario builds the world declared by a scenario in place of a random one.
+// The configured seed wins over the scenario's, so a scripted encounter can be replayed with other crew responses.
+func InitializeScenario(conf *config.Config, simState *SimulationState, scenario *Scenario) {
+	if conf.Seed == 0 {
+		conf.Seed = scenario.Seed
+	}
+	if conf.Seed == 0 {
+		conf.Seed = util.NewSeed()
+	}
+	simState.Seed = conf.Seed
+	simState.DifferentAltitudes = scenario.DifferentAltitudes || conf.DifferentAltitudes
+	simState.TrackInterval = conf.TrackInterval
+	simState.CollisionThreshold = conf.CollisionThreshold
+	if simState.CollisionThreshold <= 0 {
+		simState.CollisionThreshold = CollisionThreshold
+	}
+	simState.Airports = []*Airport{}
+	simState.PlanesInFlight = []Plane{}
+	simState.Frame = nil
+	if scenario.Origin != nil {
+		simState.Frame = &ENUFrame{Origin: GeoCoordinate{Latitude: scenario.Origin.Latitude, Longitude: scenario.Origin.Longitude}}
+	}
+	simState.Scenario = scenario
+	pilot := configuredPilotModel(conf)
+	crewRand := util.NewRand(conf.Seed, "crews")
+
+	airports := map[string]*Airport{}
+	for _, declared := range scenario.Airports {
+		airport := &Airport{
+			Serial: declared.Serial,
+			Runway: runway{numberOfRunway: declared.Runways},
+			rand:   util.NewRand(conf.Seed, "airport/"+declared.Serial),
+		}
+		if simState.Frame != nil {
+			airport.Location = simState.Frame.ToMap(GeoCoordinate{Latitude: *declared.Location.Latitude, Longitude: *declared.Location.Longitude})
+		} else {
+			airport.Location = Coordinate{X: *declared.Location.X, Y: *declared.Location.Y}
+		}
+		airports[declared.Serial] = airport
+		simState.Airports = append(simState.Airports, airport)
+	}
+	for _, declared := range scenario.Planes {
+		aircraftType, _ := LookupAircraftType(declared.Type)
+		capability := aircraftType.TCAS
+		if declared.TCAS != "" {
+			capability, _ = parseTCASCapability(declared.TCAS)
+		}
+		// every plane draws its crew, so that declaring one keeps the crews of the others
+		crew := pilot.vary(conf.PilotVariation, crewRand)
+		if declared.declaresCrew() {
+			crew = pilot.WithResponse(time.Duration(declared.ResponseDelay), declared.AccelerationG, declared.VerticalRateFpm)
+		}
+		home := airports[declared.Home]
+		home.Planes = append(home.Planes, Plane{
+			Serial:         declared.Serial,
+			AircraftType:   aircraftType,
+			CruiseSpeed:    aircraftType.CruiseSpeed,
+			FlightLog:      []Flight{},
+			TCASCapability: capability,
+			Pilot:          crew,
+		})
+		home.InitialPlaneAmount++
+	}
+
+	if !simState.Quiet {
+		fmt.Printf("\nLoaded scenario %q: %d airports, %d planes, %d scheduled flights (seed %d).\n\n",
+			scenario.Name, len(simState.Airports), len(scenario.Planes), len(scenario.Flights), conf.Seed)
+	}
+}
+
+// ExportScenario returns the current world as a scenario that loads back into the same world: its seed,
+// its airports with their locations and runways, and every plane with its type, TCAS and airport.
+// Parked planes are saved at the airport they are parked at and planes in flight at their destination.
+// The timetable of a loaded scenario is kept as long as none of its planes has flown.
+func (simState *SimulationState) ExportScenario(name string) *Scenario {
+	scenario := &Scenario{
+		Name:               name,
+		Seed:               simState.Seed,
+		DifferentAltitudes: simState.DifferentAltitudes,
+	}
+	if simState.Frame != nil {
+		scenario.Origin = &GeoCoordinate{Latitude: simState.Frame.Origin.Latitude, Longitude: simState.Frame.Origin.Longitude}
+	}
+
+	for _, airport := range simState.Airports {
+		declared := ScenarioAirport{Serial: airport.Serial}
+		airport.Mu.Lock()
+		declared.Runways = airport.Runway.numberOfRunway
+		airport.Mu.Unlock()
+		if simState.Frame != nil {
+			location := simState.Frame.FromMap(airport.Location)
+			declared.Location = ScenarioLocation{Latitude: &location.Latitude, Longitude: &location.Longitude}
+		} else {
+			x, y := airport.Location.X, airport.Location.Y
+			declared.Location = ScenarioLocation{X: &x, Y: &y}
+		}
+		scenario.Airports = append(scenario.Airports, declared)
+	}
+
+	flown := false
+	for _, plane := range simState.AllPlanes() {
+		home := ""
+		for _, airport := range simState.Airports {
+			airport.Mu.Lock()
+			for _, parked := range airport.Planes {
+				if parked.Serial == plane.Serial {
+					home = airport.Serial
+				}
+			}
+			airport.Mu.Unlock()
+		}
+		if home == "" && len(plane.FlightLog) > 0 {
+			home = plane.FlightLog[len(plane.FlightLog)-1].ArrivalAirPort
+		}
+		flown = flown || len(plane.FlightLog) > 0
+		declared := ScenarioPlane{
+			Serial: plane.Serial,
+			Type:   plane.AircraftType.Designator,
+			TCAS:   TCASCapabilityName(plane.TCASCapability),
+			Home:   home,
+		}
+		// a crew that is not the standard one is saved with its plane, so that it loads back the same
+		if crew := plane.Pilot; crew.InitialResponseDelay != DefaultPilotModel.InitialResponseDelay ||
+			crew.InitialAcceleration != DefaultPilotModel.InitialAcceleration || crew.TargetVerticalRate != DefaultPilotModel.TargetVerticalRate {
+			declared.ResponseDelay = ScenarioDuration(crew.InitialResponseDelay)
+			declared.AccelerationG = crew.InitialAcceleration / gravity
+			declared.VerticalRateFpm = crew.TargetVerticalRate / metersPerFoot * 60
+		}
+		scenario.Planes = append(scenario.Planes, declared)
+	}
+
+	if simState.Scenario != nil && !flown {
+		scenario.Description = simState.Scenario.Description
+		scenario.Flights = append(scenario.Flights, simState.Scenario.Flights...)
+	}
+	return scenario
+}
+
+// SaveScenario writes a scenario to a JSON file that LoadScenario reads back.
+func SaveScenario(scenario *Scenario, path string) error {
+	data, err := json.MarshalIndent(scenario, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scenario: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write scenario: %w", err)
+	}
+	return nil
+}
@@ -2,12 +2,15 @@ package aviation
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/josephus-git/TCAS-simulation/internal/config"
@@ -21,8 +24,83 @@ type SimulationState struct {
 	Mu                 sync.Mutex
 	SimStatusChannel   chan struct{}
 	DifferentAltitudes bool
-	SimIsRunning       bool
-	SimEndedTime       time.Time
+	SimIsRunning       atomic.Bool // read by every goroutine of a run, cleared as soon as the run is stopping
+	SimEndedTime       time.Time   // written under Mu when a run ends
+	Clock              Clock
+	Seed               int64
+	LogDir             string           // directory the run's log files are written to, "logs" when empty
+	Headless           bool             // true when no interactive console is attached
+	Collisions         []TCASEngagement // engagements that ended in a mid-air collision
+	CollisionThreshold float64          // distance below which a closest approach is a conflict
+	Quiet              bool             // suppresses console output, used by campaign runs
+	Frame              *ENUFrame        // geodetic reference of the map in geodetic mode, nil on a flat map
+	Scenario           *Scenario        // scripted world the simulation was loaded from, nil for a random world
+	Events             *EventBus        // receives every event of the runs, nil when nobody listens
+	TrackInterval      time.Duration    // how often planes in flight record their track, TrackInterval when 0
+
+	runMu       sync.Mutex         // guards cancelRun and stopTrigger, set by the run and read by whoever stops it
+	cancelRun   context.CancelFunc // ends the run in progress, nil when there is none
+	stopTrigger Timer              // ends the run once its duration is reached
+
+	radarMu   sync.Mutex    // guards radarStop and radarDone, set and closed by the console commands
+	radarStop chan struct{} // closed to close the live radar display, nil when it is not shown
+	radarDone chan struct{} // closed by the live radar display once it has drawn its last frame
+}
+
+// BeginRun records how to stop the run that is starting: cancel ends it and stopTrigger is the timer
+// ending it once its duration is reached.
+func (simState *SimulationState) BeginRun(cancel context.CancelFunc, stopTrigger Timer) {
+	simState.runMu.Lock()
+	defer simState.runMu.Unlock()
+	simState.cancelRun, simState.stopTrigger = cancel, stopTrigger
+}
+
+// EndRun forgets how to stop the current run and returns its cancel func and stop trigger,
+// a nil cancel func when no run was begun since the last call.
+func (simState *SimulationState) EndRun() (context.CancelFunc, Timer) {
+	simState.runMu.Lock()
+	defer simState.runMu.Unlock()
+	cancel, stopTrigger := simState.cancelRun, simState.stopTrigger
+	simState.cancelRun, simState.stopTrigger = nil, nil
+	return cancel, stopTrigger
+}
+
+// StartRadar closes the live radar display if it is shown and returns the channel closed once the display
+// that is starting is to be closed, and the channel the display closes once it has drawn its last frame.
+func (simState *SimulationState) StartRadar() (<-chan struct{}, chan<- struct{}) {
+	simState.radarMu.Lock()
+	defer simState.radarMu.Unlock()
+	simState.stopRadar()
+	simState.radarStop, simState.radarDone = make(chan struct{}), make(chan struct{})
+	return simState.radarStop, simState.radarDone
+}
+
+// StopRadar closes the live radar display if it is shown, and returns once it can no longer draw.
+func (simState *SimulationState) StopRadar() {
+	simState.radarMu.Lock()
+	defer simState.radarMu.Unlock()
+	simState.stopRadar()
+}
+
+// stopRadar closes the live radar display if it is shown and waits for its last frame, radarMu being held.
+func (simState *SimulationState) stopRadar() {
+	if simState.radarStop == nil {
+		return
+	}
+	close(simState.radarStop)
+	<-simState.radarDone
+	simState.radarStop, simState.radarDone = nil, nil
+}
+
+// LogTimeFormat is the layout of every time written to the simulation's human-readable logs.
+const LogTimeFormat = "2006-01-02 15:04:05"
+
+// LogPath returns the path of the named log file inside the simulation's log directory.
+func (simState *SimulationState) LogPath(name string) string {
+	if simState.LogDir == "" {
+		return filepath.Join("logs", name)
+	}
+	return filepath.Join(simState.LogDir, name)
 }
 
 // GetNumberOfPlanes prompts the user to input the desired number of planes for the simulation.
@@ -77,18 +155,48 @@ func GetNumberOfPlanes(conf *config.Config) {
 
 }
 
-// InitializeAirports creates appropriate amount of airports and airplanes
+// MapScale converts the coordinate generator's map units into meters, placing airports 50 to 150 km apart.
+const MapScale = 1000.0
+
+// InitializeAirports creates appropriate amount of airports and airplanes.
+// The whole world is drawn from the configured master seed, so the same seed always builds the same world.
 func InitializeAirports(conf *config.Config, simState *SimulationState) {
+	if conf.Seed == 0 {
+		conf.Seed = util.NewSeed()
+	}
+	simState.Seed = conf.Seed
 	simState.DifferentAltitudes = conf.DifferentAltitudes
+	simState.TrackInterval = conf.TrackInterval
+	simState.CollisionThreshold = conf.CollisionThreshold
+	if simState.CollisionThreshold <= 0 {
+		simState.CollisionThreshold = CollisionThreshold
+	}
+	simState.Airports = []*Airport{}
+	simState.PlanesInFlight = []Plane{}
+	simState.Frame = nil
+	simState.Scenario = nil
+	if conf.Geodetic {
+		simState.Frame = &ENUFrame{Origin: GeoCoordinate{Latitude: conf.OriginLatitude, Longitude: conf.OriginLongitude}}
+	}
+	r := util.NewRand(conf.Seed, "layout")
+	pilot := configuredPilotModel(conf)
+	// crews are drawn from their own stream so that varying them keeps the same world
+	crewRand := util.NewRand(conf.Seed, "crews")
+	fleetMix := conf.FleetMix
+	if len(fleetMix) == 0 {
+		fleetMix = DefaultFleetMix
+	}
+	// the fleet is drawn from its own stream so that changing the mix keeps the same airports
+	fleetRand := util.NewRand(conf.Seed, "fleet")
 
 	planesCreated := 0
 	airportsCreated := 0
 
 	for i := 0; planesCreated < conf.NoOfAirplanes; i++ {
-		newAirport := createAirport(airportsCreated, planesCreated, conf.NoOfAirplanes)
+		newAirport := createAirport(airportsCreated, planesCreated, conf.NoOfAirplanes, r, conf.Seed)
 		planesGenerated := planesCreated
 		for range newAirport.InitialPlaneAmount {
-			newPlane := createPlane(planesGenerated)
+			newPlane := createPlane(planesGenerated, r, conf.FaultyTCASRatio, pilot.vary(conf.PilotVariation, crewRand), drawAircraftType(fleetMix, fleetRand))
 			newAirport.Planes = append(newAirport.Planes, newPlane)
 			planesGenerated += 1
 		}
@@ -97,15 +205,17 @@ func InitializeAirports(conf *config.Config, simState *SimulationState) {
 		airportsCreated = i + 1
 	}
 
-	listOfAirportCoordinates := generateCoordinates(len(simState.Airports))
+	listOfAirportCoordinates := generateCoordinates(len(simState.Airports), r)
 
 	for i := range simState.Airports {
-		newLocation := Coordinate{listOfAirportCoordinates[i].X, listOfAirportCoordinates[i].Y, 0.0}
+		newLocation := Coordinate{listOfAirportCoordinates[i].X * MapScale, listOfAirportCoordinates[i].Y * MapScale, 0.0}
 		simState.Airports[i].Location = newLocation
 	}
 
-	fmt.Printf("\nInitialized: %d airports, %d planes distributed among airports.\n\n",
-		len(simState.Airports), conf.NoOfAirplanes)
+	if !simState.Quiet {
+		fmt.Printf("\nInitialized: %d airports, %d planes distributed among airports (seed %d).\n\n",
+			len(simState.Airports), conf.NoOfAirplanes, conf.Seed)
+	}
 }
 
 // Point represents a 2D coordinate with X and Y components.
@@ -138,14 +248,11 @@ func calculateDistance(p1, p2 Point) float64 {
 //     points are primarily generated outward from the coordinate that is currently
 //     farthest from the origin (0,0) among all existing points. They will be placed
 //     at least 50 units away from this "most distant" point.
-func generateCoordinates(numCoordinates int) []Point {
+func generateCoordinates(numCoordinates int, r *rand.Rand) []Point {
 	if numCoordinates <= 0 {
 		return []Point{}
 	}
 
-	// Initialize random number generator with a unique seed based on current time.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
 	coordinates := make([]Point, 0, numCoordinates)
 	minDist := 50.0 // The minimum required distance between any two coordinates
 
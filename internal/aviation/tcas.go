@@ -2,156 +2,364 @@ package aviation
 
 import (
 	"fmt"
+	"log"
+	"math"
 	"math/rand"
 	"os"
-	"sort"
+	"strings"
 	"time"
 
 	"github.com/josephus-git/TCAS-simulation/internal/util"
 )
 
+// TCASEngagement records an encounter between a plane and an intruder, as seen by the plane's own TCAS.
+// It is opened when the first advisory is issued, updated every surveillance cycle while it is open
+// and closed once the intruder is clear of conflict.
 type TCASEngagement struct {
 	EngagementID     string
 	FlightID         string
 	PlaneSerial      string
 	OtherPlaneSerial string
-	TimeOfEngagement time.Time
-	WillCrash        bool
-	WarningTriggered bool
+	TimeOfEngagement time.Time    // predicted time of closest approach, updated every cycle
+	MissDistance     float64      // predicted distance at the closest approach in meters, updated every cycle
+	Advisory         AdvisoryType // highest advisory reached during the encounter
+	TATime           time.Time    // when the Traffic Advisory is issued
+	RATime           time.Time    // when the Resolution Advisory is issued, zero for TA-only encounters
+	SensitivityLevel int          // TCAS sensitivity level in use when the highest advisory was issued
+	Sense            RASense      // vertical sense commanded by the Resolution Advisory
+	Coordinated      bool         // whether the sense was complemented to the intruder's over the Mode S link
+	StrengthenedTime time.Time    // when the RA was strengthened to a higher vertical rate, zero if it never was
+	PilotResponse    RASense      // sense the crew actually flies, SenseNone when they do not comply
+	ClosedTime       time.Time    // when the intruder was declared clear of conflict, zero while open
+	Collided         bool         // whether the encounter ended in a mid-air collision
 }
 
-// CollisionThreshold defines the maximum distance (in units) at which two planes are considered to be in a collision course.
+// CollisionThreshold defines the default distance (in meters) below which two planes collide.
+// A run may override it through SimulationState.CollisionThreshold.
 const CollisionThreshold = 5
 
-// CheckPlaneStatusAtTime checks the status of a plane at a specific time based on its flight log.
-func checkPlaneStatusAtTime(p Plane, checkTime time.Time) string {
-	flight := p.FlightLog[len(p.FlightLog)-1]
+// SurveillanceRange is the horizontal distance (in meters) up to which TCAS tracks intruders, about 30 nautical miles.
+const SurveillanceRange = 30 * metersPerNauticalMile
 
-	if checkTime.After(flight.DestinationArrivalTime) {
-		// If checkTime is after arrival, plane has landed for this flight
-		return "landed or still landing"
-	}
-	if checkTime.After(flight.TakeoffTime) && checkTime.Before(flight.DestinationArrivalTime) {
-		// Plane is in transit during this flight
-		// We need a more granular check for "about to land"
-		// This simplified logic assumes "in transit" or "landed" for past times.
-		// For future "about to land" it would be based on calculation.
-		// Given the 3-second prior check, if it's already "about to land" it should be reflected in FlightStatus.
-		if flight.FlightStatus == "about to land" {
-			return "about to land"
-		}
-		return "in transit"
-	}
-	if checkTime.Equal(flight.TakeoffTime) {
-		return "taking off"
-	}
-	if checkTime.Equal(flight.DestinationArrivalTime) {
-		return "arriving"
-	}
-	return "parked/unknown" // If no flight matches the time, assume parked or not in a known flight.
-}
-
-// tcas detects potential mid-air collisions between a given plane (the one about to take off)
-// and other planes currently in flight. If a collision is predicted under specific conditions,
-// it triggers an emergency stop.
+// Surveil runs one TCAS surveillance cycle for every plane in flight at the current simulation time.
+//
+// Every plane first flies its vertical maneuver since the previous cycle. Each plane's TCAS then tracks
+// the intruders within SurveillanceRange and evaluates them with the TCAS II threat logic: an engagement
+// is opened when the first advisory is issued against an intruder, updated while the geometry evolves
+// and closed once the intruder is clear of conflict, at which point it moves from CurrentTCASEngagements
+// to TCASEngagementRecords. A collision occurs when two planes come closer than the collision threshold
+// before the next cycle, whatever TCAS did about it. In geodetic mode every encounter is evaluated on a map
+// local to own plane. Close to the ground, below 1000 ft, TCAS still raises Traffic Advisories but inhibits
+// its RAs. Planes on the runway or waiting to land are not surveilled: they are separated by the tower.
 //
 // Parameters:
 //
-//	plane: The plane attempting to take off.
-//	tcasLog: The file pointer for logging planes condition before going on the flight.
-func (plane Plane) tcas(simState *SimulationState, tcasLog *os.File) []TCASEngagement {
-	planeFlight := plane.FlightLog[len(plane.FlightLog)-1]
-	simState.Mu.Lock() // Lock the simulation state to safely access PlanesInFlight
-	planesInFlight := simState.PlanesInFlight
-	simState.Mu.Unlock() // Release the lock after copying the slice
-
-	fmt.Fprintf(tcasLog, "%s TCAS: Plane %s (%v) is checking for conflicts before takeoff.\n\n",
-		time.Now().Format("2006-01-02 15:04:05"), plane.Serial, plane.TCASCapability)
-
-	tcasEngagementSlice := []TCASEngagement{}
-	for _, otherPlane := range planesInFlight {
-		// Skip checking against itself
-		if plane.Serial == otherPlane.Serial {
-			continue
-		}
+//	f: The file pointer for the simulation log.
+//	tcasLog: The file pointer for the TCAS log.
+//	r: The seeded random source drawing how crews respond to Resolution Advisories.
+//
+// Returns:
+//
+//	[]TCASEngagement: the encounters that ended in a mid-air collision during this cycle, one per pair of planes.
+func (simState *SimulationState) Surveil(f, tcasLog *os.File, r *rand.Rand) []TCASEngagement {
+	now := simState.Clock.Now()
+	// the cycle's events are published once the simulation state is unlocked
+	var events []Event
+	defer func() { simState.Events.Publish(events...) }()
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
 
-		// Ensure the other plane is indeed in flight (should be true for planesInFlight list, but good check)
-		if !otherPlane.PlaneInFlight {
-			continue
-		}
+	planes := simState.PlanesInFlight
+	positions := make([]Coordinate, len(planes))
+	velocities := make([]Coordinate, len(planes))
+	onGround := make([]bool, len(planes))
+	for i := range planes {
+		plane := &planes[i]
+		plane.flyVerticalManeuver(now, now.Sub(plane.verticalStateTime))
+		plane.verticalStateTime = now
 
-		// Find the current active flight for the otherPlane
-		otherPlaneFlight := otherPlane.FlightLog[len(otherPlane.FlightLog)-1]
+		positions[i] = plane.Position(now)
+		velocities[i] = plane.VelocityAt(now)
+		// planes taking off share the airport's location on different runways, planes past their arrival
+		// time wait there for a runway to land
+		onGround[i] = positions[i].Z < Epsilon || !now.Before(plane.FlightLog[len(plane.FlightLog)-1].DestinationArrivalTime)
+	}
 
-		// Calculate Closest Approach Details between the potential flight paths
-		closestTime, distanceAtCA := planeFlight.GetClosestApproachDetails(otherPlaneFlight)
+	collisions := []TCASEngagement{}
+	for i := range planes {
+		plane := &planes[i]
+		threats := map[string]bool{}
 
-		// Check the other plane's status at the closest approach
-		otherPlaneStatusAtCheckTime := checkPlaneStatusAtTime(otherPlane, closestTime)
+		for j := range planes {
+			intruder := &planes[j]
+			if i == j || onGround[i] || onGround[j] {
+				continue
+			}
+			if relativePosition := positions[j].subtract(positions[i]); math.Hypot(relativePosition.X, relativePosition.Y) > SurveillanceRange {
+				continue
+			}
+			ownPosition, ownVelocity, intruderPosition, intruderVelocity := positions[i], velocities[i], positions[j], velocities[j]
+			if simState.Frame != nil {
+				ownPosition, ownVelocity, intruderPosition, intruderVelocity = simState.Frame.localGeometry(ownPosition, ownVelocity, intruderPosition, intruderVelocity)
+			}
+			relativePosition := intruderPosition.subtract(ownPosition)
 
-		// Condition 1: If otherPlane has landed, is about to land or at different flight altitudes, no collision concern from altitude difference
-		if otherPlaneStatusAtCheckTime == "landed or still landing" || otherPlaneStatusAtCheckTime == "about to land" || otherPlaneFlight.CruisingAltitude != planeFlight.CruisingAltitude {
-			fmt.Fprintf(tcasLog, "%s TCAS: Plane %s's flight path %s and Plane %s's flight path %s have closest approach (%.2f units at %v), but no worries: Other plane status is '%s' or different altitude.\n\n",
-				time.Now().Format("15:04:05"), plane.Serial, planeFlight.FlightID, otherPlane.Serial, otherPlaneFlight.FlightID, distanceAtCA, closestTime.Format("15:04:05"), otherPlaneStatusAtCheckTime)
-			continue
-		}
+			// the planes collide if they come within the collision threshold before the next cycle
+			collisionIn, collisionDistance := closestApproachWithin(TCASCycleInterval, ownPosition, ownVelocity, intruderPosition, intruderVelocity)
+			collision := collisionDistance < simState.CollisionThreshold && i < j
 
-		// Condition 2: Check if collision distance threshold is met
-		if distanceAtCA < CollisionThreshold {
-			fmt.Fprintf(tcasLog, "%s TCAS ALERT: Potential collision detected between Plane %s (TCAS: %v) and Plane %s (TCAS: %v). Closest approach: %.2f units at %v.\n\n",
-				time.Now().Format("15:04:05"), plane.Serial, plane.TCASCapability, otherPlane.Serial, otherPlane.TCASCapability, distanceAtCA, closestTime.Format("15:04:05"))
-
-			// Collision Resolution based on TCAS capabilities
-			shouldCrash := false
-
-			if plane.TCASCapability == TCASPerfect && otherPlane.TCASCapability == TCASPerfect {
-				// Both perfect, no crash
-				fmt.Fprintf(tcasLog, "%s TCAS: Both planes have perfect TCAS. Collision averted between %s and %s.\n\n",
-					time.Now().Format("2006-01-02 15:04:05"), plane.Serial, otherPlane.Serial)
-				shouldCrash = false
-			} else if (plane.TCASCapability == TCASPerfect && otherPlane.TCASCapability == TCASFaulty) ||
-				(plane.TCASCapability == TCASFaulty && otherPlane.TCASCapability == TCASPerfect) {
-				// One perfect, one faulty: 50% chance of crash
-				if rand.Float64() < 0.25 {
-					shouldCrash = true
-				} else {
-					fmt.Fprintf(tcasLog, "%s TCAS: One perfect, one faulty TCAS. Collision narrowly averted between %s and %s.\n\n",
-						time.Now().Format("15:04:05"), plane.Serial, otherPlane.Serial)
-				}
-			} else if plane.TCASCapability == TCASFaulty && otherPlane.TCASCapability == TCASFaulty {
-				if rand.Float64() < 0.5 {
-					shouldCrash = true
-				} else {
-					fmt.Fprintf(tcasLog, "%s TCAS: Two faulty TCAS. Collision narrowly averted between %s and %s.\n\n",
-						time.Now().Format("15:04:05"), plane.Serial, otherPlane.Serial)
+			advisory, sl := evaluateThreat(ownPosition, ownVelocity, intruderPosition, intruderVelocity)
+			switch plane.TCASCapability {
+			case TCASFaulty:
+				// a faulty TCAS only ever raises Traffic Advisories
+				if advisory == AdvisoryRA {
+					advisory = AdvisoryTA
 				}
+			case TCASNone:
+				// a plane without TCAS raises no advisory, it is only seen as traffic by the others
+				advisory = AdvisoryNone
+			}
+			// an open engagement stays open until the planes diverge horizontally, even if the
+			// maneuver already took the intruder out of the advisory thresholds
+			index := plane.openEngagementIndex(intruder.Serial)
+			relativeVelocity := intruderVelocity.subtract(ownVelocity)
+			closing := relativePosition.X*relativeVelocity.X+relativePosition.Y*relativeVelocity.Y < 0
+			if advisory == AdvisoryNone && !collision && (index == -1 || !closing) {
+				continue
 			}
+			threats[intruder.Serial] = true
 
-			if shouldCrash {
-				newTcasEngagement := TCASEngagement{
-					EngagementID:     plane.Serial + util.GenerateSerialNumber(len(plane.TCASEngagementRecords), "e"),
-					FlightID:         planeFlight.FlightID,
+			if index == -1 {
+				flight := plane.FlightLog[len(plane.FlightLog)-1]
+				plane.CurrentTCASEngagements = append(plane.CurrentTCASEngagements, TCASEngagement{
+					EngagementID:     flight.FlightID + util.GenerateSerialNumber(plane.engagementCount(flight.FlightID)+1, "e"),
+					FlightID:         flight.FlightID,
 					PlaneSerial:      plane.Serial,
-					OtherPlaneSerial: otherPlane.Serial,
-					TimeOfEngagement: closestTime,
-					WillCrash:        true,
+					OtherPlaneSerial: intruder.Serial,
+				})
+				index = len(plane.CurrentTCASEngagements) - 1
+			}
+			engagement := &plane.CurrentTCASEngagements[index]
+			engagement.TimeOfEngagement, engagement.MissDistance = predictClosestApproach(now, simState.Frame, *plane, *intruder,
+				ownPosition, ownVelocity, intruderPosition, intruderVelocity)
+
+			if advisory > engagement.Advisory {
+				issueAdvisory(engagement, advisory, sl, *plane, *intruder, positions[i].Z, positions[j].Z, now, r, f, tcasLog)
+				events = append(events, engagement.advisoryEvent(now, positions[i], positions[j]))
+			}
+			// an RA that still does not resolve the conflict once a standard pilot had time to fly it is strengthened
+			if advisory == AdvisoryRA && engagement.Sense != SenseNone && engagement.StrengthenedTime.IsZero() &&
+				!now.Before(engagement.RATime.Add(timeToStrengthen())) {
+				strengthenAdvisory(engagement, *plane, *intruder, now, f, tcasLog)
+				events = append(events, engagement.advisoryEvent(now, positions[i], positions[j]))
+			}
+
+			if collision {
+				engagement.Collided = true
+				engagement.TimeOfEngagement = now.Add(collisionIn)
+				engagement.MissDistance = collisionDistance
+				if intruderIndex := intruder.openEngagementIndex(plane.Serial); intruderIndex != -1 {
+					intruder.CurrentTCASEngagements[intruderIndex].Collided = true
 				}
-				tcasEngagementSlice = append(tcasEngagementSlice, newTcasEngagement)
+				collisions = append(collisions, *engagement)
+				events = append(events, Collision{
+					Time:             now,
+					Plane:            plane.Serial,
+					Intruder:         intruder.Serial,
+					FlightID:         engagement.FlightID,
+					EngagementID:     engagement.EngagementID,
+					Position:         positions[i].add(velocities[i].mulScalar(collisionIn.Seconds())),
+					IntruderPosition: positions[j].add(velocities[j].mulScalar(collisionIn.Seconds())),
+					MissDistance:     collisionDistance,
+				})
+			}
+		}
+
+		// close the engagements against intruders that are no longer a threat or no longer in flight
+		for index := len(plane.CurrentTCASEngagements) - 1; index >= 0; index-- {
+			engagement := plane.CurrentTCASEngagements[index]
+			if threats[engagement.OtherPlaneSerial] {
 				continue
 			}
-			newTcasEngagement := TCASEngagement{
-				EngagementID:     plane.Serial + util.GenerateSerialNumber(len(plane.TCASEngagementRecords), "e"),
-				FlightID:         planeFlight.FlightID,
-				PlaneSerial:      plane.Serial,
-				OtherPlaneSerial: otherPlane.Serial,
-				TimeOfEngagement: closestTime,
-				WillCrash:        false,
+			plane.closeEngagement(index, now)
+			if engagement.Advisory == AdvisoryRA && !engagement.Collided {
+				log.Printf("DISASTER AVERTED! Plane %s and Plane %s SUCCESSFULLY ENGAGED EVASIVE MANEUVER\n\n",
+					engagement.PlaneSerial, engagement.OtherPlaneSerial)
+				fmt.Fprintf(f, "%s DISASTER AVERTED! Plane %s and Plane %s SUCCESSFULLY ENGAGED EVASIVE MANEUVER\n\n",
+					now.Format(LogTimeFormat), engagement.PlaneSerial, engagement.OtherPlaneSerial)
+				events = append(events, CollisionAverted{Time: now, Plane: engagement.PlaneSerial, Intruder: engagement.OtherPlaneSerial,
+					FlightID: engagement.FlightID, EngagementID: engagement.EngagementID, Position: positions[i], MissDistance: engagement.MissDistance})
 			}
-			tcasEngagementSlice = append(tcasEngagementSlice, newTcasEngagement)
+			fmt.Fprintf(tcasLog, "%s TCAS: Plane %s and Plane %s CLEAR OF CONFLICT\n\n",
+				now.Format(LogTimeFormat), engagement.PlaneSerial, engagement.OtherPlaneSerial)
+		}
+	}
+
+	for i := range planes {
+		if point, recorded := planes[i].recordTrack(now, positions[i], velocities[i], simState.TrackInterval); recorded {
+			events = append(events, PositionUpdate{Time: now, Plane: planes[i].Serial,
+				FlightID: planes[i].FlightLog[len(planes[i].FlightLog)-1].FlightID, Position: point.Position,
+				Velocity: point.Velocity, Advisory: point.Advisory, Sense: point.Sense})
 		}
 	}
-	sort.Slice(tcasEngagementSlice, func(i, j int) bool {
-		return tcasEngagementSlice[i].TimeOfEngagement.Before(tcasEngagementSlice[j].TimeOfEngagement)
-	})
-	return tcasEngagementSlice
+	return collisions
+}
+
+// issueAdvisory raises the advisory of an open engagement and logs it. A Resolution Advisory
+// also selects the climb or descend sense own plane must fly, coordinated with the intruder,
+// and draws how the crew responds to it.
+func issueAdvisory(engagement *TCASEngagement, advisory AdvisoryType, sl SensitivityLevel,
+	plane, intruder Plane, ownAltitude, intruderAltitude float64, now time.Time, r *rand.Rand, f, tcasLog *os.File) {
+	engagement.Advisory = advisory
+	engagement.SensitivityLevel = sl.Level
+	if engagement.TATime.IsZero() {
+		engagement.TATime = now
+
+		log.Printf("TCAS TA: TRAFFIC, TRAFFIC! Plane %s and Plane %s are converging (sensitivity level %d)\n\n",
+			plane.Serial, intruder.Serial, sl.Level)
+		fmt.Fprintf(f, "%s TCAS TA: TRAFFIC, TRAFFIC! Plane %s and Plane %s are converging (sensitivity level %d)\n\n",
+			now.Format(LogTimeFormat), plane.Serial, intruder.Serial, sl.Level)
+		fmt.Fprintf(tcasLog, "%s TCAS TA: TRAFFIC, TRAFFIC! Plane %s and Plane %s are converging (sensitivity level %d), closest approach %.2f meters at %s\n\n",
+			now.Format(LogTimeFormat), plane.Serial, intruder.Serial, sl.Level, engagement.MissDistance, engagement.TimeOfEngagement.Format(LogTimeFormat))
+	}
+	if advisory != AdvisoryRA {
+		return
+	}
+	engagement.RATime = now
+	engagement.Sense, engagement.Coordinated = selectSense(plane, intruder, ownAltitude, intruderAltitude)
+	engagement.PilotResponse = plane.Pilot.respond(engagement.Sense, r)
+
+	log.Printf("TCAS RA: CRASH IMMINENT! Plane %s and Plane %s about to collide! %s NOW!!!\n\n",
+		plane.Serial, intruder.Serial, strings.ToUpper(engagement.Sense.String()))
+	fmt.Fprintf(f, "%s TCAS RA: CRASH IMMINENT! Plane %s and Plane %s about to collide! %s NOW!!!\n\n",
+		now.Format(LogTimeFormat), plane.Serial, intruder.Serial, strings.ToUpper(engagement.Sense.String()))
+	fmt.Fprintf(tcasLog, "%s TCAS RA: Plane %s (TCAS: %v) must %s to avoid Plane %s (TCAS: %v), coordinated: %t, crew flying %s, closest approach %.2f meters at %s\n\n",
+		now.Format(LogTimeFormat), plane.Serial, plane.TCASCapability, engagement.Sense, intruder.Serial, intruder.TCASCapability,
+		engagement.Coordinated, engagement.PilotResponse, engagement.MissDistance, engagement.TimeOfEngagement.Format(LogTimeFormat))
+}
+
+// strengthenAdvisory increases the vertical rate commanded by an RA that is not resolving the conflict.
+func strengthenAdvisory(engagement *TCASEngagement, plane, intruder Plane, now time.Time, f, tcasLog *os.File) {
+	engagement.StrengthenedTime = now
+
+	log.Printf("TCAS RA: Plane %s INCREASE %s to avoid Plane %s!\n\n",
+		plane.Serial, strings.ToUpper(engagement.Sense.String()), intruder.Serial)
+	fmt.Fprintf(f, "%s TCAS RA: Plane %s INCREASE %s to avoid Plane %s!\n\n",
+		now.Format(LogTimeFormat), plane.Serial, strings.ToUpper(engagement.Sense.String()), intruder.Serial)
+	fmt.Fprintf(tcasLog, "%s TCAS RA: Plane %s strengthened to INCREASE %s against Plane %s, crew flying %s, closest approach %.2f meters at %s\n\n",
+		now.Format(LogTimeFormat), plane.Serial, strings.ToUpper(engagement.Sense.String()), intruder.Serial,
+		engagement.PilotResponse, engagement.MissDistance, engagement.TimeOfEngagement.Format(LogTimeFormat))
+}
+
+// advisoryEvent returns the TCASAdvisory event describing the engagement's current advisory.
+func (engagement *TCASEngagement) advisoryEvent(now time.Time, position, intruderPosition Coordinate) TCASAdvisory {
+	return TCASAdvisory{
+		Time:                now,
+		Plane:               engagement.PlaneSerial,
+		Intruder:            engagement.OtherPlaneSerial,
+		FlightID:            engagement.FlightID,
+		EngagementID:        engagement.EngagementID,
+		Position:            position,
+		IntruderPosition:    intruderPosition,
+		Advisory:            engagement.Advisory,
+		SensitivityLevel:    engagement.SensitivityLevel,
+		Sense:               engagement.Sense,
+		Coordinated:         engagement.Coordinated,
+		PilotResponse:       engagement.PilotResponse,
+		Strengthened:        !engagement.StrengthenedTime.IsZero(),
+		MissDistance:        engagement.MissDistance,
+		ClosestApproachTime: engagement.TimeOfEngagement,
+	}
+}
+
+// predictClosestApproach returns the time and distance of the closest approach of two planes, never earlier than now.
+// Both planes are followed along the 4D trajectories of their current flights, held at the vertical offset they
+// fly, so climbs, descents and turns ahead are accounted for. In geodetic mode the miss distance is measured
+// on a map local to the closest approach. Planes that are no longer airborne together on their plans are
+// extrapolated along their current velocities instead.
+func predictClosestApproach(now time.Time, frame *ENUFrame, own, intruder Plane,
+	ownPosition, ownVelocity, intruderPosition, intruderVelocity Coordinate) (time.Time, float64) {
+	if ca, ok := own.plannedFlight().ClosestApproach(intruder.plannedFlight(), now); ok {
+		if frame != nil {
+			ca.Position1, _, ca.Position2, _ = frame.localGeometry(ca.Position1, Coordinate{}, ca.Position2, Coordinate{})
+		}
+		return ca.Time, ca.MissDistance()
+	}
+	in, distance := closestApproachWithin(time.Duration(math.MaxInt64), ownPosition, ownVelocity, intruderPosition, intruderVelocity)
+	return now.Add(in), distance
+}
+
+// plannedFlight returns the plane's current flight with its trajectory displaced by the plane's vertical offset.
+func (plane Plane) plannedFlight() Flight {
+	flight := plane.FlightLog[len(plane.FlightLog)-1]
+	trajectory := flight.trajectory()
+	flight.Trajectory = make([]Waypoint, len(trajectory))
+	for i, waypoint := range trajectory {
+		waypoint.Position.Z += plane.VerticalOffset
+		flight.Trajectory[i] = waypoint
+	}
+	return flight
+}
+
+// openEngagementIndex returns the index in CurrentTCASEngagements of the plane's open engagement
+// against the given intruder, or -1 if there is none.
+func (plane Plane) openEngagementIndex(intruderSerial string) int {
+	for i, engagement := range plane.CurrentTCASEngagements {
+		if engagement.OtherPlaneSerial == intruderSerial {
+			return i
+		}
+	}
+	return -1
+}
+
+// engagementCount returns how many engagements, open or closed, the plane had during the given flight.
+func (plane Plane) engagementCount(flightID string) int {
+	count := 0
+	for _, engagement := range plane.TCASEngagementRecords {
+		if engagement.FlightID == flightID {
+			count++
+		}
+	}
+	for _, engagement := range plane.CurrentTCASEngagements {
+		if engagement.FlightID == flightID {
+			count++
+		}
+	}
+	return count
+}
+
+// recordTrack samples the plane's position into the track of its current flight once every interval,
+// TrackInterval when it is not positive, and whenever the advisory active on board changes.
+// It returns the sample and whether it was recorded.
+func (plane *Plane) recordTrack(now time.Time, position, velocity Coordinate, interval time.Duration) (TrackPoint, bool) {
+	if interval <= 0 {
+		interval = TrackInterval
+	}
+	point := TrackPoint{Time: now, Position: position, Velocity: velocity}
+	for _, engagement := range plane.CurrentTCASEngagements {
+		if engagement.Advisory > point.Advisory {
+			point.Advisory = engagement.Advisory
+		}
+		if engagement.Advisory == AdvisoryRA && point.Sense == SenseNone {
+			point.Sense = engagement.Sense
+		}
+	}
+
+	flight := &plane.FlightLog[len(plane.FlightLog)-1]
+	if len(flight.Track) > 0 {
+		last := flight.Track[len(flight.Track)-1]
+		if now.Sub(last.Time) < interval && last.Advisory == point.Advisory && last.Sense == point.Sense {
+			return point, false
+		}
+	}
+	flight.Track = append(flight.Track, point)
+	return point, true
+}
+
+// closeEngagement closes the open engagement at the given index and moves it to the plane's records.
+func (plane *Plane) closeEngagement(index int, now time.Time) {
+	engagement := plane.CurrentTCASEngagements[index]
+	engagement.ClosedTime = now
+	plane.TCASEngagementRecords = append(plane.TCASEngagementRecords, engagement)
+	plane.CurrentTCASEngagements = append(plane.CurrentTCASEngagements[:index], plane.CurrentTCASEngagements[index+1:]...)
 }
@@ -0,0 +1,150 @@
+package aviation
+
+import (
+	"math"
+	"time"
+)
+
+// Most functions here implement the TCAS II threat detection logic:
+// range tau and vertical tau tests against thresholds selected by altitude band.
+
+// AdvisoryType is the level of alert TCAS issues for an intruder.
+type AdvisoryType int
+
+const (
+	AdvisoryNone AdvisoryType = iota // 0, intruder is not a threat
+	AdvisoryTA                       // Traffic Advisory, "TRAFFIC, TRAFFIC"
+	AdvisoryRA                       // Resolution Advisory, a vertical maneuver is required
+)
+
+// String returns the usual abbreviation of the advisory.
+func (a AdvisoryType) String() string {
+	switch a {
+	case AdvisoryTA:
+		return "TA"
+	case AdvisoryRA:
+		return "RA"
+	default:
+		return "none"
+	}
+}
+
+// MarshalText writes the advisory as its abbreviation, e.g. in the JSON event log.
+func (a AdvisoryType) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// TCASCycleInterval is how often TCAS re-evaluates the traffic around a plane.
+const TCASCycleInterval = 1 * time.Second
+
+// Unit conversions between the simulation (meters) and the TCAS thresholds (feet and nautical miles).
+const (
+	metersPerFoot         = 0.3048
+	metersPerNauticalMile = 1852.0
+)
+
+// SensitivityLevel holds the TCAS II alerting thresholds used in an altitude band.
+// Tau values are in seconds, DMOD in nautical miles, ZTHR and ALIM in feet.
+type SensitivityLevel struct {
+	Level  int
+	TATau  float64
+	TADMOD float64
+	TAZTHR float64
+	RATau  float64 // 0 when Resolution Advisories are inhibited
+	RADMOD float64
+	RAZTHR float64
+	ALIM   float64
+}
+
+// sensitivityLevels is the TCAS II v7.1 threshold table, indexed by the upper bound of each altitude band in feet.
+var sensitivityLevels = []struct {
+	maxAltitudeFeet float64
+	level           SensitivityLevel
+}{
+	{1000, SensitivityLevel{Level: 2, TATau: 20, TADMOD: 0.30, TAZTHR: 850}},
+	{2350, SensitivityLevel{Level: 3, TATau: 25, TADMOD: 0.33, TAZTHR: 850, RATau: 15, RADMOD: 0.20, RAZTHR: 600, ALIM: 300}},
+	{5000, SensitivityLevel{Level: 4, TATau: 30, TADMOD: 0.48, TAZTHR: 850, RATau: 20, RADMOD: 0.35, RAZTHR: 600, ALIM: 300}},
+	{10000, SensitivityLevel{Level: 5, TATau: 40, TADMOD: 0.75, TAZTHR: 850, RATau: 25, RADMOD: 0.55, RAZTHR: 600, ALIM: 350}},
+	{20000, SensitivityLevel{Level: 6, TATau: 45, TADMOD: 1.00, TAZTHR: 850, RATau: 30, RADMOD: 0.80, RAZTHR: 600, ALIM: 400}},
+	{42000, SensitivityLevel{Level: 7, TATau: 48, TADMOD: 1.30, TAZTHR: 850, RATau: 35, RADMOD: 1.10, RAZTHR: 700, ALIM: 600}},
+	{math.Inf(1), SensitivityLevel{Level: 7, TATau: 48, TADMOD: 1.30, TAZTHR: 1200, RATau: 35, RADMOD: 1.10, RAZTHR: 800, ALIM: 700}},
+}
+
+// SelectSensitivityLevel returns the thresholds TCAS uses at the given own altitude (meters).
+// Airports sit at Z=0, so the altitude is also the height above ground used for the lowest bands.
+func SelectSensitivityLevel(altitude float64) SensitivityLevel {
+	altitudeFeet := altitude / metersPerFoot
+	for _, band := range sensitivityLevels {
+		if altitudeFeet < band.maxAltitudeFeet {
+			return band.level
+		}
+	}
+	return sensitivityLevels[len(sensitivityLevels)-1].level
+}
+
+// evaluateThreat runs the TCAS range and altitude tests of own plane against an intruder,
+// given both positions (meters) and velocities (meters per second), and returns the advisory it raises.
+//
+// A Resolution Advisory additionally requires the vertical separation projected at the closest point of approach
+// to be below ALIM; otherwise no maneuver is needed and the intruder stays at the Traffic Advisory level.
+func evaluateThreat(ownPosition, ownVelocity, intruderPosition, intruderVelocity Coordinate) (AdvisoryType, SensitivityLevel) {
+	sl := SelectSensitivityLevel(ownPosition.Z)
+
+	relativePosition := intruderPosition.subtract(ownPosition)
+	relativeVelocity := intruderVelocity.subtract(ownVelocity)
+
+	// horizontal range and range rate
+	r := math.Hypot(relativePosition.X, relativePosition.Y)
+	rDot := 0.0
+	if r > 0 {
+		rDot = (relativePosition.X*relativeVelocity.X + relativePosition.Y*relativeVelocity.Y) / r
+	}
+
+	// relative altitude and vertical closure, own minus intruder
+	a := -relativePosition.Z
+	aDot := -relativeVelocity.Z
+
+	if !rangeTest(r, rDot, sl.TATau, sl.TADMOD) || !altitudeTest(a, aDot, sl.TATau, sl.TAZTHR) {
+		return AdvisoryNone, sl
+	}
+	if sl.RATau == 0 || !rangeTest(r, rDot, sl.RATau, sl.RADMOD) || !altitudeTest(a, aDot, sl.RATau, sl.RAZTHR) {
+		return AdvisoryTA, sl
+	}
+
+	tauRange := 0.0
+	if rDot < 0 {
+		tauRange = -r / rDot
+	}
+	projectedSeparation := math.Abs(a+aDot*tauRange) / metersPerFoot
+	if projectedSeparation >= sl.ALIM {
+		return AdvisoryTA, sl
+	}
+	return AdvisoryRA, sl
+}
+
+// rangeTest reports whether the intruder is inside DMOD (nautical miles), or closing
+// with a modified range tau at or below the tau threshold (seconds).
+func rangeTest(r, rDot, tau, dmod float64) bool {
+	dmodMeters := dmod * metersPerNauticalMile
+	if r <= dmodMeters {
+		return true
+	}
+	if rDot >= 0 {
+		return false
+	}
+	modifiedTau := (dmodMeters*dmodMeters - r*r) / (r * rDot)
+	return modifiedTau <= tau
+}
+
+// altitudeTest reports whether the intruder is within ZTHR (feet) of own altitude,
+// or converging vertically with a vertical tau at or below the tau threshold (seconds).
+func altitudeTest(a, aDot, tau, zthr float64) bool {
+	if math.Abs(a) <= zthr*metersPerFoot {
+		return true
+	}
+	if a*aDot >= 0 {
+		// diverging or level with each other
+		return false
+	}
+	return -a/aDot <= tau
+}
@@ -0,0 +1,140 @@
+package aviation
+
+import (
+	"math"
+	"time"
+)
+
+// Most functions here implement the TCAS II resolution logic: selecting a climb or descend sense,
+// coordinating it with the intruder over the Mode S link and flying the resulting vertical maneuver.
+
+// RASense is the vertical direction a Resolution Advisory commands.
+type RASense int
+
+const (
+	SenseNone    RASense = iota // 0, no maneuver commanded
+	SenseClimb                  // "CLIMB, CLIMB"
+	SenseDescend                // "DESCEND, DESCEND"
+)
+
+// String returns the sense as announced in the cockpit.
+func (s RASense) String() string {
+	switch s {
+	case SenseClimb:
+		return "climb"
+	case SenseDescend:
+		return "descend"
+	default:
+		return "none"
+	}
+}
+
+// MarshalText writes the sense as announced in the cockpit, e.g. in the JSON event log.
+func (s RASense) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// opposite returns the complementary sense the other aircraft of a coordinated encounter must fly.
+func (s RASense) opposite() RASense {
+	switch s {
+	case SenseClimb:
+		return SenseDescend
+	case SenseDescend:
+		return SenseClimb
+	default:
+		return SenseNone
+	}
+}
+
+// selectSense chooses the Resolution Advisory sense of own plane against an intruder.
+//
+// The planes coordinate over a simulated Mode S air-to-air link: if the intruder's TCAS already
+// announced a sense against own plane, own plane flies the opposite one. Otherwise the sense that
+// keeps own plane on its side of the intruder is chosen, and when both are level the plane with
+// the lower Mode S address (its serial) climbs. A faulty TCAS never issues an RA, so it never
+// announces a sense and the other plane resolves the encounter alone.
+func selectSense(plane, intruder Plane, ownAltitude, intruderAltitude float64) (sense RASense, coordinated bool) {
+	if index := intruder.openEngagementIndex(plane.Serial); index != -1 {
+		if intruderSense := intruder.CurrentTCASEngagements[index].Sense; intruderSense != SenseNone {
+			return intruderSense.opposite(), true
+		}
+	}
+	switch {
+	case ownAltitude > intruderAltitude:
+		return SenseClimb, false
+	case ownAltitude < intruderAltitude:
+		return SenseDescend, false
+	case plane.Serial < intruder.Serial:
+		return SenseClimb, false
+	default:
+		return SenseDescend, false
+	}
+}
+
+// commandedVerticalRate returns the vertical rate the crew is flying towards at the given time and
+// the acceleration they use to reach it. The crew follows its response to an RA once its pilot model's
+// response delay has passed, and a strengthening RA after the shorter strengthening delay; with no RA
+// to follow the plane returns to its planned altitude at no more than the initial RA vertical rate.
+func (plane Plane) commandedVerticalRate(now time.Time) (rate, acceleration float64) {
+	pilot := plane.Pilot
+	for _, engagement := range plane.CurrentTCASEngagements {
+		if engagement.PilotResponse == SenseNone || now.Before(engagement.RATime.Add(pilot.InitialResponseDelay)) {
+			continue
+		}
+		rate, acceleration = pilot.TargetVerticalRate, pilot.InitialAcceleration
+		if !engagement.StrengthenedTime.IsZero() && !now.Before(engagement.StrengthenedTime.Add(pilot.StrengtheningResponseDelay)) {
+			rate, acceleration = pilot.StrengthenedVerticalRate, pilot.StrengtheningAcceleration
+		}
+		if engagement.PilotResponse == SenseDescend {
+			rate = -rate
+		}
+		return rate, acceleration
+	}
+	return clamp(-plane.VerticalOffset, -pilot.TargetVerticalRate, pilot.TargetVerticalRate), pilot.InitialAcceleration
+}
+
+// flyVerticalManeuver advances the plane's vertical state by elapsed, accelerating towards
+// the commanded vertical rate at no more than the commanded acceleration.
+func (plane *Plane) flyVerticalManeuver(now time.Time, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return
+	}
+	target, acceleration := plane.commandedVerticalRate(now)
+	change := clamp(target-plane.VerticalRate, -acceleration*seconds, acceleration*seconds)
+	newRate := plane.VerticalRate + change
+	plane.VerticalOffset += (plane.VerticalRate + newRate) / 2 * seconds
+	plane.VerticalRate = newRate
+	if math.Abs(plane.VerticalOffset) < Epsilon && plane.VerticalRate == 0 {
+		plane.VerticalOffset = 0
+	}
+}
+
+// Position returns the plane's actual position at time t: its planned position along the
+// current flight, displaced vertically by any maneuver it flew.
+func (plane Plane) Position(t time.Time) Coordinate {
+	position := plane.FlightLog[len(plane.FlightLog)-1].PositionAt(t)
+	position.Z += plane.VerticalOffset
+	return position
+}
+
+// VelocityAt returns the plane's actual velocity at time t: its planned velocity plus its vertical rate.
+func (plane Plane) VelocityAt(t time.Time) Coordinate {
+	velocity := plane.FlightLog[len(plane.FlightLog)-1].VelocityAt(t)
+	velocity.Z += plane.VerticalRate
+	return velocity
+}
+
+// closestApproachWithin returns the smallest distance between two planes flying straight
+// from the given positions and velocities during the next window, and when it happens.
+func closestApproachWithin(window time.Duration, ownPosition, ownVelocity, intruderPosition, intruderVelocity Coordinate) (time.Duration, float64) {
+	relativePosition := intruderPosition.subtract(ownPosition)
+	relativeVelocity := intruderVelocity.subtract(ownVelocity)
+
+	seconds := 0.0
+	if speedSquared := relativeVelocity.dot(relativeVelocity); speedSquared > 0 {
+		seconds = clamp(-relativePosition.dot(relativeVelocity)/speedSquared, 0, window.Seconds())
+	}
+	closest := relativePosition.add(relativeVelocity.mulScalar(seconds))
+	return time.Duration(math.Round(seconds * float64(time.Second))), math.Sqrt(closest.dot(closest))
+}
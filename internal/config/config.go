@@ -1,8 +1,28 @@
 package config
 
+import "time"
+
 // Config holds the simulation's configuration parameters.
 type Config struct {
 	NoOfAirplanes      int
 	IsRunning          bool
 	DifferentAltitudes bool
+	Seed               int64              // master seed for all randomness, 0 picks a random seed
+	FaultyTCASRatio    float64            // share of the fleet fitted with a faulty TCAS
+	CollisionThreshold float64            // conflict distance threshold, 0 uses the default
+	FleetMix           map[string]float64 // relative share of each aircraft type designator, empty uses the default mix
+
+	PilotNonCompliance    float64 // probability that a crew ignores a Resolution Advisory
+	PilotOppositeResponse float64 // probability that a crew flies the opposite of the commanded sense
+
+	PilotResponseDelay time.Duration // delay before a crew starts an initial RA maneuver, 0 uses the standard 5 s
+	PilotAcceleration  float64       // vertical acceleration of an initial RA maneuver in g, 0 uses the standard 0.25 g
+	PilotVerticalRate  float64       // vertical rate commanded by an initial RA in ft/min, 0 uses the standard 1500 ft/min
+	PilotVariation     float64       // spread of every crew's delay, acceleration and rate around the configured ones (0 to 1)
+
+	Geodetic        bool    // places the map on the Earth and flies great-circle routes
+	OriginLatitude  float64 // latitude of the map origin in geodetic mode, in degrees
+	OriginLongitude float64 // longitude of the map origin in geodetic mode, in degrees
+
+	TrackInterval time.Duration // how often planes in flight record their position, 0 uses the default
 }
@@ -2,16 +2,26 @@ package util
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 )
 
 // CleanInput processes a string, returning a slice of lowercase words with leading/trailing spaces and empty strings removed.
 func CleanInput(text string) []string {
+	words := SplitInput(text)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return words
+}
+
+// SplitInput processes a string like CleanInput but keeps the case of the words, for arguments such as file paths.
+func SplitInput(text string) []string {
 	words := []string{}
 	sText := strings.Split(strings.TrimSpace(text), " ")
 	for _, word := range sText {
 		if len(word) != 0 {
-			words = append(words, strings.ToLower(word))
+			words = append(words, word)
 		}
 	}
 	return words
@@ -19,15 +29,25 @@ func CleanInput(text string) []string {
 
 // ResetLog removes all logs in logs/
 func ResetLog() {
+	ResetLogDir("logs")
+}
+
+// ResetLogDir removes all simulation logs in the given directory.
+func ResetLogDir(dir string) {
 	filesToDelete := []string{
-		"logs/airportDetails.txt",
-		"logs/airplaneDetails.txt",
-		"logs/flightDetails.txt",
-		"logs/console_log.txt",
-		"logs/tcasLog.txt",
+		"airportDetails.txt",
+		"airplaneDetails.txt",
+		"flightDetails.txt",
+		"console_log.txt",
+		"tcasLog.txt",
+		"events.jsonl",
+		"report.txt",
+		"report.md",
+		"report.html",
 	}
 
-	for _, filePath := range filesToDelete {
+	for _, fileName := range filesToDelete {
+		filePath := filepath.Join(dir, fileName)
 		_, err := os.Stat(filePath)
 		if os.IsNotExist(err) {
 			continue
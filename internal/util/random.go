@@ -0,0 +1,21 @@
+package util
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// NewSeed returns a fresh master seed for runs where the user did not provide one.
+func NewSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// NewRand returns a random source derived from the master seed for the named stream,
+// so each part of the simulation draws from its own reproducible sequence
+// regardless of the order in which goroutines happen to run.
+func NewRand(seed int64, stream string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(stream))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
@@ -2,15 +2,59 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/josephus-git/TCAS-simulation/internal/aviation"
 	"github.com/josephus-git/TCAS-simulation/internal/config"
 	"github.com/josephus-git/TCAS-simulation/internal/util"
 )
 
+// seedFlag is the master seed given on the command line, 0 picks a random seed.
+var seedFlag = flag.Int64("seed", 0, "master seed that makes the simulation reproducible at any speed (0 picks a random seed)")
+
+// scenarioFlag is the scenario file the world is loaded from, empty builds a random world.
+var scenarioFlag = flag.String("scenario", "", "scenario file declaring airports, planes and a timetable of flights")
+
+// originFlag places the map on the Earth at the given latitude,longitude, empty keeps a flat map.
+var originFlag = flag.String("origin", "", "latitude,longitude of the map origin: places the map on the Earth and flies great-circle routes")
+
+// trackIntervalFlag is how often planes in flight record their track.
+var trackIntervalFlag = flag.Duration("track-interval", aviation.TrackInterval, "how often planes in flight record their track, e.g. 1s or 30s")
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runHeadless(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		os.Exit(exitOK)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "campaign" {
+		if err := runCampaign(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		os.Exit(exitOK)
+	}
+
+	flag.Usage = func() { usage(os.Stderr) }
+	flag.Parse()
+	if *originFlag != "" {
+		if _, err := aviation.ParseGeoCoordinate(*originFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "--origin: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+	if *trackIntervalFlag <= 0 {
+		fmt.Fprintln(os.Stderr, "--track-interval must be positive")
+		os.Exit(exitUsage)
+	}
 	util.ResetLog()
 	start()
 }
@@ -19,28 +63,44 @@ func main() {
 func start() {
 	scanner := bufio.NewScanner(os.Stdin)
 	initialize := &config.Config{
-		IsRunning: true,
+		IsRunning:       true,
+		Seed:            *seedFlag,
+		FaultyTCASRatio: aviation.DefaultFaultyTCASRatio,
+		TrackInterval:   *trackIntervalFlag,
 	}
-	simState := &aviation.SimulationState{}
+	if *originFlag != "" {
+		origin, _ := aviation.ParseGeoCoordinate(*originFlag) // validated when the flags were parsed
+		initialize.Geodetic = true
+		initialize.OriginLatitude, initialize.OriginLongitude = origin.Latitude, origin.Longitude
+	}
+	simState := &aviation.SimulationState{Events: aviation.NewEventBus()}
 
-	aviation.GetNumberOfPlanes(initialize)
-	aviation.InitializeAirports(initialize, simState)
+	if *scenarioFlag != "" {
+		scenario, err := aviation.LoadScenario(*scenarioFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+		aviation.InitializeScenario(initialize, simState, scenario)
+	} else {
+		aviation.GetNumberOfPlanes(initialize)
+		aviation.InitializeAirports(initialize, simState)
+	}
 
 	for i := 0; initialize.IsRunning; i++ {
 		fmt.Print("TCAS-simulator > ")
 		scanner.Scan()
-		input := util.CleanInput(scanner.Text())
-		argument2 := ""
-		if len(input) > 1 {
-			argument2 = input[1]
-		}
+		// any input closes the radar, so that command output is not drawn over
+		simState.StopRadar()
+		// arguments keep their case, file paths may need it
+		input := util.SplitInput(scanner.Text())
 
 		if len(input) == 0 {
 			fmt.Println("")
 			continue
 		}
 
-		cmd, ok := getCommand(initialize, simState, argument2)[input[0]]
+		cmd, ok := getCommand(initialize, simState, input[1:])[strings.ToLower(input[0])]
 		if !ok {
 			fmt.Println("Unknown command, type <help> for usage")
 			continue
@@ -0,0 +1,800 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation/internal/config"
+)
+
+// TestMain silences the standard logger the simulations write their progress to.
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestServer returns an API server writing the logs of its simulations to a temporary directory.
+func newTestServer(t *testing.T) (*apiServer, *httptest.Server) {
+	t.Helper()
+	server := &apiServer{outDir: t.TempDir(), simulations: map[string]*apiSimulation{}}
+	httpServer := httptest.NewServer(server.routes())
+	t.Cleanup(httpServer.Close)
+	return server, httpServer
+}
+
+// postJSON sends a POST request with the given JSON body and decodes the JSON answer into answer.
+func postJSON(t *testing.T, url, body string, answer any) int {
+	t.Helper()
+	response, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if answer != nil {
+		if err := json.NewDecoder(response.Body).Decode(answer); err != nil {
+			t.Fatalf("POST %s: invalid JSON answer: %v", url, err)
+		}
+	}
+	return response.StatusCode
+}
+
+func TestServeStartStop(t *testing.T) {
+	server, httpServer := newTestServer(t)
+
+	var created simulationView
+	status := postJSON(t, httpServer.URL+"/simulations", `{"planes": 6, "duration": "1h", "speed": "1x", "seed": 7}`, &created)
+	if status != http.StatusCreated || created.ID != "1" || created.Status != "running" || created.Seed != 7 {
+		t.Fatalf("POST /simulations answered %d with %+v, want 201 and simulation 1 running with seed 7", status, created)
+	}
+
+	// the run may not have begun yet, in which case the stop is to be retried
+	var stopped simulationView
+	for range 100 {
+		status = postJSON(t, httpServer.URL+"/simulations/1/stop", "", &stopped)
+		if status != http.StatusConflict {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status != http.StatusOK || stopped.Status != "stopped" {
+		t.Fatalf("stop answered %d with %+v, want 200 and a stopped simulation", status, stopped)
+	}
+	if status = postJSON(t, httpServer.URL+"/simulations/1/stop", "", nil); status != http.StatusConflict {
+		t.Errorf("second stop answered %d, want 409", status)
+	}
+	if status = postJSON(t, httpServer.URL+"/simulations", `{"planes": 1}`, nil); status != http.StatusBadRequest {
+		t.Errorf("POST /simulations with 1 plane answered %d, want 400", status)
+	}
+
+	response, err := http.Get(httpServer.URL + "/simulations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var simulations []simulationView
+	err = json.NewDecoder(response.Body).Decode(&simulations)
+	response.Body.Close()
+	if err != nil || len(simulations) != 1 || simulations[0].Status != "stopped" {
+		t.Errorf("GET /simulations returned %+v (%v), want the stopped simulation", simulations, err)
+	}
+	for _, name := range []string{"console_log.txt", "events.jsonl", "report.txt"} {
+		if _, err := os.Stat(filepath.Join(server.outDir, "simulation-1", name)); err != nil {
+			t.Errorf("simulation log %s: %v", name, err)
+		}
+	}
+}
+
+// TestServeScenarios checks that simulations are only started from files of the scenario directory, and that
+// the answers about the others tell nothing of the files on disk.
+func TestServeScenarios(t *testing.T) {
+	server, httpServer := newTestServer(t)
+	server.scenarioDir = t.TempDir()
+	scenario, err := os.ReadFile(filepath.Join("scenarios", "head_on_same_level.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"head_on_same_level.json": scenario, "broken.json": []byte("{")} {
+		if err := os.WriteFile(filepath.Join(server.scenarioDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		scenario string
+		wantErr  string
+	}{
+		// the scenario is loaded, the run then being refused for its duration
+		{name: "scenario of the directory", scenario: "head_on_same_level.json", wantErr: "duration must be"},
+		{name: "absolute path", scenario: filepath.Join(t.TempDir(), "scenario.json"), wantErr: "invalid scenario"},
+		{name: "parent directory", scenario: "../go.mod", wantErr: "invalid scenario"},
+		{name: "missing file", scenario: "missing.json", wantErr: `scenario "missing.json" cannot be loaded`},
+		{name: "invalid file", scenario: "broken.json", wantErr: `scenario "broken.json" cannot be loaded`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"scenario": test.scenario, "duration": "0s"})
+			var answer map[string]string
+			status := postJSON(t, httpServer.URL+"/simulations", string(body), &answer)
+			if status != http.StatusBadRequest || !strings.HasPrefix(answer["error"], test.wantErr) {
+				t.Errorf("got %d %q, want 400 %q", status, answer["error"], test.wantErr)
+			}
+			if strings.Contains(answer["error"], server.scenarioDir) || strings.Contains(answer["error"], "no such file") {
+				t.Errorf("the answer %q tells which files exist", answer["error"])
+			}
+		})
+	}
+
+	server.scenarioDir = ""
+	var answer map[string]string
+	if status := postJSON(t, httpServer.URL+"/simulations", `{"scenario": "head_on_same_level.json"}`, &answer); status != http.StatusBadRequest {
+		t.Errorf("a server without a scenario directory answered %d %q, want 400", status, answer["error"])
+	}
+}
+
+// TestRunSameSeedSameEvents checks that two runs of the same seed at the same speed log the very same events,
+// at max speed as when simulated time is paced to wall-clock time.
+func TestRunSameSeedSameEvents(t *testing.T) {
+	tests := []struct {
+		speed    string
+		duration string
+		wantCode int
+	}{
+		{speed: "max", duration: "2h", wantCode: exitCollision},
+		{speed: "1000x", duration: "10m", wantCode: exitOK},
+	}
+	for _, test := range tests {
+		t.Run(test.speed, func(t *testing.T) {
+			var events [2][]string
+			for i := range events {
+				out := filepath.Join(t.TempDir(), "results")
+				code := runHeadless([]string{"--planes", "40", "--altitudes", "varied", "--duration", test.duration, "--seed", "11",
+					"--crew-variation", "0.2", "--speed", test.speed, "--out", out})
+				if code != test.wantCode {
+					t.Errorf("got exit code %d, want %d", code, test.wantCode)
+				}
+				data, err := os.ReadFile(filepath.Join(out, "events.jsonl"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				events[i] = strings.Split(string(data), "\n")
+			}
+
+			if len(events[0]) < 100 {
+				t.Fatalf("the run logged %d events, want a busy run", len(events[0]))
+			}
+			for i := range min(len(events[0]), len(events[1])) {
+				if events[0][i] != events[1][i] {
+					t.Fatalf("the runs of the same seed differ at event %d:\n%s\n%s", i+1, events[0][i], events[1][i])
+				}
+			}
+			if len(events[0]) != len(events[1]) {
+				t.Errorf("the runs of the same seed logged %d and %d events", len(events[0]), len(events[1]))
+			}
+		})
+	}
+}
+
+// TestRunOptionsPrepare checks the validation of the options of a run and the configuration built from them.
+func TestRunOptionsPrepare(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(opts *runOptions)
+		wantErr   string
+		wantSpeed float64
+		check     func(t *testing.T, cfg *config.Config, scenario *aviation.Scenario)
+	}{
+		{
+			name:      "defaults",
+			change:    func(opts *runOptions) {},
+			wantSpeed: aviation.MaxSpeed,
+			check: func(t *testing.T, cfg *config.Config, scenario *aviation.Scenario) {
+				if cfg.NoOfAirplanes != 10 || cfg.DifferentAltitudes || cfg.Geodetic || cfg.FleetMix != nil || scenario != nil {
+					t.Errorf("got %+v and scenario %v", cfg, scenario)
+				}
+			},
+		},
+		{
+			name: "varied altitudes at 10x on the Earth",
+			change: func(opts *runOptions) {
+				opts.Altitudes, opts.Speed, opts.Origin, opts.Fleet = "Varied", "10x", "51.47,-0.45", "A320=1"
+			},
+			wantSpeed: 10,
+			check: func(t *testing.T, cfg *config.Config, scenario *aviation.Scenario) {
+				if !cfg.DifferentAltitudes || !cfg.Geodetic || cfg.OriginLatitude != 51.47 || cfg.OriginLongitude != -0.45 || cfg.FleetMix["A320"] != 1 {
+					t.Errorf("got %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "scenario in place of planes",
+			change: func(opts *runOptions) {
+				opts.Planes, opts.Scenario = 0, filepath.Join("scenarios", "head_on_same_level.json")
+			},
+			wantSpeed: aviation.MaxSpeed,
+			check: func(t *testing.T, cfg *config.Config, scenario *aviation.Scenario) {
+				if scenario == nil {
+					t.Error("got no scenario")
+				}
+			},
+		},
+		{name: "one plane", change: func(opts *runOptions) { opts.Planes = 1 }, wantErr: "planes must be"},
+		{name: "missing scenario", change: func(opts *runOptions) { opts.Scenario = "missing.json" }, wantErr: "missing.json"},
+		{name: "no duration", change: func(opts *runOptions) { opts.Duration = 0 }, wantErr: "duration must be"},
+		{name: "unknown altitudes", change: func(opts *runOptions) { opts.Altitudes = "high" }, wantErr: "altitudes must be"},
+		{name: "faulty above 1", change: func(opts *runOptions) { opts.Faulty = 1.5 }, wantErr: "faulty must be"},
+		{name: "negative faulty", change: func(opts *runOptions) { opts.Faulty = -0.1 }, wantErr: "faulty must be"},
+		{name: "no threshold", change: func(opts *runOptions) { opts.Threshold = 0 }, wantErr: "threshold must be"},
+		{name: "negative threshold", change: func(opts *runOptions) { opts.Threshold = -3 }, wantErr: "threshold must be"},
+		{name: "crew responses above 1", change: func(opts *runOptions) { opts.NonCompliance, opts.Opposite = 0.6, 0.5 }, wantErr: "noncompliance and opposite"},
+		{name: "no response delay", change: func(opts *runOptions) { opts.ResponseDelay = 0 }, wantErr: "response-delay"},
+		{name: "no vertical rate", change: func(opts *runOptions) { opts.VerticalRate = 0 }, wantErr: "vertical-rate"},
+		{name: "crew variation of 1", change: func(opts *runOptions) { opts.CrewVariation = 1 }, wantErr: "crew-variation"},
+		{name: "unknown speed", change: func(opts *runOptions) { opts.Speed = "fast" }, wantErr: "invalid speed"},
+		{name: "unknown aircraft type", change: func(opts *runOptions) { opts.Fleet = "XYZ=1" }, wantErr: "fleet: unknown aircraft type"},
+		{name: "no track interval", change: func(opts *runOptions) { opts.TrackInterval = 0 }, wantErr: "track-interval"},
+		{name: "invalid origin", change: func(opts *runOptions) { opts.Origin = "north" }, wantErr: "origin: invalid position"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := defaultRunOptions()
+			opts.Planes = 10
+			test.change(&opts)
+			cfg, scenario, speed, err := opts.prepare()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if speed != test.wantSpeed {
+				t.Errorf("got speed %v, want %v", speed, test.wantSpeed)
+			}
+			test.check(t, cfg, scenario)
+		})
+	}
+}
+
+// TestParseRunFlags checks that the flags of the run command end up in the options of the run.
+func TestParseRunFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    func(opts *runOptions)
+		wantOut string
+		wantErr bool
+	}{
+		{name: "defaults", want: func(opts *runOptions) {}, wantOut: "results"},
+		{
+			name: "every flag",
+			args: []string{"--planes", "200", "--scenario", "s.json", "--altitudes", "varied", "--duration", "30m", "--seed", "7",
+				"--speed", "10x", "--faulty", "0.5", "--threshold", "8", "--noncompliance", "0.1", "--opposite", "0.2",
+				"--response-delay", "3s", "--acceleration", "0.35", "--vertical-rate", "2500", "--crew-variation", "0.2",
+				"--fleet", "C172=1", "--origin", "51.47,-0.45", "--track-interval", "30s", "--out", "elsewhere"},
+			want: func(opts *runOptions) {
+				*opts = runOptions{Planes: 200, Scenario: "s.json", Altitudes: "varied", Duration: 30 * time.Minute, Seed: 7,
+					Speed: "10x", Faulty: 0.5, Threshold: 8, NonCompliance: 0.1, Opposite: 0.2,
+					ResponseDelay: 3 * time.Second, Acceleration: 0.35, VerticalRate: 2500, CrewVariation: 0.2,
+					Fleet: "C172=1", Origin: "51.47,-0.45", TrackInterval: 30 * time.Second}
+			},
+			wantOut: "elsewhere",
+		},
+		{name: "single dash", args: []string{"-planes=3", "-seed=-4"}, want: func(opts *runOptions) { opts.Planes, opts.Seed = 3, -4 }, wantOut: "results"},
+		{name: "unknown flag", args: []string{"--plane", "3"}, wantErr: true},
+		{name: "planes not a number", args: []string{"--planes", "many"}, wantErr: true},
+		{name: "duration without unit", args: []string{"--duration", "30"}, wantErr: true},
+		{name: "missing value", args: []string{"--seed"}, wantErr: true},
+	}
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() { os.Stderr.Close(); os.Stderr = stderr }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, outDir, err := parseRunFlags(test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got options %+v, want an error", opts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := defaultRunOptions()
+			test.want(&want)
+			if opts != want || outDir != test.wantOut {
+				t.Errorf("got %+v and out %q, want %+v and out %q", opts, outDir, want, test.wantOut)
+			}
+		})
+	}
+}
+
+// TestRunHeadless checks the exit codes of the run command and the artifacts a run writes to its output directory.
+func TestRunHeadless(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "results")
+	if code := runHeadless([]string{"--planes", "6", "--duration", "20m", "--seed", "7", "--speed", "max", "--out", out}); code != exitOK {
+		t.Fatalf("got exit code %d, want %d", code, exitOK)
+	}
+	for _, name := range []string{"console_log.txt", "tcasLog.txt", "events.jsonl", "report.txt", "report.md", "report.html",
+		"airportDetails.txt", "airplaneDetails.txt", "flightDetails.txt"} {
+		if info, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("artifact %s: %v", name, err)
+		} else if info.Size() == 0 {
+			t.Errorf("artifact %s is empty", name)
+		}
+	}
+
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() { os.Stderr.Close(); os.Stderr = stderr }()
+	for _, args := range [][]string{{"--bogus"}, {"--planes", "1"}, {"--planes", "6", "--speed", "fast"}} {
+		if code := runHeadless(append(args, "--out", filepath.Join(t.TempDir(), "results"))); code != exitUsage {
+			t.Errorf("run %v: got exit code %d, want %d", args, code, exitUsage)
+		}
+	}
+}
+
+// TestCampaignIntervals checks the campaign's 95% intervals against known values.
+func TestCampaignIntervals(t *testing.T) {
+	const tolerance = 1e-6
+	near := func(got, want [3]float64) bool {
+		for i := range got {
+			if math.Abs(got[i]-want[i]) > tolerance {
+				return false
+			}
+		}
+		return true
+	}
+
+	wilsonTests := []struct {
+		successes, trials int
+		want              [3]float64
+	}{
+		{0, 0, [3]float64{0, 0, 0}},
+		{0, 10, [3]float64{0, 0, 0.277533}},
+		{5, 10, [3]float64{0.5, 0.236593, 0.763407}},
+		{10, 10, [3]float64{1, 0.722467, 1}},
+		{1, 20, [3]float64{0.05, 0.008881, 0.236131}},
+	}
+	for _, test := range wilsonTests {
+		ratio, low, high := wilsonInterval(test.successes, test.trials)
+		if got := [3]float64{ratio, low, high}; !near(got, test.want) {
+			t.Errorf("wilsonInterval(%d, %d) = %v, want %v", test.successes, test.trials, got, test.want)
+		}
+	}
+
+	poissonTests := []struct {
+		count    int
+		exposure float64
+		want     [3]float64
+	}{
+		{0, 0, [3]float64{0, 0, 0}},
+		{3, -1, [3]float64{0, 0, 0}},
+		{0, 10, [3]float64{0, 0, 0.3}}, // rule of three
+		{4, 2, [3]float64{2, 0.040036, 3.959964}},
+		{1, 0.5, [3]float64{2, 0, 5.919928}},
+	}
+	for _, test := range poissonTests {
+		rate, low, high := poissonRateInterval(test.count, test.exposure)
+		if got := [3]float64{rate, low, high}; !near(got, test.want) {
+			t.Errorf("poissonRateInterval(%d, %v) = %v, want %v", test.count, test.exposure, got, test.want)
+		}
+	}
+
+	meanTests := []struct {
+		samples []float64
+		want    [3]float64
+	}{
+		{nil, [3]float64{0, 0, 0}},
+		{[]float64{3}, [3]float64{3, 3, 3}},
+		{[]float64{1, 2, 3}, [3]float64{2, 0.868414, 3.131586}},
+		{[]float64{0, 0, 0, 4}, [3]float64{1, 0, 2.959964}},
+	}
+	for _, test := range meanTests {
+		mean, low, high := meanInterval(test.samples)
+		if got := [3]float64{mean, low, high}; !near(got, test.want) {
+			t.Errorf("meanInterval(%v) = %v, want %v", test.samples, got, test.want)
+		}
+	}
+}
+
+// TestCampaignGrid checks the cells built from the campaign's parameter lists and the lists it rejects.
+func TestCampaignGrid(t *testing.T) {
+	tests := []struct {
+		name                                                  string
+		planes, altitudes, faulty, threshold, comply, reverse string
+		wantCells                                             int
+		wantFirst, wantLast                                   campaignCell
+		wantErr                                               string
+	}{
+		{
+			name: "single cell", planes: "20", altitudes: "same", faulty: "0.25", threshold: "5", comply: "0", reverse: "0",
+			wantCells: 1,
+			wantFirst: campaignCell{Planes: 20, FaultyTCASRatio: 0.25, CollisionThreshold: 5},
+			wantLast:  campaignCell{Planes: 20, FaultyTCASRatio: 0.25, CollisionThreshold: 5},
+		},
+		{
+			name: "cartesian product", planes: " 20, 50", altitudes: "same,Varied", faulty: "0.1", threshold: "5", comply: "0,0.2", reverse: "0.1",
+			wantCells: 8,
+			wantFirst: campaignCell{Planes: 20, FaultyTCASRatio: 0.1, CollisionThreshold: 5, PilotOppositeResponse: 0.1},
+			wantLast: campaignCell{Planes: 50, DifferentAltitudes: true, FaultyTCASRatio: 0.1, CollisionThreshold: 5,
+				PilotNonCompliance: 0.2, PilotOppositeResponse: 0.1},
+		},
+		{name: "one plane", planes: "20,1", altitudes: "same", faulty: "0", threshold: "5", comply: "0", reverse: "0", wantErr: "invalid plane count"},
+		{name: "plane count not a number", planes: "x", altitudes: "same", faulty: "0", threshold: "5", comply: "0", reverse: "0", wantErr: "invalid plane count"},
+		{name: "unknown altitudes", planes: "20", altitudes: "high", faulty: "0", threshold: "5", comply: "0", reverse: "0", wantErr: "invalid altitude mode"},
+		{name: "faulty above 1", planes: "20", altitudes: "same", faulty: "1.5", threshold: "5", comply: "0", reverse: "0", wantErr: "invalid faulty-TCAS ratio"},
+		{name: "negative threshold", planes: "20", altitudes: "same", faulty: "0", threshold: "-1", comply: "0", reverse: "0", wantErr: "invalid collision threshold"},
+		{name: "zero threshold", planes: "20", altitudes: "same", faulty: "0", threshold: "5,0", comply: "0", reverse: "0", wantErr: "invalid collision threshold"},
+		{name: "empty list", planes: "20", altitudes: "same", faulty: "0", threshold: "5", comply: "", reverse: "0", wantErr: "invalid non-compliance"},
+		{name: "crew responses above 1", planes: "20", altitudes: "same", faulty: "0", threshold: "5", comply: "0,0.6", reverse: "0.5", wantErr: "add up to more than 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cells, err := campaignGrid(test.planes, test.altitudes, test.faulty, test.threshold, test.comply, test.reverse)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cells) != test.wantCells {
+				t.Fatalf("got %d cells, want %d", len(cells), test.wantCells)
+			}
+			if cells[0] != test.wantFirst || cells[len(cells)-1] != test.wantLast {
+				t.Errorf("got cells from %+v to %+v, want from %+v to %+v", cells[0], cells[len(cells)-1], test.wantFirst, test.wantLast)
+			}
+		})
+	}
+}
+
+// pipeResponseWriter is a streaming ResponseWriter whose writes block until the test reads them.
+type pipeResponseWriter struct {
+	*io.PipeWriter
+	header http.Header
+}
+
+func (w *pipeResponseWriter) Header() http.Header { return w.header }
+func (w *pipeResponseWriter) WriteHeader(int)     {}
+func (w *pipeResponseWriter) Flush()              {}
+
+// newStreamTestServer returns an API server with a running simulation 1, whose events the test publishes itself.
+func newStreamTestServer(t *testing.T) (*apiServer, *apiSimulation) {
+	t.Helper()
+	sim := &apiSimulation{id: "1", state: &aviation.SimulationState{Events: aviation.NewEventBus()}, done: make(chan struct{})}
+	server := &apiServer{outDir: t.TempDir(), simulations: map[string]*apiSimulation{"1": sim}, latest: "1"}
+	return server, sim
+}
+
+// openEventStream requests the live feed with the given query and returns it once the feed has subscribed to the events.
+func openEventStream(t *testing.T, server *apiServer, query string) *bufio.Reader {
+	t.Helper()
+	reader, writer := io.Pipe()
+	t.Cleanup(func() { reader.Close() })
+	go func() {
+		server.streamEvents(&pipeResponseWriter{PipeWriter: writer, header: http.Header{}}, httptest.NewRequest(http.MethodGet, "/events?"+query, nil))
+		writer.Close()
+	}()
+	stream := bufio.NewReader(reader)
+	if line, err := stream.ReadString('\n'); err != nil || !strings.HasPrefix(line, ": live feed of simulation 1") {
+		t.Fatalf("the feed started with %q (%v), want its opening comment", line, err)
+	}
+	if _, err := stream.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+// readEventStream reads the feed until it closes and returns the names of its messages and its comments.
+func readEventStream(t *testing.T, stream io.Reader) (events, comments []string) {
+	t.Helper()
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range strings.Split(strings.TrimSpace(string(data)), "\n\n") {
+		for _, line := range strings.Split(message, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				events = append(events, name)
+			} else if comment, ok := strings.CutPrefix(line, ": "); ok {
+				comments = append(comments, comment)
+			}
+		}
+	}
+	return events, comments
+}
+
+// TestStreamEventsFilters checks that the live feed only sends the events of the planes and kinds asked for,
+// and always sends the end of the simulation last.
+func TestStreamEventsFilters(t *testing.T) {
+	server, sim := newStreamTestServer(t)
+	stream := openEventStream(t, server, "plane=p2,P3&type=takeoff_started,tcas_advisory")
+
+	sim.state.Events.Publish(aviation.TakeoffStarted{Plane: "P1"})
+	sim.state.Events.Publish(aviation.TakeoffStarted{Plane: "P2"})
+	sim.state.Events.Publish(aviation.LandingCompleted{Plane: "P2"})
+	sim.state.Events.Publish(aviation.TCASAdvisory{Plane: "P1", Intruder: "P3"})
+	sim.state.Events.Publish(aviation.TCASAdvisory{Plane: "P1", Intruder: "P4"})
+	sim.state.Events.Publish(aviation.SimulationEnded{Reason: "duration reached"})
+	close(sim.done)
+
+	events, comments := readEventStream(t, stream)
+	want := []string{"takeoff_started", "tcas_advisory", "simulation_ended"}
+	if !slices.Equal(events, want) {
+		t.Errorf("the feed sent %v, want %v", events, want)
+	}
+	if len(comments) == 0 || comments[len(comments)-1] != "3 event(s) sent, 0 dropped" {
+		t.Errorf("the feed closed with comments %q, want the count of events sent and dropped", comments)
+	}
+}
+
+// TestStreamEventsSlowClient checks that the live feed drops the events a slow client cannot keep up with,
+// counts them, and still sends the end of the simulation.
+func TestStreamEventsSlowClient(t *testing.T) {
+	server, sim := newStreamTestServer(t)
+	stream := openEventStream(t, server, "")
+
+	// the feed is stuck writing the first event until the client reads it all
+	sim.state.Events.Publish(aviation.TakeoffStarted{Plane: "P1"})
+	first, err := stream.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range eventStreamBuffer + 3 {
+		sim.state.Events.Publish(aviation.PositionUpdate{Plane: "P1"})
+	}
+	sim.state.Events.Publish(aviation.SimulationEnded{Reason: "duration reached"})
+	close(sim.done)
+
+	events, comments := readEventStream(t, io.MultiReader(strings.NewReader(string(first)), stream))
+	if len(events) != eventStreamBuffer+2 || events[0] != "takeoff_started" || events[len(events)-1] != "simulation_ended" {
+		t.Fatalf("the feed sent %d events, from %v to %v, want the first, %d queued and the end", len(events), events[0], events[len(events)-1], eventStreamBuffer)
+	}
+	want := fmt.Sprintf("%d event(s) sent, 3 dropped", eventStreamBuffer+2)
+	if len(comments) == 0 || comments[len(comments)-1] != want {
+		t.Errorf("the feed closed with comments %q, want %q", comments, want)
+	}
+}
+
+// TestStreamEventsRequests checks the answers of the live feed to requests it cannot serve.
+func TestStreamEventsRequests(t *testing.T) {
+	server, sim := newStreamTestServer(t)
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantError  string
+	}{
+		{"type=takeoff_started,landing", http.StatusBadRequest, "unknown event type"},
+		{"simulation=2", http.StatusNotFound, "no simulation"},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		server.streamEvents(recorder, httptest.NewRequest(http.MethodGet, "/events?"+test.query, nil))
+		if recorder.Code != test.wantStatus || !strings.Contains(recorder.Body.String(), test.wantError) {
+			t.Errorf("GET /events?%s answered %d with %s, want %d and %s", test.query, recorder.Code, recorder.Body, test.wantStatus, test.wantError)
+		}
+	}
+
+	close(sim.done)
+	recorder := httptest.NewRecorder()
+	server.streamEvents(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("GET /events of an ended simulation answered %d, want 409", recorder.Code)
+	}
+}
+
+// TestServeMetrics checks the exposition of the counters and histograms of a simulation recorded from its events.
+func TestServeMetrics(t *testing.T) {
+	sim := &apiSimulation{id: "1", state: &aviation.SimulationState{}, metrics: newSimulationMetrics(), done: make(chan struct{})}
+	server := &apiServer{simulations: map[string]*apiSimulation{"1": sim}, latest: "1"}
+	start := aviation.SimulationEpoch
+	for _, event := range []aviation.Event{
+		// P1 waits 20s for a runway, however often it finds them busy; P2 waits for none
+		aviation.RunwayBusy{Time: start, Plane: "P1", Operation: "takeoff"},
+		aviation.RunwayBusy{Time: start.Add(5 * time.Second), Plane: "P1", Operation: "takeoff"},
+		aviation.TakeoffStarted{Time: start.Add(20 * time.Second), Plane: "P1"},
+		aviation.TakeoffStarted{Time: start, Plane: "P2"},
+		aviation.TakeoffCompleted{Time: start, Plane: "P2", FlightID: "F2"},
+		aviation.TakeoffCompleted{Time: start.Add(time.Minute), Plane: "P1", FlightID: "F1"},
+		aviation.TCASAdvisory{Plane: "P1", Intruder: "P2", Advisory: aviation.AdvisoryTA},
+		aviation.TCASAdvisory{Plane: "P1", Intruder: "P2", Advisory: aviation.AdvisoryRA},
+		aviation.TCASAdvisory{Plane: "P1", Intruder: "P2", Advisory: aviation.AdvisoryRA, Strengthened: true},
+		aviation.Collision{Plane: "P1", Intruder: "P2"},
+		// F1 flies 40 minutes, F2 longer than the last bucket
+		aviation.LandingCompleted{Time: start.Add(41 * time.Minute), Plane: "P1", FlightID: "F1"},
+		aviation.LandingCompleted{Time: start.Add(10000 * time.Second), Plane: "P2", FlightID: "F2"},
+	} {
+		sim.metrics.record(event)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.serveMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	lines := strings.Split(recorder.Body.String(), "\n")
+	for _, want := range []string{
+		`# TYPE tcas_takeoff_queue_wait_seconds histogram`,
+		`tcas_takeoffs_total{simulation="1"} 2`,
+		`tcas_landings_total{simulation="1"} 2`,
+		`tcas_traffic_advisories_total{simulation="1"} 1`,
+		`tcas_resolution_advisories_total{simulation="1"} 1`,
+		`tcas_crashes_total{simulation="1"} 1`,
+		`tcas_takeoff_queue_wait_seconds_bucket{simulation="1",le="1"} 1`,
+		`tcas_takeoff_queue_wait_seconds_bucket{simulation="1",le="10"} 1`,
+		`tcas_takeoff_queue_wait_seconds_bucket{simulation="1",le="30"} 2`,
+		`tcas_takeoff_queue_wait_seconds_bucket{simulation="1",le="600"} 2`,
+		`tcas_takeoff_queue_wait_seconds_bucket{simulation="1",le="+Inf"} 2`,
+		`tcas_takeoff_queue_wait_seconds_sum{simulation="1"} 20`,
+		`tcas_takeoff_queue_wait_seconds_count{simulation="1"} 2`,
+		`tcas_flight_duration_seconds_bucket{simulation="1",le="1800"} 0`,
+		`tcas_flight_duration_seconds_bucket{simulation="1",le="2700"} 1`,
+		`tcas_flight_duration_seconds_bucket{simulation="1",le="7200"} 1`,
+		`tcas_flight_duration_seconds_bucket{simulation="1",le="+Inf"} 2`,
+		`tcas_flight_duration_seconds_sum{simulation="1"} 12400`,
+		`tcas_flight_duration_seconds_count{simulation="1"} 2`,
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("/metrics lacks %s", want)
+		}
+	}
+
+	// every histogram's buckets only grow, up to its count
+	buckets := map[string]float64{}
+	for _, line := range lines {
+		name, value, _ := strings.Cut(line, " ")
+		number, err := strconv.ParseFloat(value, 64)
+		if strings.HasPrefix(line, "#") || err != nil {
+			continue
+		}
+		if family, _, isBucket := strings.Cut(name, "_bucket{"); isBucket {
+			if number < buckets[family] {
+				t.Errorf("%s is below the previous bucket, %v", line, buckets[family])
+			}
+			buckets[family] = number
+		} else if family, _, isCount := strings.Cut(name, "_count{"); isCount && number != buckets[family] {
+			t.Errorf("%s differs from the +Inf bucket, %v", line, buckets[family])
+		}
+	}
+}
+
+// radarTestState returns a state at 00:03:20 of the simulation epoch with two airports 90 km apart and two planes
+// cruising at 10,000 m on opposite headings, 20 km apart, the first with a Resolution Advisory against the second.
+func radarTestState(t *testing.T) *aviation.SimulationState {
+	t.Helper()
+	start := aviation.SimulationEpoch
+	clock := aviation.NewSimClock(start.Add(200*time.Second), aviation.MaxSpeed)
+	t.Cleanup(clock.Stop)
+	flight := func(from, to aviation.Coordinate) aviation.Flight {
+		return aviation.Flight{TakeoffTime: start, DestinationArrivalTime: start.Add(400 * time.Second),
+			Trajectory: []aviation.Waypoint{{Position: from, Time: start}, {Position: to, Time: start.Add(400 * time.Second)}}}
+	}
+	return &aviation.SimulationState{
+		Clock: clock,
+		Airports: []*aviation.Airport{
+			{Serial: "A1", Location: aviation.Coordinate{X: 0, Y: 0}},
+			{Serial: "A2", Location: aviation.Coordinate{X: 90000, Y: 0}},
+		},
+		PlanesInFlight: []aviation.Plane{
+			{Serial: "P2", FlightLog: []aviation.Flight{flight(aviation.Coordinate{X: 90000, Y: 20000, Z: 10000}, aviation.Coordinate{X: 0, Y: 20000, Z: 10000})}},
+			{Serial: "P1", FlightLog: []aviation.Flight{flight(aviation.Coordinate{X: 0, Y: 0, Z: 10000}, aviation.Coordinate{X: 90000, Y: 0, Z: 10000})},
+				CurrentTCASEngagements: []aviation.TCASEngagement{{PlaneSerial: "P1", OtherPlaneSerial: "P2", Advisory: aviation.AdvisoryRA}}},
+		},
+	}
+}
+
+// TestRenderRadar checks where the radar draws airports, planes, their tags and velocity vectors, and how it colors advisories.
+func TestRenderRadar(t *testing.T) {
+	simState := radarTestState(t)
+	lines := strings.Split(renderRadar(simState, radarWidth, radarHeight, false), "\n")
+	if len(lines) != radarHeight+5 {
+		t.Fatalf("the radar has %d lines, want %d", len(lines), radarHeight+5)
+	}
+	if want := "TCAS RADAR  2025-01-01 00:03:20  |  2 plane(s) in flight  |  1 column = 918.37 meters"; lines[0] != want {
+		t.Errorf("got header %q, want %q", lines[0], want)
+	}
+	border := "+" + strings.Repeat("-", radarWidth) + "+"
+	if lines[1] != border || lines[radarHeight+2] != border {
+		t.Errorf("got borders %q and %q, want %q", lines[1], lines[radarHeight+2], border)
+	}
+
+	// the scope is 918.37 m per column and twice that per row, centred between the airports and the planes:
+	// P2 flies west 20 km north of the airports, P1 east midway between them, 13.5 km a minute
+	rows := map[int]string{
+		11: strings.Repeat(" ", 35) + strings.Repeat(".", 15) + "+ P2 FL328" + strings.Repeat(" ", 40),
+		21: " #" + strings.Repeat(" ", 48) + "+.P1 FL328......" + strings.Repeat(" ", 33) + "#",
+	}
+	for row := range radarHeight {
+		want, drawn := rows[row]
+		if !drawn {
+			want = strings.Repeat(" ", radarWidth)
+		}
+		if got := lines[row+2]; got != "|"+want+"|" {
+			t.Errorf("row %d is\n%q, want\n%q", row, got, "|"+want+"|")
+		}
+	}
+
+	// both planes of the Resolution Advisory are red, the airports cyan
+	colored := renderRadar(simState, radarWidth, radarHeight, true)
+	for _, want := range []string{colorRA + "+" + colorReset, colorRA + "P" + colorReset + colorRA + "2" + colorReset, colorAirport + "#" + colorReset} {
+		if !strings.Contains(colored, want) {
+			t.Errorf("the colored radar lacks %q", want)
+		}
+	}
+	if strings.Contains(colored, colorPlane) || strings.Contains(colored, colorTA+"+") {
+		t.Error("the colored radar shows a plane without the RA it is involved in")
+	}
+}
+
+// TestRadarSessions checks that starting a radar closes the one shown before, that stopping it closes the last
+// one, and that no frame is drawn once the radar is stopped, even when a tick is ready at the same time.
+func TestRadarSessions(t *testing.T) {
+	simState := &aviation.SimulationState{}
+	first, firstDone := simState.StartRadar()
+	close(firstDone)
+	second, secondDone := simState.StartRadar()
+	select {
+	case <-first:
+	default:
+		t.Error("starting a second radar left the first one open")
+	}
+	close(secondDone)
+	simState.StopRadar()
+	simState.StopRadar()
+	select {
+	case <-second:
+	default:
+		t.Error("stopping the radar left it open")
+	}
+
+	t.Run("tick at the same time as a stop", func(t *testing.T) {
+		// select picks at random between ready cases, so the race is run many times
+		for i := 0; i < 200; i++ {
+			stop, done := make(chan struct{}), make(chan struct{})
+			ticks := make(chan time.Time, 1)
+			out := &radarStopWriter{stop: stop, ticks: ticks}
+			drawRadar(out, ticks, stop, done, func() string { return "frame\n" })
+			if out.frames != 1 {
+				t.Fatalf("run %d: %d frames drawn, want 1", i, out.frames)
+			}
+			select {
+			case <-done:
+			default:
+				t.Fatalf("run %d: the radar did not close done", i)
+			}
+		}
+	})
+}
+
+// radarStopWriter counts the radar frames written to it and, once the first one is, closes stop and sends
+// a tick so that both are ready when the radar waits for the next frame.
+type radarStopWriter struct {
+	stop   chan struct{}
+	ticks  chan time.Time
+	frames int
+}
+
+func (w *radarStopWriter) Write(p []byte) (int, error) {
+	w.frames++
+	if w.frames == 1 {
+		close(w.stop)
+		w.ticks <- time.Time{}
+	}
+	return len(p), nil
+}
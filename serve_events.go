@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// eventStreamBuffer is how many events a live feed holds for a slow client before it drops them.
+const eventStreamBuffer = 1024
+
+// eventStreamKeepAlive is how often, in wall-clock time, an idle live feed sends a comment so that
+// proxies and browsers keep the connection open.
+const eventStreamKeepAlive = 15 * time.Second
+
+// streamEvents sends the events of a running simulation to the client as Server-Sent Events, as they
+// are published, until the simulation ends or the client goes away. Each message is named after the
+// kind of its event and carries the event as JSON, as written to events.jsonl.
+//
+// The "plane" and "type" query parameters take comma separated serials and event kinds: only events
+// about one of the planes, as own plane or intruder, and of one of the kinds are sent. The final
+// simulation_ended event is always sent, even to a client so slow that other events were dropped,
+// and is followed by a comment counting the events sent and dropped.
+func (server *apiServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	planes := splitList(query.Get("plane"))
+	kinds := []aviation.EventKind{}
+	for _, kind := range splitList(query.Get("type")) {
+		if !slices.Contains(aviation.EventKinds, aviation.EventKind(kind)) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown event type %q, expected one of %v", kind, aviation.EventKinds))
+			return
+		}
+		kinds = append(kinds, aviation.EventKind(kind))
+	}
+	sim := server.resolve(w, r)
+	if sim == nil {
+		return
+	}
+
+	// events are queued by the simulation goroutines and written here, so that a slow client never holds them up;
+	// the end of the simulation has a place of its own, so that it is never dropped
+	events := make(chan aviation.Event, eventStreamBuffer)
+	ended := make(chan aviation.Event, 1)
+	var dropped atomic.Int64
+	wanted := func(event aviation.Event) bool {
+		if len(kinds) > 0 && !slices.Contains(kinds, event.Kind()) {
+			return false
+		}
+		if len(planes) == 0 {
+			return true
+		}
+		for _, serial := range aviation.EventPlanes(event) {
+			if slices.ContainsFunc(planes, func(plane string) bool { return strings.EqualFold(plane, serial) }) {
+				return true
+			}
+		}
+		return false
+	}
+	unsubscribe := sim.state.Events.Subscribe(func(event aviation.Event) {
+		if event.Kind() == aviation.KindSimulationEnded {
+			select {
+			case ended <- event:
+			default:
+			}
+			return
+		}
+		if !wanted(event) {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+			dropped.Add(1)
+		}
+	})
+	defer unsubscribe()
+
+	select {
+	case <-sim.done:
+		writeError(w, http.StatusConflict, fmt.Sprintf("simulation %s has already ended", sim.id))
+		return
+	default:
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, ": live feed of simulation %s\n\n", sim.id)
+	controller.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	sent := 0
+	send := func(event aviation.Event) error {
+		data, err := aviation.MarshalEvent(event)
+		if err != nil {
+			return nil
+		}
+		sent++
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", sent, event.Kind(), data); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+	for {
+		select {
+		case event := <-events:
+			if err := send(event); err != nil {
+				return
+			}
+		case <-sim.done:
+			// the simulation ended: send what it published last, then its end, and close the feed
+			for len(events) > 0 {
+				if send(<-events) != nil {
+					return
+				}
+			}
+			select {
+			case event := <-ended:
+				if send(event) != nil {
+					return
+				}
+			default:
+			}
+			fmt.Fprintf(w, ": %d event(s) sent, %d dropped\n\n", sent, dropped.Load())
+			controller.Flush()
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprintf(w, ": %d event(s) sent, %d dropped\n\n", sent, dropped.Load()); err != nil {
+				return
+			}
+			if controller.Flush() != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// splitList splits a comma separated query parameter, ignoring blanks.
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// Bucket upper bounds, in seconds, of the histograms published on /metrics.
+var (
+	takeoffQueueWaitBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+	flightDurationBuckets   = []float64{300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200}
+)
+
+// simulationMetrics counts what happened during a simulation from the events it publishes.
+type simulationMetrics struct {
+	mu                   sync.Mutex
+	takeoffs             int
+	landings             int
+	trafficAdvisories    int
+	resolutionAdvisories int
+	crashes              int
+	takeoffQueueWait     histogram
+	flightDuration       histogram
+	waitingSince         map[string]time.Time // when each plane waiting for a runway to take off first found them busy
+	takeoffTimes         map[string]time.Time // when each flight in the air took off, by flight ID
+}
+
+// histogram counts observations into buckets, as a Prometheus histogram.
+type histogram struct {
+	bounds []float64 // upper bounds of the buckets, the last +Inf bucket excluded
+	counts []int     // observations per bucket, not cumulative, the last one for +Inf
+	sum    float64
+}
+
+// newSimulationMetrics returns the metrics of a simulation before anything happened.
+func newSimulationMetrics() *simulationMetrics {
+	return &simulationMetrics{
+		takeoffQueueWait: histogram{bounds: takeoffQueueWaitBuckets, counts: make([]int, len(takeoffQueueWaitBuckets)+1)},
+		flightDuration:   histogram{bounds: flightDurationBuckets, counts: make([]int, len(flightDurationBuckets)+1)},
+		waitingSince:     map[string]time.Time{},
+		takeoffTimes:     map[string]time.Time{},
+	}
+}
+
+// record is the EventBus handler updating the metrics with an event of the simulation.
+func (metrics *simulationMetrics) record(event aviation.Event) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	switch e := event.(type) {
+	case aviation.RunwayBusy:
+		if _, waiting := metrics.waitingSince[e.Plane]; e.Operation == "takeoff" && !waiting {
+			metrics.waitingSince[e.Plane] = e.Time
+		}
+	case aviation.TakeoffStarted:
+		// a plane that found a runway free right away waited for none
+		wait := time.Duration(0)
+		if since, waiting := metrics.waitingSince[e.Plane]; waiting {
+			wait = e.Time.Sub(since)
+			delete(metrics.waitingSince, e.Plane)
+		}
+		metrics.takeoffQueueWait.observe(wait.Seconds())
+	case aviation.TakeoffCompleted:
+		metrics.takeoffs++
+		metrics.takeoffTimes[e.FlightID] = e.Time
+	case aviation.LandingCompleted:
+		metrics.landings++
+		if takeoff, flying := metrics.takeoffTimes[e.FlightID]; flying {
+			metrics.flightDuration.observe(e.Time.Sub(takeoff).Seconds())
+			delete(metrics.takeoffTimes, e.FlightID)
+		}
+	case aviation.TCASAdvisory:
+		// a strengthened RA is the same advisory
+		switch {
+		case e.Strengthened:
+		case e.Advisory == aviation.AdvisoryTA:
+			metrics.trafficAdvisories++
+		case e.Advisory == aviation.AdvisoryRA:
+			metrics.resolutionAdvisories++
+		}
+	case aviation.Collision:
+		metrics.crashes++
+	}
+}
+
+// observe adds a value to the histogram.
+func (h *histogram) observe(value float64) {
+	bucket := len(h.bounds)
+	for i, bound := range h.bounds {
+		if value <= bound {
+			bucket = i
+			break
+		}
+	}
+	h.counts[bucket]++
+	h.sum += value
+}
+
+// metricFamily is a metric of the Prometheus text exposition format with its samples.
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string // "gauge", "counter" or "histogram"
+	samples []metricSample
+}
+
+// metricSample is a line of a metric family: the name's suffix, the labels and the value.
+type metricSample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+// serveMetrics publishes the gauges, counters and histograms of every simulation started through the API
+// in the Prometheus text exposition format, each series labelled with the ID of its simulation.
+func (server *apiServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	planesInFlight := &metricFamily{name: "tcas_planes_in_flight", help: "Planes currently in flight.", kind: "gauge"}
+	planesParked := &metricFamily{name: "tcas_planes_parked", help: "Planes currently parked at each airport.", kind: "gauge"}
+	runways := &metricFamily{name: "tcas_runways", help: "Runways of each airport.", kind: "gauge"}
+	runwaysInUse := &metricFamily{name: "tcas_runways_in_use", help: "Runways of each airport currently used by a takeoff or a landing.", kind: "gauge"}
+	takeoffs := &metricFamily{name: "tcas_takeoffs_total", help: "Planes that took off.", kind: "counter"}
+	landings := &metricFamily{name: "tcas_landings_total", help: "Planes that landed.", kind: "counter"}
+	trafficAdvisories := &metricFamily{name: "tcas_traffic_advisories_total", help: "TCAS Traffic Advisories issued.", kind: "counter"}
+	resolutionAdvisories := &metricFamily{name: "tcas_resolution_advisories_total", help: "TCAS Resolution Advisories issued.", kind: "counter"}
+	crashes := &metricFamily{name: "tcas_crashes_total", help: "Mid-air collisions, one per pair of planes.", kind: "counter"}
+	queueWait := &metricFamily{name: "tcas_takeoff_queue_wait_seconds", help: "Simulated time planes waited for a runway to take off.", kind: "histogram"}
+	flightDuration := &metricFamily{name: "tcas_flight_duration_seconds", help: "Simulated time from takeoff to landing of the flights that landed.", kind: "histogram"}
+
+	for _, sim := range server.sortedSimulations() {
+		labels := `simulation="` + labelValue(sim.id) + `"`
+		simState := sim.state
+
+		simState.Mu.Lock()
+		planesInFlight.add("", labels, float64(len(simState.PlanesInFlight)))
+		simState.Mu.Unlock()
+		for _, airport := range simState.Airports {
+			airportLabels := labels + `,airport="` + labelValue(airport.Serial) + `"`
+			airport.Mu.Lock()
+			total, inUse := airport.Runways()
+			planesParked.add("", airportLabels, float64(len(airport.Planes)))
+			airport.Mu.Unlock()
+			runways.add("", airportLabels, float64(total))
+			runwaysInUse.add("", airportLabels, float64(inUse))
+		}
+
+		metrics := sim.metrics
+		metrics.mu.Lock()
+		takeoffs.add("", labels, float64(metrics.takeoffs))
+		landings.add("", labels, float64(metrics.landings))
+		trafficAdvisories.add("", labels, float64(metrics.trafficAdvisories))
+		resolutionAdvisories.add("", labels, float64(metrics.resolutionAdvisories))
+		crashes.add("", labels, float64(metrics.crashes))
+		queueWait.addHistogram(labels, metrics.takeoffQueueWait)
+		flightDuration.addHistogram(labels, metrics.flightDuration)
+		metrics.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	for _, family := range []*metricFamily{planesInFlight, planesParked, runways, runwaysInUse, takeoffs, landings,
+		trafficAdvisories, resolutionAdvisories, crashes, queueWait, flightDuration} {
+		out.WriteString("# HELP " + family.name + " " + family.help + "\n")
+		out.WriteString("# TYPE " + family.name + " " + family.kind + "\n")
+		for _, sample := range family.samples {
+			out.WriteString(family.name + sample.suffix + "{" + sample.labels + "} " + formatMetric(sample.value) + "\n")
+		}
+	}
+	out.Flush()
+}
+
+// add appends a sample to the family.
+func (family *metricFamily) add(suffix, labels string, value float64) {
+	family.samples = append(family.samples, metricSample{suffix: suffix, labels: labels, value: value})
+}
+
+// addHistogram appends the cumulative buckets, sum and count of a histogram to the family.
+func (family *metricFamily) addHistogram(labels string, h histogram) {
+	cumulative := 0
+	for i, count := range h.counts {
+		cumulative += count
+		bound := math.Inf(1)
+		if i < len(h.bounds) {
+			bound = h.bounds[i]
+		}
+		family.add("_bucket", labels+`,le="`+formatMetric(bound)+`"`, float64(cumulative))
+	}
+	family.add("_sum", labels, h.sum)
+	family.add("_count", labels, float64(cumulative))
+}
+
+// formatMetric formats a sample value or bucket bound as the exposition format expects.
+func formatMetric(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// labelValueEscaper escapes the characters the exposition format does not allow in a label value.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue escapes a label value.
+func labelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation/internal/aviation"
+)
+
+// The JSON documents returned by the API. Distances are in meters and times are simulation times;
+// times that have not happened yet, and latitudes and longitudes on a flat map, are left out.
+
+// simulationView is the status of a simulation started through the API.
+type simulationView struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"` // "running", "paused", "finished" or "stopped"
+	Seed           int64      `json:"seed"`
+	Duration       string     `json:"duration"`
+	Speed          string     `json:"speed"`
+	SimulationTime *time.Time `json:"simulation_time,omitempty"`
+	PlanesInFlight int        `json:"planes_in_flight"`
+	Collisions     int        `json:"collisions"`
+	LogDir         string     `json:"log_dir"`
+}
+
+// airportView is an airport in the API.
+type airportView struct {
+	Serial         string   `json:"serial"`
+	X              float64  `json:"x"`
+	Y              float64  `json:"y"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	Runways        int      `json:"runways"`
+	RunwaysInUse   int      `json:"runways_in_use"`
+	InitialPlanes  int      `json:"initial_planes"`
+	ParkedPlanes   []string `json:"parked_planes"`
+	ReceivingPlane bool     `json:"receiving_plane"`
+}
+
+// planeView is a plane in the API, with its position while it is in flight.
+type planeView struct {
+	Serial         string                 `json:"serial"`
+	AircraftType   string                 `json:"aircraft_type"`
+	CruiseSpeed    float64                `json:"cruise_speed"` // meters per second
+	TCASCapability string                 `json:"tcas_capability"`
+	InFlight       bool                   `json:"in_flight"`
+	Airport        string                 `json:"airport,omitempty"`   // where the plane is parked
+	FlightID       string                 `json:"flight_id,omitempty"` // the flight the plane is flying
+	Position       *aviation.Coordinate   `json:"position,omitempty"`
+	Velocity       *aviation.Coordinate   `json:"velocity,omitempty"` // meters per second
+	Advisory       *aviation.AdvisoryType `json:"advisory,omitempty"` // highest advisory active on board
+	Flights        int                    `json:"flights"`
+	Engagements    int                    `json:"engagements"`
+}
+
+// flightView is a flight in the API.
+type flightView struct {
+	FlightID             string     `json:"flight_id"`
+	Plane                string     `json:"plane"`
+	DepartureAirport     string     `json:"departure_airport"`
+	ArrivalAirport       string     `json:"arrival_airport"`
+	CruisingAltitude     float64    `json:"cruising_altitude"`
+	TakeoffTime          time.Time  `json:"takeoff_time"`
+	ScheduledArrivalTime time.Time  `json:"scheduled_arrival_time"`
+	LandingTime          *time.Time `json:"landing_time,omitempty"`
+	Status               string     `json:"status"`
+	TrackPoints          int        `json:"track_points"`
+}
+
+// engagementView is a TCAS engagement in the API.
+type engagementView struct {
+	EngagementID        string                `json:"engagement_id"`
+	FlightID            string                `json:"flight_id"`
+	Plane               string                `json:"plane"`
+	Intruder            string                `json:"intruder"`
+	Advisory            aviation.AdvisoryType `json:"advisory"`
+	SensitivityLevel    int                   `json:"sensitivity_level"`
+	TATime              *time.Time            `json:"ta_time,omitempty"`
+	RATime              *time.Time            `json:"ra_time,omitempty"`
+	Sense               aviation.RASense      `json:"sense"`
+	Coordinated         bool                  `json:"coordinated"`
+	PilotResponse       aviation.RASense      `json:"pilot_response"`
+	ClosestApproachTime time.Time             `json:"closest_approach_time"`
+	MissDistance        float64               `json:"miss_distance"`
+	ClosedTime          *time.Time            `json:"closed_time,omitempty"`
+	Collided            bool                  `json:"collided"`
+	Outcome             string                `json:"outcome"`
+}
+
+// view returns the status of the simulation.
+func (sim *apiSimulation) view() simulationView {
+	simState := sim.state
+	view := simulationView{
+		ID:       sim.id,
+		Status:   "running",
+		Seed:     simState.Seed,
+		Duration: sim.duration.String(),
+		Speed:    aviation.FormatClockSpeed(sim.speed),
+		LogDir:   simState.LogDir,
+	}
+	select {
+	case <-sim.done:
+		view.Status = "finished"
+		sim.stopMu.Lock()
+		stopped := sim.stopped
+		sim.stopMu.Unlock()
+		if stopped {
+			view.Status = "stopped"
+		}
+	default:
+		if clock, ok := simState.Clock.(*aviation.SimClock); ok && clock.Paused() {
+			view.Status = "paused"
+		}
+	}
+	view.SimulationTime = optionalTime(simulationTime(simState))
+	simState.Mu.Lock()
+	view.PlanesInFlight = len(simState.PlanesInFlight)
+	view.Collisions = len(simState.Collisions)
+	simState.Mu.Unlock()
+	return view
+}
+
+// airportViews returns every airport of the simulation.
+func airportViews(simState *aviation.SimulationState) any {
+	views := []airportView{}
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		view := airportView{
+			Serial:         airport.Serial,
+			X:              airport.Location.X,
+			Y:              airport.Location.Y,
+			InitialPlanes:  airport.InitialPlaneAmount,
+			ParkedPlanes:   []string{},
+			ReceivingPlane: airport.ReceivingPlane,
+		}
+		view.Runways, view.RunwaysInUse = airport.Runways()
+		for _, plane := range airport.Planes {
+			view.ParkedPlanes = append(view.ParkedPlanes, plane.Serial)
+		}
+		airport.Mu.Unlock()
+		if simState.Frame != nil {
+			position := simState.Frame.FromMap(airport.Location)
+			view.Latitude, view.Longitude = &position.Latitude, &position.Longitude
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// planeViews returns every plane of the simulation, parked or in flight, ordered by serial.
+func planeViews(simState *aviation.SimulationState) any {
+	now := simulationTime(simState)
+	views := []planeView{}
+	visitPlanes(simState, func(plane aviation.Plane, airport string) {
+		view := planeView{
+			Serial:         plane.Serial,
+			AircraftType:   plane.AircraftType.Designator,
+			CruiseSpeed:    plane.CruiseSpeed,
+			TCASCapability: aviation.TCASCapabilityName(plane.TCASCapability),
+			InFlight:       plane.PlaneInFlight,
+			Airport:        airport,
+			Flights:        len(plane.FlightLog),
+			Engagements:    len(plane.TCASEngagementRecords) + len(plane.CurrentTCASEngagements),
+		}
+		if plane.PlaneInFlight && len(plane.FlightLog) > 0 {
+			position, velocity := plane.Position(now), plane.VelocityAt(now)
+			advisory := aviation.AdvisoryNone
+			for _, engagement := range plane.CurrentTCASEngagements {
+				advisory = max(advisory, engagement.Advisory)
+			}
+			view.FlightID = plane.FlightLog[len(plane.FlightLog)-1].FlightID
+			view.Position, view.Velocity, view.Advisory = &position, &velocity, &advisory
+		}
+		views = append(views, view)
+	})
+	sort.Slice(views, func(i, j int) bool { return views[i].Serial < views[j].Serial })
+	return views
+}
+
+// flightViews returns every flight flown or being flown in the simulation, ordered by flight ID.
+func flightViews(simState *aviation.SimulationState) any {
+	views := []flightView{}
+	visitPlanes(simState, func(plane aviation.Plane, airport string) {
+		for _, flight := range plane.FlightLog {
+			views = append(views, flightView{
+				FlightID:             flight.FlightID,
+				Plane:                plane.Serial,
+				DepartureAirport:     flight.DepatureAirPort,
+				ArrivalAirport:       flight.ArrivalAirPort,
+				CruisingAltitude:     flight.CruisingAltitude,
+				TakeoffTime:          flight.TakeoffTime,
+				ScheduledArrivalTime: flight.DestinationArrivalTime,
+				LandingTime:          optionalTime(flight.ActualLandingTime),
+				Status:               flight.FlightStatus,
+				TrackPoints:          len(flight.Track),
+			})
+		}
+	})
+	sort.Slice(views, func(i, j int) bool { return views[i].FlightID < views[j].FlightID })
+	return views
+}
+
+// engagementViews returns every TCAS engagement of the simulation, open or closed, ordered by engagement ID.
+func engagementViews(simState *aviation.SimulationState) any {
+	views := []engagementView{}
+	visitPlanes(simState, func(plane aviation.Plane, airport string) {
+		for _, engagement := range append(append([]aviation.TCASEngagement{}, plane.TCASEngagementRecords...), plane.CurrentTCASEngagements...) {
+			views = append(views, engagementView{
+				EngagementID:        engagement.EngagementID,
+				FlightID:            engagement.FlightID,
+				Plane:               engagement.PlaneSerial,
+				Intruder:            engagement.OtherPlaneSerial,
+				Advisory:            engagement.Advisory,
+				SensitivityLevel:    engagement.SensitivityLevel,
+				TATime:              optionalTime(engagement.TATime),
+				RATime:              optionalTime(engagement.RATime),
+				Sense:               engagement.Sense,
+				Coordinated:         engagement.Coordinated,
+				PilotResponse:       engagement.PilotResponse,
+				ClosestApproachTime: engagement.TimeOfEngagement,
+				MissDistance:        engagement.MissDistance,
+				ClosedTime:          optionalTime(engagement.ClosedTime),
+				Collided:            engagement.Collided,
+				Outcome:             aviation.EngagementOutcome(engagement),
+			})
+		}
+	})
+	sort.Slice(views, func(i, j int) bool { return views[i].EngagementID < views[j].EngagementID })
+	return views
+}
+
+// visitPlanes calls visit for every plane of the simulation while it is locked, with the serial of the
+// airport a parked plane is at, so that the flights and engagements it reads are not being written to.
+func visitPlanes(simState *aviation.SimulationState, visit func(plane aviation.Plane, airport string)) {
+	for _, airport := range simState.Airports {
+		airport.Mu.Lock()
+		for _, plane := range airport.Planes {
+			visit(plane, airport.Serial)
+		}
+		airport.Mu.Unlock()
+	}
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+	for _, plane := range simState.PlanesInFlight {
+		visit(plane, "")
+	}
+}
+
+// simulationTime returns the current time of the simulation, its end once it has ended.
+func simulationTime(simState *aviation.SimulationState) time.Time {
+	simState.Mu.Lock()
+	ended := simState.SimEndedTime
+	simState.Mu.Unlock()
+	if !ended.IsZero() || simState.Clock == nil {
+		return ended
+	}
+	return simState.Clock.Now()
+}
+
+// optionalTime returns a pointer to t, or nil for a time that has not happened.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}